@@ -0,0 +1,24 @@
+package dto
+
+import "time"
+
+// APIKeyDto describes an API key without its secret.
+type APIKeyDto struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Prefix           string     `json:"prefix"`
+	Scopes           []string   `json:"scopes"`
+	AllowedIPs       []string   `json:"allowed_ips"`
+	RequireSignature bool       `json:"require_signature"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// APIKeyCreatedDto is returned once, when the key is created. Key and SigningSecret cannot be read again.
+type APIKeyCreatedDto struct {
+	APIKeyDto
+
+	Key           string `json:"key"`
+	SigningSecret string `json:"signing_secret"`
+}
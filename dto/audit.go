@@ -0,0 +1,7 @@
+package dto
+
+// ClientInfo identifies where a request came from, for the audit log.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
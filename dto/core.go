@@ -1,12 +1,23 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/shopspring/decimal"
 )
 
 type WalletDetailsDto struct {
-	Balance       decimal.Decimal `json:"balance"`
-	AccountNumber string          `json:"account_number"`
+	Currency         string          `json:"currency"`
+	LedgerBalance    decimal.Decimal `json:"ledger_balance"`
+	AvailableBalance decimal.Decimal `json:"available_balance"`
+	AccountNumber    string          `json:"account_number"`
+	Status           string          `json:"status"`
+}
+
+// AccountClosureDto is a closed wallet and, when it had a balance, the transaction that swept it.
+type AccountClosureDto struct {
+	Account WalletDetailsDto `json:"account"`
+	Sweep   *TransactionDto  `json:"sweep,omitempty"`
 }
 
 type TransactionDto struct {
@@ -16,6 +27,52 @@ type TransactionDto struct {
 	Amount      decimal.Decimal `json:"amount"`
 	Currency    string          `json:"currency"`
 	Description string          `json:"description"`
+	Fee         *FeeDto         `json:"fee,omitempty" gorm:"-"`
 	CreatedAt   string          `json:"created_at"`
 	UpdatedAt   string          `json:"updated_at"`
 }
+
+// FeeDto is the fee charged on a transaction, Total is the amount plus the fee.
+type FeeDto struct {
+	Operation string          `json:"operation"`
+	Currency  string          `json:"currency"`
+	Kind      string          `json:"kind,omitempty"`
+	Amount    decimal.Decimal `json:"amount"`
+	Fee       decimal.Decimal `json:"fee"`
+	Total     decimal.Decimal `json:"total"`
+}
+
+type ConversionQuoteDto struct {
+	QuoteID      string          `json:"quote_id"`
+	FromCurrency string          `json:"from_currency"`
+	ToCurrency   string          `json:"to_currency"`
+	SourceAmount decimal.Decimal `json:"source_amount"`
+	TargetAmount decimal.Decimal `json:"target_amount"`
+	MidRate      decimal.Decimal `json:"mid_rate"`
+	Rate         decimal.Decimal `json:"rate"`
+	Spread       decimal.Decimal `json:"spread"`
+	ExpiresAt    time.Time       `json:"expires_at"`
+}
+
+type ScheduledTransferDto struct {
+	ID              string          `json:"id"`
+	ToAccountNumber string          `json:"to_account_number"`
+	Currency        string          `json:"currency"`
+	Amount          decimal.Decimal `json:"amount"`
+	Description     string          `json:"description"`
+	CronExpression  string          `json:"cron,omitempty"`
+	IntervalMinutes int             `json:"interval_minutes,omitempty"`
+	NextRunAt       string          `json:"next_run_at"`
+	LastRunAt       string          `json:"last_run_at,omitempty"`
+	Status          string          `json:"status"`
+	FailureCount    int             `json:"failure_count"`
+	LastError       string          `json:"last_error,omitempty"`
+	CreatedAt       string          `json:"created_at"`
+}
+
+type ScheduledTransferRunDto struct {
+	Status               string `json:"status"`
+	TransactionReference string `json:"transaction_reference,omitempty"`
+	Error                string `json:"error,omitempty"`
+	CreatedAt            string `json:"created_at"`
+}
@@ -0,0 +1,103 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// EventEnvelope is the body of every message published to RabbitMQ. Data holds the event's
+// fields in the schema of Version. ID is the same on every redelivery of the event, so
+// consumers can use it to drop duplicates.
+type EventEnvelope struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Version    int         `json:"version"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WalletFundedV1 is the data of wallet.funded.
+type WalletFundedV1 struct {
+	Reference     string          `json:"reference"`
+	UserID        string          `json:"user_id"`
+	AccountNumber string          `json:"account_number"`
+	Amount        decimal.Decimal `json:"amount"`
+	Currency      string          `json:"currency"`
+}
+
+// WalletWithdrawnV1 is the data of wallet.withdrawn. Fee is zero when none was charged.
+type WalletWithdrawnV1 struct {
+	Reference     string          `json:"reference"`
+	UserID        string          `json:"user_id"`
+	AccountNumber string          `json:"account_number"`
+	Amount        decimal.Decimal `json:"amount"`
+	Fee           decimal.Decimal `json:"fee"`
+	Currency      string          `json:"currency"`
+}
+
+// TransferCompletedV1 is the data of transfer.completed. Fee is paid by the sender.
+type TransferCompletedV1 struct {
+	Reference         string          `json:"reference"`
+	FromUserID        string          `json:"from_user_id"`
+	FromAccountNumber string          `json:"from_account_number"`
+	ToUserID          string          `json:"to_user_id"`
+	ToAccountNumber   string          `json:"to_account_number"`
+	Amount            decimal.Decimal `json:"amount"`
+	Fee               decimal.Decimal `json:"fee"`
+	Currency          string          `json:"currency"`
+	// ReceivedAmount and ReceivedCurrency are what the recipient was paid, converted through a
+	// quote when their wallet is in another currency.
+	ReceivedAmount   decimal.Decimal `json:"received_amount"`
+	ReceivedCurrency string          `json:"received_currency"`
+}
+
+// TransactionReversedV1 is the data of transaction.reversed. Amount is what this reversal gave
+// back, RemainingAmount what is left of the original to reverse.
+type TransactionReversedV1 struct {
+	Reference         string          `json:"reference"`
+	OriginalReference string          `json:"original_reference"`
+	UserID            string          `json:"user_id"`
+	Amount            decimal.Decimal `json:"amount"`
+	RemainingAmount   decimal.Decimal `json:"remaining_amount"`
+	Currency          string          `json:"currency"`
+	Reason            string          `json:"reason"`
+}
+
+// HoldV1 is the data of hold.authorized, hold.captured, hold.voided and hold.expired.
+// CapturedAmount is only set on hold.captured.
+type HoldV1 struct {
+	Reference      string           `json:"reference"`
+	UserID         string           `json:"user_id"`
+	AccountNumber  string           `json:"account_number"`
+	Amount         decimal.Decimal  `json:"amount"`
+	CapturedAmount *decimal.Decimal `json:"captured_amount,omitempty"`
+	Currency       string           `json:"currency"`
+	ExpiresAt      time.Time        `json:"expires_at"`
+}
+
+// FundsConvertedV1 is the data of funds.converted.
+type FundsConvertedV1 struct {
+	Reference         string          `json:"reference"`
+	UserID            string          `json:"user_id"`
+	QuoteID           string          `json:"quote_id"`
+	FromAccountNumber string          `json:"from_account_number"`
+	ToAccountNumber   string          `json:"to_account_number"`
+	FromCurrency      string          `json:"from_currency"`
+	ToCurrency        string          `json:"to_currency"`
+	SourceAmount      decimal.Decimal `json:"source_amount"`
+	TargetAmount      decimal.Decimal `json:"target_amount"`
+	Rate              decimal.Decimal `json:"rate"`
+}
+
+// AccountStatusChangedV1 is the data of account.status_changed. A closure that swept the balance
+// to another wallet names the sweep transaction in SweepReference.
+type AccountStatusChangedV1 struct {
+	UserID         string `json:"user_id"`
+	AccountNumber  string `json:"account_number"`
+	FromStatus     string `json:"from_status"`
+	ToStatus       string `json:"to_status"`
+	Reason         string `json:"reason"`
+	ActorID        string `json:"actor_id"`
+	SweepReference string `json:"sweep_reference,omitempty"`
+}
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEndpointDto describes a webhook endpoint without its secret.
+type WebhookEndpointDto struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookEndpointCreatedDto is returned once, when the endpoint is created. Secret cannot be read again.
+type WebhookEndpointCreatedDto struct {
+	WebhookEndpointDto
+
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryDto struct {
+	ID            string     `json:"id"`
+	EventID       string     `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// WebhookDeliveryDetailDto is a delivery with the payload sent and every attempt made.
+type WebhookDeliveryDetailDto struct {
+	WebhookDeliveryDto
+
+	Payload     json.RawMessage     `json:"payload"`
+	AttemptLogs []WebhookAttemptDto `json:"attempt_logs"`
+}
+
+type WebhookAttemptDto struct {
+	StatusCode   int       `json:"status_code"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WebhookEventDto is the body of every webhook request. ID is the same for every delivery and
+// retry of an event, so receivers can use it to drop duplicates.
+type WebhookEventDto struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt string      `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WalletEventDataDto is the data of a wallet event: the transaction as seen from the wallet it
+// happened to, and the other wallet of a transfer.
+type WalletEventDataDto struct {
+	Transaction               TransactionDto `json:"transaction"`
+	AccountNumber             string         `json:"account_number"`
+	CounterpartyAccountNumber string         `json:"counterparty_account_number,omitempty"`
+	ReversalOf                string         `json:"reversal_of,omitempty"`
+	Reason                    string         `json:"reason,omitempty"`
+}
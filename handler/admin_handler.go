@@ -0,0 +1,405 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/horlakz/wallet-sync.api/payload/request"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+	"github.com/horlakz/wallet-sync.api/validator"
+)
+
+type adminHandler struct {
+	walletService service.WalletServiceInterface
+	limitService  service.LimitServiceInterface
+	adminService  service.AdminServiceInterface
+	validator     validator.AdminValidator
+}
+
+type AdminHandlerInterface interface {
+	ReverseTransaction(c *fiber.Ctx) error
+	ListLimitRules(c *fiber.Ctx) error
+	SaveLimitRule(c *fiber.Ctx) error
+	SetKycLevel(c *fiber.Ctx) error
+	ListUsers(c *fiber.Ctx) error
+	GetUser(c *fiber.Ctx) error
+	GetUserWallets(c *fiber.Ctx) error
+	SetRole(c *fiber.Ctx) error
+	SetAccountStatus(c *fiber.Ctx) error
+	CloseAccount(c *fiber.Ctx) error
+	GetAccountStatusHistory(c *fiber.Ctx) error
+	ListReconciliationLogs(c *fiber.Ctx) error
+}
+
+func NewAdminHandler(walletService service.WalletServiceInterface, limitService service.LimitServiceInterface, adminService service.AdminServiceInterface) AdminHandlerInterface {
+	return &adminHandler{walletService: walletService, limitService: limitService, adminService: adminService}
+}
+
+func (handler *adminHandler) ReverseTransaction(c *fiber.Ctx) error {
+	var reverseRequest request.TransactionReverseRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&reverseRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.ReverseValidate(reverseRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	amountDecimal := decimal.NewFromFloat(reverseRequest.Amount)
+
+	reversal, err := handler.walletService.ReverseTransaction(GetUserId(c), c.Params("reference"), reverseRequest.Reason, amountDecimal, GetClientInfo(c))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Status = http.StatusNotFound
+		}
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Transaction reversed successfully"
+	resp.Data = reversal
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) ListLimitRules(c *fiber.Ctx) error {
+	var resp response.Response
+
+	rules, err := handler.limitService.ListLimitRules()
+	if err != nil {
+		resp.Status = http.StatusInternalServerError
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Limit rules retrieved successfully"
+	resp.Data = rules
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) SaveLimitRule(c *fiber.Ctx) error {
+	var limitRequest request.LimitRuleRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&limitRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.LimitRuleValidate(limitRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	rule := &model.LimitRule{
+		KycLevel:              limitRequest.KycLevel,
+		Operation:             limitRequest.Operation,
+		Currency:              strings.ToUpper(limitRequest.Currency),
+		PerTransaction:        decimal.NewFromFloat(limitRequest.PerTransaction),
+		Daily:                 decimal.NewFromFloat(limitRequest.Daily),
+		Monthly:               decimal.NewFromFloat(limitRequest.Monthly),
+		VelocityCount:         limitRequest.VelocityCount,
+		VelocityWindowMinutes: limitRequest.VelocityWindowMinutes,
+	}
+
+	if limitRequest.UserID != "" {
+		userId := uuid.MustParse(limitRequest.UserID)
+		rule.UserID = &userId
+	}
+
+	if err := handler.limitService.SaveLimitRule(rule); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Limit rule saved successfully"
+	resp.Data = rule
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) SetKycLevel(c *fiber.Ctx) error {
+	var kycRequest request.KycLevelRequest
+	var resp response.Response
+
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid user id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := c.BodyParser(&kycRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.KycLevelValidate(kycRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.limitService.SetKycLevel(userId, kycRequest.KycLevel); err != nil {
+		resp.Status = http.StatusBadRequest
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.Status = http.StatusNotFound
+		}
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "KYC level updated successfully"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) ListUsers(c *fiber.Ctx) error {
+	var resp response.Response
+
+	users, pagination, err := handler.adminService.ListUsers(c.Query("role"), GeneratePageable(c))
+	if err != nil {
+		resp.Status = http.StatusInternalServerError
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Users retrieved successfully"
+	resp.Data = users
+	resp.Meta = pagination
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) GetUser(c *fiber.Ctx) error {
+	var resp response.Response
+
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid user id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	user, err := handler.adminService.GetUser(userId)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "User retrieved successfully"
+	resp.Data = user
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) GetUserWallets(c *fiber.Ctx) error {
+	var resp response.Response
+
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid user id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.adminService.GetUser(userId); err != nil {
+		return adminError(c, err)
+	}
+
+	wallets, err := handler.walletService.GetWalletDetails(userId)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Wallets retrieved successfully"
+	resp.Data = wallets
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) SetRole(c *fiber.Ctx) error {
+	var roleRequest request.RoleRequest
+	var resp response.Response
+
+	userId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid user id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := c.BodyParser(&roleRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.RoleValidate(roleRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.adminService.SetRole(GetUserId(c), userId, roleRequest.Role, GetClientInfo(c)); err != nil {
+		return adminError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Role updated successfully"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) SetAccountStatus(c *fiber.Ctx) error {
+	var statusRequest request.AccountStatusRequest
+	var resp response.Response
+
+	accountId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid account id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := c.BodyParser(&statusRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.AccountStatusValidate(statusRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	account, err := handler.walletService.ChangeAccountStatus(GetUserId(c), accountId, statusRequest.Status, statusRequest.Reason)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Account status updated successfully"
+	resp.Data = account
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) CloseAccount(c *fiber.Ctx) error {
+	var closeRequest request.AccountCloseRequest
+	var resp response.Response
+
+	accountId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid account id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := c.BodyParser(&closeRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.AccountCloseValidate(closeRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	closure, err := handler.walletService.CloseAccount(GetUserId(c), accountId, closeRequest.Reason, closeRequest.SweepToAccountNumber)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Account closed successfully"
+	resp.Data = closure
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) GetAccountStatusHistory(c *fiber.Ctx) error {
+	var resp response.Response
+
+	accountId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid account id"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	changes, err := handler.walletService.GetAccountStatusHistory(accountId)
+	if err != nil {
+		return adminError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Account status history retrieved successfully"
+	resp.Data = changes
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *adminHandler) ListReconciliationLogs(c *fiber.Ctx) error {
+	var resp response.Response
+	var accountId *uuid.UUID
+
+	if value := c.Query("account_id"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			resp.Status = http.StatusBadRequest
+			resp.Message = "Invalid account id"
+			return c.Status(resp.Status).JSON(resp)
+		}
+		accountId = &parsed
+	}
+
+	logs, pagination, err := handler.adminService.ListReconciliationLogs(accountId, GeneratePageable(c))
+	if err != nil {
+		resp.Status = http.StatusInternalServerError
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Reconciliation logs retrieved successfully"
+	resp.Data = logs
+	resp.Meta = pagination
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func adminError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusBadRequest
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		resp.Status = http.StatusNotFound
+		err = errors.New("not found")
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/payload/request"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+	"github.com/horlakz/wallet-sync.api/validator"
+)
+
+type apiKeyHandler struct {
+	apiKeyService service.APIKeyServiceInterface
+	validator     validator.APIKeyValidator
+}
+
+type APIKeyHandlerInterface interface {
+	Create(c *fiber.Ctx) error
+	List(c *fiber.Ctx) error
+	Revoke(c *fiber.Ctx) error
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyServiceInterface) APIKeyHandlerInterface {
+	return &apiKeyHandler{apiKeyService: apiKeyService}
+}
+
+func (handler *apiKeyHandler) Create(c *fiber.Ctx) error {
+	var resp response.Response
+	var apiKeyRequest request.APIKeyRequest
+
+	if err := c.BodyParser(&apiKeyRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.APIKeyValidate(apiKeyRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	slices.Sort(apiKeyRequest.Scopes)
+
+	apiKey, err := handler.apiKeyService.CreateAPIKey(GetUserId(c), service.APIKeyInput{
+		Name:             apiKeyRequest.Name,
+		Scopes:           slices.Compact(apiKeyRequest.Scopes),
+		AllowedIPs:       apiKeyRequest.AllowedIPs,
+		RequireSignature: apiKeyRequest.RequireSignature,
+	}, GetClientInfo(c))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	resp.Status = http.StatusCreated
+	resp.Message = "API key created, store the key and signing secret now as they will not be shown again"
+	resp.Data = apiKey
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *apiKeyHandler) List(c *fiber.Ctx) error {
+	var resp response.Response
+
+	apiKeys, err := handler.apiKeyService.GetAPIKeys(GetUserId(c))
+	if err != nil {
+		return apiKeyError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "API keys retrieved successfully"
+	resp.Data = apiKeys
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *apiKeyHandler) Revoke(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return apiKeyError(c, gorm.ErrRecordNotFound)
+	}
+
+	if err := handler.apiKeyService.RevokeAPIKey(GetUserId(c), id, GetClientInfo(c)); err != nil {
+		return apiKeyError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "API key revoked"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func apiKeyError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusBadRequest
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		resp.Status = http.StatusNotFound
+		err = errors.New("API key not found")
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"github.com/horlakz/wallet-sync.api/dto"
 	"github.com/horlakz/wallet-sync.api/payload/request"
@@ -13,17 +18,33 @@ import (
 )
 
 type authHandler struct {
-	authService service.AuthServiceInterface
-	validator   validator.AuthValidator
+	authService    service.AuthServiceInterface
+	sessionService service.SessionServiceInterface
+	validator      validator.AuthValidator
 }
 
 type AuthHandlerInterface interface {
 	Login(c *fiber.Ctx) error
+	LoginTwoFactor(c *fiber.Ctx) error
+	UnlockAccount(c *fiber.Ctx) error
 	Register(c *fiber.Ctx) error
+	Refresh(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
+	VerifyEmail(c *fiber.Ctx) error
+	ResendVerification(c *fiber.Ctx) error
+	ForgotPassword(c *fiber.Ctx) error
+	ResetPassword(c *fiber.Ctx) error
+	SetupTwoFactor(c *fiber.Ctx) error
+	ConfirmTwoFactor(c *fiber.Ctx) error
+	DisableTwoFactor(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+	ListSessions(c *fiber.Ctx) error
+	RevokeSession(c *fiber.Ctx) error
+	RevokeOtherSessions(c *fiber.Ctx) error
 }
 
-func NewAuthHandler(authService service.AuthServiceInterface) AuthHandlerInterface {
-	return &authHandler{authService: authService}
+func NewAuthHandler(authService service.AuthServiceInterface, sessionService service.SessionServiceInterface) AuthHandlerInterface {
+	return &authHandler{authService: authService, sessionService: sessionService}
 }
 
 func (handler *authHandler) Login(c *fiber.Ctx) error {
@@ -43,17 +64,101 @@ func (handler *authHandler) Login(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(resp)
 	}
 
-	token, err := handler.authService.Login(loginRequest.Email, loginRequest.Password)
+	tokens, err := handler.authService.Login(loginRequest.Email, loginRequest.Password, loginRequest.DeviceName, GetClientInfo(c))
 
 	if err != nil {
+		return loginError(c, &resp.Response, err, http.StatusBadRequest)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = http.StatusText(http.StatusOK)
+	resp.Data = tokens
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	var resp response.LoginResponse
+
+	loginRequest := new(request.LoginTwoFactorRequest)
+
+	if err := c.BodyParser(loginRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if _, err := handler.validator.LoginTwoFactorValidate(*loginRequest); err != nil {
 		resp.Status = http.StatusBadRequest
 		resp.Message = err.Error()
 		return c.Status(http.StatusBadRequest).JSON(resp)
 	}
 
+	tokens, err := handler.authService.LoginTwoFactor(loginRequest.MfaToken, loginRequest.Code, GetClientInfo(c))
+
+	if err != nil {
+		return loginError(c, &resp.Response, err, http.StatusUnauthorized)
+	}
+
 	resp.Status = http.StatusOK
 	resp.Message = http.StatusText(http.StatusOK)
-	resp.Data.AccessToken = token
+	resp.Data = tokens
+
+	return c.JSON(resp)
+}
+
+// loginError answers a refused login with the code of the refusal. Throttled and blocked attempts
+// tell the client when to try again. Other errors are answered with status.
+func loginError(c *fiber.Ctx, resp *response.Response, err error, status int) error {
+	resp.Status = status
+
+	var loginErr *service.LoginError
+	if errors.As(err, &loginErr) {
+		resp.Code = loginErr.Code
+
+		switch loginErr.Code {
+		case service.CodeInvalidCredentials:
+			resp.Status = http.StatusUnauthorized
+		case service.CodeAccountLocked:
+			resp.Status = http.StatusLocked
+		case service.CodeLoginThrottled, service.CodeIPBlocked:
+			resp.Status = http.StatusTooManyRequests
+		}
+
+		if loginErr.RetryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(loginErr.RetryAfter.Seconds()))))
+		}
+	}
+
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *authHandler) UnlockAccount(c *fiber.Ctx) error {
+	var resp response.Response
+
+	unlockRequest := new(request.UnlockAccountRequest)
+
+	if err := c.BodyParser(unlockRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if _, err := handler.validator.UnlockAccountValidate(*unlockRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if err := handler.authService.UnlockAccount(unlockRequest.Token, GetClientInfo(c)); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Account unlocked, you can sign in again"
 
 	return c.JSON(resp)
 }
@@ -92,3 +197,316 @@ func (handler *authHandler) Register(c *fiber.Ctx) error {
 
 	return c.JSON(resp)
 }
+
+func (handler *authHandler) Refresh(c *fiber.Ctx) error {
+	var resp response.LoginResponse
+
+	refreshRequest := new(request.RefreshRequest)
+
+	if err := c.BodyParser(refreshRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if _, err := handler.validator.RefreshValidate(*refreshRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	tokens, err := handler.authService.Refresh(refreshRequest.RefreshToken)
+
+	if err != nil {
+		resp.Status = http.StatusUnauthorized
+		resp.Message = err.Error()
+		return c.Status(http.StatusUnauthorized).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = http.StatusText(http.StatusOK)
+	resp.Data = tokens
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) Logout(c *fiber.Ctx) error {
+	var resp response.Response
+
+	sessionId := c.Locals("sessionId").(string)
+
+	if err := handler.authService.Logout(sessionId); err != nil {
+		resp.Status = http.StatusInternalServerError
+		resp.Message = err.Error()
+		return c.Status(http.StatusInternalServerError).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Logged out successfully"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) VerifyEmail(c *fiber.Ctx) error {
+	var resp response.Response
+
+	verifyRequest := new(request.VerifyEmailRequest)
+
+	if err := c.BodyParser(verifyRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if _, err := handler.validator.VerifyEmailValidate(*verifyRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if err := handler.authService.VerifyEmail(verifyRequest.Token); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Email verified successfully"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) ResendVerification(c *fiber.Ctx) error {
+	var resp response.Response
+
+	if err := handler.authService.ResendVerification(GetUserId(c)); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Verification email sent"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) ForgotPassword(c *fiber.Ctx) error {
+	var resp response.Response
+
+	forgotRequest := new(request.ForgotPasswordRequest)
+
+	if err := c.BodyParser(forgotRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if _, err := handler.validator.ForgotPasswordValidate(*forgotRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if err := handler.authService.ForgotPassword(forgotRequest.Email); err != nil {
+		resp.Status = http.StatusInternalServerError
+		resp.Message = err.Error()
+		return c.Status(http.StatusInternalServerError).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "If an account exists for this email, a reset link has been sent"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) ResetPassword(c *fiber.Ctx) error {
+	var resp response.Response
+
+	resetRequest := new(request.ResetPasswordRequest)
+
+	if err := c.BodyParser(resetRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if _, err := handler.validator.ResetPasswordValidate(*resetRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	if err := handler.authService.ResetPassword(resetRequest.Token, resetRequest.Password); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Password reset successfully"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	var resp response.Response
+
+	setup, err := handler.authService.SetupTwoFactor(GetUserId(c))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scan the secret with an authenticator app and confirm it with a code"
+	resp.Data = setup
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	var resp response.Response
+
+	codeRequest, ok := handler.parseTwoFactorCode(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	codes, err := handler.authService.ConfirmTwoFactor(GetUserId(c), codeRequest.Code)
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Two-factor authentication enabled, store the recovery codes somewhere safe"
+	resp.Data = codes
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	var resp response.Response
+
+	codeRequest, ok := handler.parseTwoFactorCode(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.authService.DisableTwoFactor(GetUserId(c), codeRequest.Code, GetClientInfo(c)); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Two-factor authentication disabled"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var resp response.Response
+
+	codeRequest, ok := handler.parseTwoFactorCode(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	codes, err := handler.authService.RegenerateRecoveryCodes(GetUserId(c), codeRequest.Code, GetClientInfo(c))
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(http.StatusBadRequest).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Recovery codes regenerated, the previous codes no longer work"
+	resp.Data = codes
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) ListSessions(c *fiber.Ctx) error {
+	var resp response.Response
+
+	sessions, err := handler.sessionService.GetSessions(GetUserId(c), c.Locals("sessionId").(string))
+	if err != nil {
+		return sessionError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Sessions retrieved successfully"
+	resp.Data = sessions
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) RevokeSession(c *fiber.Ctx) error {
+	var resp response.Response
+
+	sessionId, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return sessionError(c, gorm.ErrRecordNotFound)
+	}
+
+	if err := handler.sessionService.RevokeSession(GetUserId(c), sessionId, c.Locals("sessionId").(string), GetClientInfo(c)); err != nil {
+		return sessionError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Session revoked"
+
+	return c.JSON(resp)
+}
+
+func (handler *authHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	var resp response.Response
+
+	revoked, err := handler.sessionService.RevokeOtherSessions(GetUserId(c), c.Locals("sessionId").(string), GetClientInfo(c))
+	if err != nil {
+		return sessionError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Signed out of all other sessions"
+	resp.Data = map[string]interface{}{"revoked": revoked}
+
+	return c.JSON(resp)
+}
+
+func sessionError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		resp.Status = http.StatusNotFound
+		err = errors.New("session not found")
+	case errors.Is(err, service.ErrCurrentSession):
+		resp.Status = http.StatusBadRequest
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *authHandler) parseTwoFactorCode(c *fiber.Ctx, resp *response.Response) (request.TwoFactorCodeRequest, bool) {
+	var codeRequest request.TwoFactorCodeRequest
+
+	if err := c.BodyParser(&codeRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return codeRequest, false
+	}
+
+	if _, err := handler.validator.TwoFactorCodeValidate(codeRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return codeRequest, false
+	}
+
+	return codeRequest, true
+}
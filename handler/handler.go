@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
+	"github.com/horlakz/wallet-sync.api/dto"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/payload/response"
 	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
@@ -18,6 +19,18 @@ func GetUserId(c *fiber.Ctx) uuid.UUID {
 	return userId
 }
 
+// GetClientInfo returns the caller's address and user agent for the audit log.
+func GetClientInfo(c *fiber.Ctx) dto.ClientInfo {
+	return dto.ClientInfo{IPAddress: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+}
+
+// GetIdempotencyKey returns the Idempotency-Key claimed by the Idempotency middleware, or "" without one.
+func GetIdempotencyKey(c *fiber.Ctx) string {
+	key, _ := c.Locals("idempotencyKey").(string)
+
+	return key
+}
+
 func Index(c *fiber.Ctx) error {
 
 	var resp response.Response
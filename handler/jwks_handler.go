@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+type jwksHandler struct {
+	signingKeyService service.SigningKeyServiceInterface
+}
+
+type JWKSHandlerInterface interface {
+	Get(c *fiber.Ctx) error
+}
+
+func NewJWKSHandler(signingKeyService service.SigningKeyServiceInterface) JWKSHandlerInterface {
+	return &jwksHandler{signingKeyService: signingKeyService}
+}
+
+// Get serves the public signing keys as a bare JWK Set so standard JWT libraries can read it.
+func (handler *jwksHandler) Get(c *fiber.Ctx) error {
+	jwks, err := handler.signingKeyService.JWKS()
+	if err != nil {
+		var resp response.Response
+
+		resp.Status = http.StatusInternalServerError
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	// new keys are published an hour before they sign, so verifiers can cache the set for a while
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(http.StatusOK).JSON(jwks)
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/payload/request"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+	"github.com/horlakz/wallet-sync.api/validator"
+)
+
+type profileHandler struct {
+	profileService service.ProfileServiceInterface
+	validator      validator.ProfileValidator
+}
+
+type ProfileHandlerInterface interface {
+	Get(c *fiber.Ctx) error
+	Update(c *fiber.Ctx) error
+	ChangePassword(c *fiber.Ctx) error
+	ChangeEmail(c *fiber.Ctx) error
+	ConfirmEmailChange(c *fiber.Ctx) error
+}
+
+func NewProfileHandler(profileService service.ProfileServiceInterface) ProfileHandlerInterface {
+	return &profileHandler{profileService: profileService}
+}
+
+func (handler *profileHandler) Get(c *fiber.Ctx) error {
+	var resp response.Response
+
+	profile, err := handler.profileService.GetProfile(GetUserId(c))
+	if err != nil {
+		return profileError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Profile retrieved successfully"
+	resp.Data = profile
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *profileHandler) Update(c *fiber.Ctx) error {
+	var resp response.Response
+	var profileRequest request.UpdateProfileRequest
+
+	if err := c.BodyParser(&profileRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.UpdateProfileValidate(profileRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	profile, err := handler.profileService.UpdateProfile(GetUserId(c), profileRequest.Name)
+	if err != nil {
+		return profileError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Profile updated successfully"
+	resp.Data = profile
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *profileHandler) ChangePassword(c *fiber.Ctx) error {
+	var resp response.Response
+	var passwordRequest request.ChangePasswordRequest
+
+	if err := c.BodyParser(&passwordRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.ChangePasswordValidate(passwordRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	sessionId, _ := c.Locals("sessionId").(string)
+
+	if err := handler.profileService.ChangePassword(GetUserId(c), sessionId, passwordRequest.CurrentPassword, passwordRequest.NewPassword, GetClientInfo(c)); err != nil {
+		return profileError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Password changed successfully"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *profileHandler) ChangeEmail(c *fiber.Ctx) error {
+	var resp response.Response
+	var emailRequest request.ChangeEmailRequest
+
+	if err := c.BodyParser(&emailRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.ChangeEmailValidate(emailRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.profileService.RequestEmailChange(GetUserId(c), emailRequest.Email, emailRequest.Password, GetClientInfo(c)); err != nil {
+		return profileError(c, err)
+	}
+
+	resp.Status = http.StatusAccepted
+	resp.Message = "Check the new address for a link to confirm the change"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *profileHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var resp response.Response
+	var confirmRequest request.ConfirmEmailChangeRequest
+
+	if err := c.BodyParser(&confirmRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if vEs, err := handler.validator.ConfirmEmailChangeValidate(confirmRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	profile, err := handler.profileService.ConfirmEmailChange(confirmRequest.Token, GetClientInfo(c))
+	if err != nil {
+		return profileError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Email changed successfully"
+	resp.Data = profile
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func profileError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusBadRequest
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		resp.Status = http.StatusNotFound
+		err = errors.New("user not found")
+	case errors.Is(err, service.ErrInvalidPassword):
+		resp.Status = http.StatusUnauthorized
+	case errors.Is(err, service.ErrEmailTaken):
+		resp.Status = http.StatusConflict
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
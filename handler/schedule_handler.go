@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/payload/request"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+	"github.com/horlakz/wallet-sync.api/validator"
+)
+
+type scheduleHandler struct {
+	scheduleService  service.ScheduleServiceInterface
+	twoFactorService service.TwoFactorServiceInterface
+	pinService       service.PinServiceInterface
+	validator        validator.ScheduleValidator
+}
+
+type ScheduleHandlerInterface interface {
+	Create(c *fiber.Ctx) error
+	List(c *fiber.Ctx) error
+	Get(c *fiber.Ctx) error
+	Update(c *fiber.Ctx) error
+	Pause(c *fiber.Ctx) error
+	Resume(c *fiber.Ctx) error
+	Delete(c *fiber.Ctx) error
+	Runs(c *fiber.Ctx) error
+}
+
+func NewScheduleHandler(scheduleService service.ScheduleServiceInterface, twoFactorService service.TwoFactorServiceInterface, pinService service.PinServiceInterface) ScheduleHandlerInterface {
+	return &scheduleHandler{scheduleService: scheduleService, twoFactorService: twoFactorService, pinService: pinService}
+}
+
+func (handler *scheduleHandler) Create(c *fiber.Ctx) error {
+	var resp response.Response
+
+	input, pin, ok := handler.parseInput(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	// the runs happen without the user, so the PIN and second factor are asked for when the schedule is saved
+	if err := authorizeMovement(c, handler.pinService, handler.twoFactorService, input.Currency, input.Amount, pin); err != nil {
+		return authorizationError(c, err)
+	}
+
+	schedule, err := handler.scheduleService.CreateSchedule(GetUserId(c), input)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusCreated
+	resp.Message = "Scheduled transfer created successfully"
+	resp.Data = schedule
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) List(c *fiber.Ctx) error {
+	var resp response.Response
+
+	schedules, err := handler.scheduleService.GetSchedules(GetUserId(c))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfers retrieved successfully"
+	resp.Data = schedules
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) Get(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scheduleError(c, gorm.ErrRecordNotFound)
+	}
+
+	schedule, err := handler.scheduleService.GetSchedule(GetUserId(c), id)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfer retrieved successfully"
+	resp.Data = schedule
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) Update(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scheduleError(c, gorm.ErrRecordNotFound)
+	}
+
+	input, pin, ok := handler.parseInput(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := authorizeMovement(c, handler.pinService, handler.twoFactorService, input.Currency, input.Amount, pin); err != nil {
+		return authorizationError(c, err)
+	}
+
+	schedule, err := handler.scheduleService.UpdateSchedule(GetUserId(c), id, input)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfer updated successfully"
+	resp.Data = schedule
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) Pause(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scheduleError(c, gorm.ErrRecordNotFound)
+	}
+
+	schedule, err := handler.scheduleService.PauseSchedule(GetUserId(c), id)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfer paused"
+	resp.Data = schedule
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) Resume(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scheduleError(c, gorm.ErrRecordNotFound)
+	}
+
+	schedule, err := handler.scheduleService.ResumeSchedule(GetUserId(c), id)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfer resumed"
+	resp.Data = schedule
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) Delete(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scheduleError(c, gorm.ErrRecordNotFound)
+	}
+
+	if err := handler.scheduleService.DeleteSchedule(GetUserId(c), id); err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfer deleted"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *scheduleHandler) Runs(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return scheduleError(c, gorm.ErrRecordNotFound)
+	}
+
+	runs, err := handler.scheduleService.GetScheduleRuns(GetUserId(c), id)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Scheduled transfer runs retrieved successfully"
+	resp.Data = runs
+	return c.Status(resp.Status).JSON(resp)
+}
+
+// parseInput reads and validates a schedule request body and returns it with the transaction PIN,
+// filling resp when it is rejected.
+func (handler *scheduleHandler) parseInput(c *fiber.Ctx, resp *response.Response) (service.ScheduleInput, string, bool) {
+	var scheduleRequest request.ScheduledTransferRequest
+
+	if err := c.BodyParser(&scheduleRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return service.ScheduleInput{}, "", false
+	}
+
+	if vEs, err := handler.validator.ScheduleValidate(scheduleRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return service.ScheduleInput{}, "", false
+	}
+
+	input := service.ScheduleInput{
+		ToAccountNumber: scheduleRequest.ToAccountNumber,
+		Currency:        scheduleRequest.Currency,
+		Amount:          decimal.NewFromFloat(scheduleRequest.Amount),
+		Description:     scheduleRequest.Description,
+		CronExpression:  scheduleRequest.Cron,
+		IntervalMinutes: scheduleRequest.IntervalMinutes,
+	}
+
+	if scheduleRequest.RunAt != "" {
+		runAt, _ := time.Parse(time.RFC3339, scheduleRequest.RunAt)
+		input.RunAt = &runAt
+	}
+
+	return input, scheduleRequest.Pin, true
+}
+
+func scheduleError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusBadRequest
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		resp.Status = http.StatusNotFound
+		err = errors.New("scheduled transfer not found")
+	} else if errors.Is(err, service.ErrScheduleRunning) {
+		resp.Status = http.StatusConflict
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,19 +14,42 @@ import (
 )
 
 type walletHandler struct {
-	walletService service.WalletServiceInterface
-	validator     validator.WalletValidator
+	walletService    service.WalletServiceInterface
+	fxService        service.FxServiceInterface
+	feeService       service.FeeServiceInterface
+	twoFactorService service.TwoFactorServiceInterface
+	pinService       service.PinServiceInterface
+	validator        validator.WalletValidator
 }
 
 type WalletHandlerInterface interface {
 	GetDetails(c *fiber.Ctx) error
+	Open(c *fiber.Ctx) error
 	Transfer(c *fiber.Ctx) error
 	Fund(c *fiber.Ctx) error
 	Withdraw(c *fiber.Ctx) error
+	Authorize(c *fiber.Ctx) error
+	Capture(c *fiber.Ctx) error
+	Void(c *fiber.Ctx) error
+	Quote(c *fiber.Ctx) error
+	Convert(c *fiber.Ctx) error
+	QuoteFee(c *fiber.Ctx) error
+	SetPin(c *fiber.Ctx) error
+	ChangePin(c *fiber.Ctx) error
+	ResetPin(c *fiber.Ctx) error
 }
 
-func NewWalletHandler(walletService service.WalletServiceInterface) WalletHandlerInterface {
-	return &walletHandler{walletService: walletService}
+// OtpHeader carries the two-factor code for operations that need step-up verification.
+const OtpHeader = "X-OTP-Code"
+
+func NewWalletHandler(walletService service.WalletServiceInterface, fxService service.FxServiceInterface, feeService service.FeeServiceInterface, twoFactorService service.TwoFactorServiceInterface, pinService service.PinServiceInterface) WalletHandlerInterface {
+	return &walletHandler{
+		walletService:    walletService,
+		fxService:        fxService,
+		feeService:       feeService,
+		twoFactorService: twoFactorService,
+		pinService:       pinService,
+	}
 }
 
 func (handler *walletHandler) GetDetails(c *fiber.Ctx) error {
@@ -46,6 +70,37 @@ func (handler *walletHandler) GetDetails(c *fiber.Ctx) error {
 	return c.Status(resp.Status).JSON(resp)
 }
 
+func (handler *walletHandler) Open(c *fiber.Ctx) error {
+	var openRequest request.WalletOpenRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&openRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.OpenValidate(openRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	userId := c.Locals("userId").(uuid.UUID)
+
+	wallet, err := handler.walletService.OpenWallet(userId, openRequest.Currency)
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusCreated
+	resp.Message = "Wallet opened successfully"
+	resp.Data = wallet
+	return c.Status(resp.Status).JSON(resp)
+}
+
 func (handler *walletHandler) Transfer(c *fiber.Ctx) error {
 	var transferRequest request.WalletTransferRequest
 	var resp response.Response
@@ -65,14 +120,21 @@ func (handler *walletHandler) Transfer(c *fiber.Ctx) error {
 	userId := c.Locals("userId").(uuid.UUID)
 
 	amountDecimal := decimal.NewFromFloat(transferRequest.Amount)
-	if err := handler.walletService.TransferFunds(userId, transferRequest.ToAccountNumber, amountDecimal); err != nil {
-		resp.Status = http.StatusBadRequest
-		resp.Message = err.Error()
-		return c.Status(resp.Status).JSON(resp)
+
+	if err := authorizeMovement(c, handler.pinService, handler.twoFactorService, transferRequest.Currency, amountDecimal, transferRequest.Pin); err != nil {
+		return authorizationError(c, err)
 	}
 
+	transaction, err := handler.walletService.TransferFunds(userId, transferRequest.ToAccountNumber, transferRequest.Currency, amountDecimal, transferRequest.QuoteID, GetIdempotencyKey(c))
+	if err != nil {
+		return walletError(c, err)
+	}
+
+	c.Locals("transactionReference", transaction.Reference)
+
 	resp.Status = http.StatusOK
 	resp.Message = "Transfer successful"
+	resp.Data = transaction
 	return c.Status(resp.Status).JSON(resp)
 }
 
@@ -96,14 +158,16 @@ func (handler *walletHandler) Fund(c *fiber.Ctx) error {
 
 	amountDecimal := decimal.NewFromFloat(fundRequest.Amount)
 
-	if err := handler.walletService.FundWallet(userId, amountDecimal); err != nil {
-		resp.Status = http.StatusBadRequest
-		resp.Message = err.Error()
-		return c.Status(resp.Status).JSON(resp)
+	transaction, err := handler.walletService.FundWallet(userId, fundRequest.Currency, amountDecimal, GetIdempotencyKey(c))
+	if err != nil {
+		return walletError(c, err)
 	}
 
+	c.Locals("transactionReference", transaction.Reference)
+
 	resp.Status = http.StatusOK
 	resp.Message = "Wallet funded successfully"
+	resp.Data = transaction
 	return c.Status(resp.Status).JSON(resp)
 }
 
@@ -127,13 +191,341 @@ func (handler *walletHandler) Withdraw(c *fiber.Ctx) error {
 
 	amountDecimal := decimal.NewFromFloat(withdrawRequest.Amount)
 
-	if err := handler.walletService.WithdrawFromWallet(userId, amountDecimal); err != nil {
+	if err := authorizeMovement(c, handler.pinService, handler.twoFactorService, withdrawRequest.Currency, amountDecimal, withdrawRequest.Pin); err != nil {
+		return authorizationError(c, err)
+	}
+
+	transaction, err := handler.walletService.WithdrawFromWallet(userId, withdrawRequest.Currency, amountDecimal, GetIdempotencyKey(c))
+	if err != nil {
+		return walletError(c, err)
+	}
+
+	c.Locals("transactionReference", transaction.Reference)
+
+	resp.Status = http.StatusOK
+	resp.Message = "Withdrawal successful"
+	resp.Data = transaction
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) Authorize(c *fiber.Ctx) error {
+	var holdRequest request.WalletHoldRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&holdRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.HoldValidate(holdRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	userId := c.Locals("userId").(uuid.UUID)
+
+	amountDecimal := decimal.NewFromFloat(holdRequest.Amount)
+
+	// a hold is captured without the user, so the PIN and second factor are asked for up front
+	if err := authorizeMovement(c, handler.pinService, handler.twoFactorService, holdRequest.Currency, amountDecimal, holdRequest.Pin); err != nil {
+		return authorizationError(c, err)
+	}
+
+	transaction, err := handler.walletService.AuthorizeHold(userId, holdRequest.Currency, amountDecimal, holdRequest.Description, GetIdempotencyKey(c))
+	if err != nil {
+		return walletError(c, err)
+	}
+
+	c.Locals("transactionReference", transaction.Reference)
+
+	resp.Status = http.StatusOK
+	resp.Message = "Funds authorized successfully"
+	resp.Data = transaction
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) Capture(c *fiber.Ctx) error {
+	var captureRequest request.WalletCaptureRequest
+	var resp response.Response
+
+	// the body is optional, an empty capture settles the full hold
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&captureRequest); err != nil {
+			resp.Status = http.StatusUnprocessableEntity
+			resp.Message = "Invalid request"
+			return c.Status(resp.Status).JSON(resp)
+		}
+	}
+
+	if _, err := handler.validator.CaptureValidate(captureRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	userId := c.Locals("userId").(uuid.UUID)
+
+	amountDecimal := decimal.NewFromFloat(captureRequest.Amount)
+
+	transaction, err := handler.walletService.CaptureHold(userId, c.Params("reference"), amountDecimal)
+	if err != nil {
+		return walletError(c, err)
+	}
+
+	c.Locals("transactionReference", transaction.Reference)
+
+	resp.Status = http.StatusOK
+	resp.Message = "Hold captured successfully"
+	resp.Data = transaction
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) Void(c *fiber.Ctx) error {
+	var resp response.Response
+
+	userId := c.Locals("userId").(uuid.UUID)
+
+	transaction, err := handler.walletService.VoidHold(userId, c.Params("reference"))
+	if err != nil {
 		resp.Status = http.StatusBadRequest
 		resp.Message = err.Error()
 		return c.Status(resp.Status).JSON(resp)
 	}
 
 	resp.Status = http.StatusOK
-	resp.Message = "Withdrawal successful"
+	resp.Message = "Hold voided successfully"
+	resp.Data = transaction
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) Quote(c *fiber.Ctx) error {
+	var quoteRequest request.WalletQuoteRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&quoteRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.QuoteValidate(quoteRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	userId := c.Locals("userId").(uuid.UUID)
+
+	amountDecimal := decimal.NewFromFloat(quoteRequest.Amount)
+
+	quote, err := handler.fxService.Quote(userId, quoteRequest.FromCurrency, quoteRequest.ToCurrency, amountDecimal)
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Quote created successfully"
+	resp.Data = quote
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) Convert(c *fiber.Ctx) error {
+	var convertRequest request.WalletConvertRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&convertRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.ConvertValidate(convertRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	userId := c.Locals("userId").(uuid.UUID)
+
+	transaction, err := handler.walletService.ConvertFunds(userId, convertRequest.QuoteID)
+	if err != nil {
+		return walletError(c, err)
+	}
+
+	c.Locals("transactionReference", transaction.Reference)
+
+	resp.Status = http.StatusOK
+	resp.Message = "Conversion successful"
+	resp.Data = transaction
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) QuoteFee(c *fiber.Ctx) error {
+	var feeRequest request.WalletFeeQuoteRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&feeRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.FeeQuoteValidate(feeRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	amountDecimal := decimal.NewFromFloat(feeRequest.Amount)
+
+	fee, err := handler.feeService.QuoteFee(feeRequest.Operation, feeRequest.Currency, amountDecimal)
+	if err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Fee quoted successfully"
+	resp.Data = fee
+	return c.Status(resp.Status).JSON(resp)
+}
+
+// walletError responds to a failed money movement. Limits and account states that refuse the
+// operation are a 403 with their code, anything else is a bad request.
+func walletError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusBadRequest
+	var limitErr *service.LimitError
+	var stateErr *service.AccountStateError
+	if errors.Is(err, service.ErrIdempotencyKeyMismatch) {
+		resp.Status = http.StatusUnprocessableEntity
+	} else if errors.As(err, &limitErr) {
+		resp.Status = http.StatusForbidden
+		resp.Code = limitErr.Code
+	} else if errors.As(err, &stateErr) {
+		resp.Status = http.StatusForbidden
+		resp.Code = stateErr.Code
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
+
+// authorizeMovement checks the transaction PIN and, above the currency's step-up threshold, the
+// two-factor code of a request that moves money out of the user's wallet.
+func authorizeMovement(c *fiber.Ctx, pinService service.PinServiceInterface, twoFactorService service.TwoFactorServiceInterface, currency string, amount decimal.Decimal, pin string) error {
+	userId := GetUserId(c)
+
+	if err := pinService.VerifyPin(userId, pin, GetClientInfo(c)); err != nil {
+		return err
+	}
+
+	return twoFactorService.VerifyStepUp(userId, currency, amount, c.Get(OtpHeader), GetClientInfo(c))
+}
+
+// authorizationError responds to a failed authorizeMovement, a PIN or second factor problem is a 403 with its code.
+func authorizationError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusInternalServerError
+	var pinErr *service.PinError
+	var stepUpErr *service.StepUpError
+	if errors.As(err, &pinErr) {
+		resp.Status = http.StatusForbidden
+		resp.Code = pinErr.Code
+	} else if errors.As(err, &stepUpErr) {
+		resp.Status = http.StatusForbidden
+		resp.Code = stepUpErr.Code
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) SetPin(c *fiber.Ctx) error {
+	var pinRequest request.WalletSetPinRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&pinRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.SetPinValidate(pinRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.pinService.SetPin(GetUserId(c), pinRequest.Pin, GetClientInfo(c)); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusCreated
+	resp.Message = "Transaction PIN set successfully"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) ChangePin(c *fiber.Ctx) error {
+	var pinRequest request.WalletChangePinRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&pinRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.ChangePinValidate(pinRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.pinService.ChangePin(GetUserId(c), pinRequest.CurrentPin, pinRequest.NewPin, GetClientInfo(c)); err != nil {
+		return authorizationError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Transaction PIN changed successfully"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *walletHandler) ResetPin(c *fiber.Ctx) error {
+	var pinRequest request.WalletResetPinRequest
+	var resp response.Response
+
+	if err := c.BodyParser(&pinRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = "Invalid request"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if _, err := handler.validator.ResetPinValidate(pinRequest); err != nil {
+		resp.Status = http.StatusUnprocessableEntity
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	if err := handler.pinService.ResetPin(GetUserId(c), pinRequest.Password, pinRequest.Code, pinRequest.NewPin, GetClientInfo(c)); err != nil {
+		var pinErr *service.PinError
+		if errors.As(err, &pinErr) {
+			return authorizationError(c, err)
+		}
+
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Transaction PIN reset successfully"
 	return c.Status(resp.Status).JSON(resp)
 }
@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/horlakz/wallet-sync.api/payload/request"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+	"github.com/horlakz/wallet-sync.api/validator"
+)
+
+type webhookHandler struct {
+	webhookService service.WebhookServiceInterface
+	validator      validator.WebhookValidator
+}
+
+type WebhookHandlerInterface interface {
+	Create(c *fiber.Ctx) error
+	List(c *fiber.Ctx) error
+	Get(c *fiber.Ctx) error
+	Update(c *fiber.Ctx) error
+	Delete(c *fiber.Ctx) error
+	Deliveries(c *fiber.Ctx) error
+	Delivery(c *fiber.Ctx) error
+	Redeliver(c *fiber.Ctx) error
+}
+
+func NewWebhookHandler(webhookService service.WebhookServiceInterface) WebhookHandlerInterface {
+	return &webhookHandler{webhookService: webhookService}
+}
+
+func (handler *webhookHandler) Create(c *fiber.Ctx) error {
+	var resp response.Response
+
+	input, ok := handler.parseInput(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	endpoint, err := handler.webhookService.CreateEndpoint(GetUserId(c), input, GetClientInfo(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusCreated
+	resp.Message = "Webhook endpoint created, store the secret now as it will not be shown again"
+	resp.Data = endpoint
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *webhookHandler) List(c *fiber.Ctx) error {
+	var resp response.Response
+
+	endpoints, err := handler.webhookService.GetEndpoints(GetUserId(c))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Webhook endpoints retrieved successfully"
+	resp.Data = endpoints
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *webhookHandler) Get(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return webhookError(c, gorm.ErrRecordNotFound)
+	}
+
+	endpoint, err := handler.webhookService.GetEndpoint(GetUserId(c), id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Webhook endpoint retrieved successfully"
+	resp.Data = endpoint
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *webhookHandler) Update(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return webhookError(c, gorm.ErrRecordNotFound)
+	}
+
+	input, ok := handler.parseInput(c, &resp)
+	if !ok {
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	endpoint, err := handler.webhookService.UpdateEndpoint(GetUserId(c), id, input)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Webhook endpoint updated successfully"
+	resp.Data = endpoint
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *webhookHandler) Delete(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return webhookError(c, gorm.ErrRecordNotFound)
+	}
+
+	if err := handler.webhookService.DeleteEndpoint(GetUserId(c), id, GetClientInfo(c)); err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Webhook endpoint deleted"
+	return c.Status(resp.Status).JSON(resp)
+}
+
+// Deliveries lists the endpoint's latest deliveries, optionally only those with ?status=.
+func (handler *webhookHandler) Deliveries(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return webhookError(c, gorm.ErrRecordNotFound)
+	}
+
+	status := c.Query("status")
+	if status != "" && !slices.Contains([]string{model.WebhookDeliveryPending, model.WebhookDeliveryDelivered, model.WebhookDeliveryDead}, status) {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "status must be pending, delivered or dead"
+		return c.Status(resp.Status).JSON(resp)
+	}
+
+	deliveries, err := handler.webhookService.GetDeliveries(GetUserId(c), id, status)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Webhook deliveries retrieved successfully"
+	resp.Data = deliveries
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *webhookHandler) Delivery(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, deliveryID, ok := deliveryParams(c)
+	if !ok {
+		return webhookError(c, gorm.ErrRecordNotFound)
+	}
+
+	delivery, err := handler.webhookService.GetDelivery(GetUserId(c), id, deliveryID)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusOK
+	resp.Message = "Webhook delivery retrieved successfully"
+	resp.Data = delivery
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func (handler *webhookHandler) Redeliver(c *fiber.Ctx) error {
+	var resp response.Response
+
+	id, deliveryID, ok := deliveryParams(c)
+	if !ok {
+		return webhookError(c, gorm.ErrRecordNotFound)
+	}
+
+	delivery, err := handler.webhookService.Redeliver(GetUserId(c), id, deliveryID)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	resp.Status = http.StatusAccepted
+	resp.Message = "Webhook delivery queued"
+	resp.Data = delivery
+	return c.Status(resp.Status).JSON(resp)
+}
+
+// parseInput reads and validates the endpoint in the body, filling resp when it is invalid.
+func (handler *webhookHandler) parseInput(c *fiber.Ctx, resp *response.Response) (service.WebhookInput, bool) {
+	var webhookRequest request.WebhookRequest
+
+	if err := c.BodyParser(&webhookRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = "Invalid request"
+		return service.WebhookInput{}, false
+	}
+
+	if vEs, err := handler.validator.WebhookValidate(webhookRequest); err != nil {
+		resp.Status = http.StatusBadRequest
+		resp.Message = err.Error()
+		resp.Data = vEs
+		return service.WebhookInput{}, false
+	}
+
+	slices.Sort(webhookRequest.Events)
+
+	return service.WebhookInput{
+		URL:         webhookRequest.URL,
+		Description: webhookRequest.Description,
+		Events:      slices.Compact(webhookRequest.Events),
+		Active:      webhookRequest.Active,
+	}, true
+}
+
+func deliveryParams(c *fiber.Ctx) (uuid.UUID, uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	deliveryID, err := uuid.Parse(c.Params("deliveryId"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return id, deliveryID, true
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	var resp response.Response
+
+	resp.Status = http.StatusBadRequest
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		resp.Status = http.StatusNotFound
+		err = errors.New("webhook endpoint or delivery not found")
+	case errors.Is(err, service.ErrWebhookDeliveryInFlight):
+		resp.Status = http.StatusConflict
+	}
+	resp.Message = err.Error()
+	return c.Status(resp.Status).JSON(resp)
+}
@@ -3,8 +3,8 @@ package config
 import (
 	"bytes"
 	"crypto/tls"
-	"fmt"
 	"html/template"
+	"net"
 	"net/smtp"
 	"strconv"
 )
@@ -18,9 +18,16 @@ type EmailInterface interface {
 type email struct {
 	Host, Username, Password, From string
 	Port                           int
+	// TLS dials the server with implicit TLS, local stand-ins such as MailHog only speak plain SMTP
+	TLS bool
 }
 
+// NewEmail returns the SMTP sender, or an in-memory mailbox when MAIL_DRIVER is "memory".
 func NewEmail(env Env) EmailInterface {
+	if env.MAIL_DRIVER == "memory" {
+		return NewMemoryEmail()
+	}
+
 	port, _ := strconv.Atoi(env.SMTP_PORT)
 
 	return &email{
@@ -29,6 +36,7 @@ func NewEmail(env Env) EmailInterface {
 		Username: env.SMTP_USERNAME,
 		Password: env.SMTP_PASSWORD,
 		From:     env.FROM_EMAIL,
+		TLS:      env.SMTP_TLS != "false",
 	}
 }
 
@@ -41,12 +49,18 @@ func (e *email) Send(to, subject, body string) error {
 		"Content-Type: text/html; charset=utf-8\r\n" +
 		"\r\n" +
 		body)
-	addr := fmt.Sprintf("%s:%d", e.Host, e.Port)
-
-	conn, err := tls.Dial("tcp", addr, &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         e.Host,
-	})
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+
+	var conn net.Conn
+	var err error
+	if e.TLS {
+		conn, err = tls.Dial("tcp", addr, &tls.Config{
+			InsecureSkipVerify: true,
+			ServerName:         e.Host,
+		})
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
 	if err != nil {
 		return err
 	}
@@ -55,10 +69,13 @@ func (e *email) Send(to, subject, body string) error {
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
-	// Authenticating
-	if err = client.Auth(auth); err != nil {
-		return err
+	// Authenticating, skipped for local servers without credentials
+	if e.Username != "" {
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
 	}
 
 	// Setting the sender and recipient
@@ -89,6 +106,10 @@ func (e *email) Send(to, subject, body string) error {
 }
 
 func (e *email) ParseTemplate(templateFile string, data interface{}) (string, error) {
+	return parseTemplate(templateFile, data)
+}
+
+func parseTemplate(templateFile string, data interface{}) (string, error) {
 	t, err := template.ParseFiles(templateFile, "templates/layout.html")
 	if err != nil {
 		return "", err
@@ -0,0 +1,42 @@
+package config
+
+import "sync"
+
+// EmailMessage is an email captured by the in-memory mailbox.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MemoryEmail renders emails like the SMTP sender but keeps them in memory instead of sending them.
+// It is meant for tests and local development.
+type MemoryEmail struct {
+	mu       sync.Mutex
+	messages []EmailMessage
+}
+
+func NewMemoryEmail() *MemoryEmail {
+	return &MemoryEmail{}
+}
+
+func (m *MemoryEmail) SendWithTemplate(to, subject, templateFile string, data interface{}) error {
+	body, err := parseTemplate(templateFile, data)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, EmailMessage{To: to, Subject: subject, Body: body})
+	return nil
+}
+
+// Messages returns the emails sent so far, oldest first.
+func (m *MemoryEmail) Messages() []EmailMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]EmailMessage(nil), m.messages...)
+}
@@ -2,7 +2,6 @@ package config
 
 import (
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -21,18 +20,36 @@ type Env struct {
 	JWT_ACCESS_SECRET  string
 	JWT_REFRESH_SECRET string
 
+	JWT_ALGORITHM          string
+	JWT_KEY_ROTATION_DAYS  string
+	JWT_KEY_ENCRYPTION_KEY string
+
 	FROM_EMAIL    string
 	SMTP_HOST     string
 	SMTP_PORT     string
 	SMTP_USERNAME string
 	SMTP_PASSWORD string
+	SMTP_TLS      string
+	MAIL_DRIVER   string
+
+	APP_URL string
+
+	RABBITMQ_SERVER        string
+	RABBITMQ_EXCHANGE      string
+	RABBITMQ_TOPOLOGY_FILE string
+	RABBITMQ_PREFETCH      string
+	EVENT_DRIVER           string
+
+	FX_RATES_FILE string
+	FX_SPREAD     string
 
-	RABBITMQ_SERVER string
+	STEP_UP_THRESHOLD string
 }
 
 func init() {
+	// without a .env file, as in tests and containers, settings come from the process environment
 	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+		fmt.Println("No .env file loaded, using the process environment")
 	} else {
 		fmt.Println("Loaded .env file")
 	}
@@ -40,20 +57,33 @@ func init() {
 
 func GetEnv() Env {
 	return Env{
-		PORT:               os.Getenv("PORT"),
-		DB_HOST:            os.Getenv("DB_HOST"),
-		DB_USER:            os.Getenv("DB_USER"),
-		DB_PASSWORD:        os.Getenv("DB_PASSWORD"),
-		DB_PORT:            os.Getenv("DB_PORT"),
-		DB_NAME:            os.Getenv("DB_NAME"),
-		REDIS_SERVER:       os.Getenv("REDIS_SERVER"),
-		JWT_ACCESS_SECRET:  os.Getenv("JWT_ACCESS_SECRET"),
-		JWT_REFRESH_SECRET: os.Getenv("JWT_REFRESH_SECRET"),
-		FROM_EMAIL:         os.Getenv("FROM_EMAIL"),
-		SMTP_HOST:          os.Getenv("SMTP_HOST"),
-		SMTP_PORT:          os.Getenv("SMTP_PORT"),
-		SMTP_USERNAME:      os.Getenv("SMTP_USERNAME"),
-		SMTP_PASSWORD:      os.Getenv("SMTP_PASSWORD"),
-		RABBITMQ_SERVER:    os.Getenv("RABBITMQ_SERVER"),
+		PORT:                   os.Getenv("PORT"),
+		DB_HOST:                os.Getenv("DB_HOST"),
+		DB_USER:                os.Getenv("DB_USER"),
+		DB_PASSWORD:            os.Getenv("DB_PASSWORD"),
+		DB_PORT:                os.Getenv("DB_PORT"),
+		DB_NAME:                os.Getenv("DB_NAME"),
+		REDIS_SERVER:           os.Getenv("REDIS_SERVER"),
+		JWT_ACCESS_SECRET:      os.Getenv("JWT_ACCESS_SECRET"),
+		JWT_REFRESH_SECRET:     os.Getenv("JWT_REFRESH_SECRET"),
+		JWT_ALGORITHM:          os.Getenv("JWT_ALGORITHM"),
+		JWT_KEY_ROTATION_DAYS:  os.Getenv("JWT_KEY_ROTATION_DAYS"),
+		JWT_KEY_ENCRYPTION_KEY: os.Getenv("JWT_KEY_ENCRYPTION_KEY"),
+		FROM_EMAIL:             os.Getenv("FROM_EMAIL"),
+		SMTP_HOST:              os.Getenv("SMTP_HOST"),
+		SMTP_PORT:              os.Getenv("SMTP_PORT"),
+		SMTP_USERNAME:          os.Getenv("SMTP_USERNAME"),
+		SMTP_PASSWORD:          os.Getenv("SMTP_PASSWORD"),
+		SMTP_TLS:               os.Getenv("SMTP_TLS"),
+		MAIL_DRIVER:            os.Getenv("MAIL_DRIVER"),
+		APP_URL:                os.Getenv("APP_URL"),
+		RABBITMQ_SERVER:        os.Getenv("RABBITMQ_SERVER"),
+		RABBITMQ_EXCHANGE:      os.Getenv("RABBITMQ_EXCHANGE"),
+		RABBITMQ_TOPOLOGY_FILE: os.Getenv("RABBITMQ_TOPOLOGY_FILE"),
+		RABBITMQ_PREFETCH:      os.Getenv("RABBITMQ_PREFETCH"),
+		EVENT_DRIVER:           os.Getenv("EVENT_DRIVER"),
+		FX_RATES_FILE:          os.Getenv("FX_RATES_FILE"),
+		FX_SPREAD:              os.Getenv("FX_SPREAD"),
+		STEP_UP_THRESHOLD:      os.Getenv("STEP_UP_THRESHOLD"),
 	}
 }
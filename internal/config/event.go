@@ -2,95 +2,575 @@ package config
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// EventInterface is an interface for the Event struct
+// DefaultEventsExchange is the topic exchange wallet events are published to when RABBITMQ_EXCHANGE is empty.
+const DefaultEventsExchange = "wallet.events"
+
+// defaultPrefetch is how many unacknowledged messages a consumer holds when RABBITMQ_PREFETCH is empty.
+const defaultPrefetch = 10
+
+// eventReturnsBuffer is how many returned messages are held until a publish reads them.
+const eventReturnsBuffer = 16
+
+var (
+	eventReconnectDelay    = time.Second
+	eventMaxReconnectDelay = 30 * time.Second
+)
+
+var (
+	ErrEventClosed = errors.New("the event client is closed")
+	// ErrEventUnroutable is returned by Publish when no queue is bound for the message.
+	ErrEventUnroutable = errors.New("no queue is bound for the message")
+)
+
+// EventsExchange returns the exchange wallet events are published to.
+func EventsExchange(env Env) string {
+	if env.RABBITMQ_EXCHANGE == "" {
+		return DefaultEventsExchange
+	}
+	return env.RABBITMQ_EXCHANGE
+}
+
+// EventsEnabled reports whether events are published, to RabbitMQ or to the in-memory broker.
+func EventsEnabled(env Env) bool {
+	return env.RABBITMQ_SERVER != "" || env.EVENT_DRIVER == "memory"
+}
+
+// EventInterface publishes and consumes messages. Exchanges, queues and bindings are declared
+// from the topology when the client connects, and again after every reconnection.
 type EventInterface interface {
+	// Publish sends a persistent JSON message and waits until the broker confirms it, or ctx is done.
+	// A message that no queue is bound for is not confirmed, it fails with ErrEventUnroutable.
 	Publish(ctx context.Context, exchange, key string, msg []byte) error
-	Consume(ctx context.Context, exchange, key string) (<-chan amqp.Delivery, error)
+	// Consume delivers the messages of queue until ctx is done, across reconnections. Every message
+	// must be acked or nacked; messages left unacknowledged when the connection drops are redelivered.
+	Consume(ctx context.Context, queue string) (<-chan Message, error)
+	// DeclareQueue adds a queue to the topology and declares it.
+	DeclareQueue(ctx context.Context, queue QueueConfig) error
+	// BindQueue adds a binding to the topology and declares it.
+	BindQueue(ctx context.Context, queue, exchange, key string) error
+	Close() error
+}
+
+// Message is a message received from a queue.
+type Message struct {
+	Exchange    string
+	RoutingKey  string
+	Body        []byte
+	Redelivered bool
+
+	ack  func() error
+	nack func(requeue bool) error
+}
+
+// Ack tells the broker the message was handled.
+func (m Message) Ack() error {
+	return m.ack()
+}
+
+// Nack tells the broker the message was not handled. With requeue it is delivered again, without
+// it goes to the dead-letter exchange of its queue, or is dropped if the queue has none.
+func (m Message) Nack(requeue bool) error {
+	return m.nack(requeue)
+}
+
+// EventTopology is what the client declares on the broker.
+type EventTopology struct {
+	Exchanges []ExchangeConfig `json:"exchanges"`
+	Queues    []QueueConfig    `json:"queues"`
+	Bindings  []BindingConfig  `json:"bindings"`
+}
+
+// ExchangeConfig is a durable exchange. Kind is direct, fanout, topic or headers.
+type ExchangeConfig struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// QueueConfig is a durable queue. Messages nacked without requeue, or expired after MessageTTLMs,
+// are sent to DeadLetterExchange with DeadLetterRoutingKey, or their own key when it is empty.
+type QueueConfig struct {
+	Name                 string `json:"name"`
+	DeadLetterExchange   string `json:"dead_letter_exchange"`
+	DeadLetterRoutingKey string `json:"dead_letter_routing_key"`
+	MessageTTLMs         int64  `json:"message_ttl_ms"`
+}
+
+// BindingConfig routes the messages of Exchange matching Key to Queue.
+type BindingConfig struct {
+	Queue    string `json:"queue"`
+	Exchange string `json:"exchange"`
+	Key      string `json:"key"`
+}
+
+func (q QueueConfig) arguments() amqp.Table {
+	args := amqp.Table{}
+	if q.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = q.DeadLetterExchange
+	}
+	if q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = q.DeadLetterRoutingKey
+	}
+	if q.MessageTTLMs > 0 {
+		args["x-message-ttl"] = q.MessageTTLMs
+	}
+	return args
+}
+
+// LoadEventTopology reads the topology from RABBITMQ_TOPOLOGY_FILE, when set, and adds the events
+// exchange as a topic exchange if the file does not declare it.
+func LoadEventTopology(env Env) (EventTopology, error) {
+	var topology EventTopology
+
+	if env.RABBITMQ_TOPOLOGY_FILE != "" {
+		data, err := os.ReadFile(env.RABBITMQ_TOPOLOGY_FILE)
+		if err != nil {
+			return EventTopology{}, err
+		}
+		if err := json.Unmarshal(data, &topology); err != nil {
+			return EventTopology{}, fmt.Errorf("invalid RABBITMQ_TOPOLOGY_FILE: %w", err)
+		}
+	}
+
+	exchange := EventsExchange(env)
+	if !slices.ContainsFunc(topology.Exchanges, func(e ExchangeConfig) bool { return e.Name == exchange }) {
+		topology.Exchanges = append(topology.Exchanges, ExchangeConfig{Name: exchange, Kind: amqp.ExchangeTopic})
+	}
+
+	for _, e := range topology.Exchanges {
+		if !slices.Contains([]string{amqp.ExchangeDirect, amqp.ExchangeFanout, amqp.ExchangeTopic, amqp.ExchangeHeaders}, e.Kind) {
+			return EventTopology{}, fmt.Errorf("exchange %q has an unknown kind %q", e.Name, e.Kind)
+		}
+	}
+
+	return topology, nil
+}
+
+// BindsExchange reports whether a queue of the topology is bound to exchange.
+func (t EventTopology) BindsExchange(exchange string) bool {
+	return slices.ContainsFunc(t.Bindings, func(b BindingConfig) bool { return b.Exchange == exchange })
+}
+
+// NewEvent returns the RabbitMQ client, or an in-memory broker when EVENT_DRIVER is "memory".
+// The RabbitMQ client connects in the background and keeps reconnecting, so an unreachable
+// broker is not an error here; Publish waits for the connection instead.
+func NewEvent(env Env) (EventInterface, error) {
+	topology, err := LoadEventTopology(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// the broker returns every event until some queue is bound, which a consumer may still do itself
+	if exchange := EventsExchange(env); !topology.BindsExchange(exchange) {
+		NewLogger().Log().Warnf("No queue is bound to the %q exchange in the topology, events are unroutable until one is", exchange)
+	}
+
+	if env.EVENT_DRIVER == "memory" {
+		return NewMemoryEvent(topology), nil
+	}
+
+	if env.RABBITMQ_SERVER == "" {
+		return nil, errors.New("RABBITMQ_SERVER is not set")
+	}
+
+	prefetch := defaultPrefetch
+	if env.RABBITMQ_PREFETCH != "" {
+		prefetch, err = strconv.Atoi(env.RABBITMQ_PREFETCH)
+		if err != nil || prefetch < 1 {
+			return nil, fmt.Errorf("RABBITMQ_PREFETCH must be a positive number, got %q", env.RABBITMQ_PREFETCH)
+		}
+	}
+
+	e := &event{
+		url:      env.RABBITMQ_SERVER,
+		prefetch: prefetch,
+		topology: topology,
+		logger:   NewLogger(),
+		ready:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go e.run()
+
+	return e, nil
 }
 
-// event is a struct that holds the connection and channel to the RabbitMQ server
+// event is the RabbitMQ client. conn and channel are nil while disconnected; ready is closed
+// once they are set, and replaced when the connection is lost. returns receives the messages of
+// the publishing channel that the broker could not route.
 type event struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
+	url      string
+	prefetch int
+	logger   *Logger
+
+	mu        sync.Mutex
+	topology  EventTopology
+	conn      *amqp.Connection
+	channel   *amqp.Channel
+	returns   <-chan amqp.Return
+	ready     chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// publishMu keeps one publish at a time on the channel, so that each reads its own returns
+	publishMu sync.Mutex
+	published atomic.Uint64
 }
 
-// NewEvent creates a new Event struct
-func NewEvent(env Env) EventInterface {
-	event := &event{}
-	event.init(env)
-	return event
+// run keeps the client connected until Close, reconnecting with a doubling delay.
+func (e *event) run() {
+	delay := eventReconnectDelay
+
+	for {
+		conn, channel, returns, err := e.connect()
+		if err != nil {
+			e.logger.Log().Errorf("RabbitMQ: failed to connect, retrying in %s: %v", delay, err)
+
+			select {
+			case <-e.done:
+				return
+			case <-time.After(delay):
+			}
+
+			delay = min(delay*2, eventMaxReconnectDelay)
+			continue
+		}
+		delay = eventReconnectDelay
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+		e.mu.Lock()
+		e.conn, e.channel, e.returns = conn, channel, returns
+		close(e.ready)
+		e.mu.Unlock()
+
+		e.logger.Log().Info("Connected to RabbitMQ")
+
+		select {
+		case <-e.done:
+			conn.Close()
+			return
+		case err := <-connClosed:
+			e.logger.Log().Warnf("RabbitMQ: connection lost: %v", err)
+		case err := <-channelClosed:
+			// the publishing channel is gone, start over with a fresh connection
+			e.logger.Log().Warnf("RabbitMQ: channel closed: %v", err)
+			conn.Close()
+		}
+
+		e.mu.Lock()
+		e.conn, e.channel, e.returns = nil, nil, nil
+		e.ready = make(chan struct{})
+		e.mu.Unlock()
+	}
+}
+
+// connect dials the broker, declares the topology and opens the publishing channel in confirm mode,
+// listening for the messages it returns as unroutable.
+func (e *event) connect() (*amqp.Connection, *amqp.Channel, <-chan amqp.Return, error) {
+	conn, err := amqp.Dial(e.url)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	e.mu.Lock()
+	topology := e.topology
+	e.mu.Unlock()
+
+	if err := declareTopology(channel, topology); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	returns := channel.NotifyReturn(make(chan amqp.Return, eventReturnsBuffer))
+
+	return conn, channel, returns, nil
 }
 
-// init initiali.Env) zes the connection and channel to the RabbitMQ server
-func (e *event) init(env Env) {
-	conn, err := amqp.Dial(env.RABBITMQ_SERVER)
-	e.FailOnError(err, "Failed to connect")
+func declareTopology(channel *amqp.Channel, topology EventTopology) error {
+	for _, exchange := range topology.Exchanges {
+		if err := channel.ExchangeDeclare(exchange.Name, exchange.Kind, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("declaring exchange %q: %w", exchange.Name, err)
+		}
+	}
+
+	for _, queue := range topology.Queues {
+		if _, err := channel.QueueDeclare(queue.Name, true, false, false, false, queue.arguments()); err != nil {
+			return fmt.Errorf("declaring queue %q: %w", queue.Name, err)
+		}
+	}
+
+	for _, binding := range topology.Bindings {
+		if err := channel.QueueBind(binding.Queue, binding.Key, binding.Exchange, false, nil); err != nil {
+			return fmt.Errorf("binding queue %q to %q: %w", binding.Queue, binding.Exchange, err)
+		}
+	}
 
-	ch, err := conn.Channel()
-	e.FailOnError(err, "Failed to open a channel")
+	return nil
+}
 
-	defer ch.Close()
+// connection waits until the client is connected, ctx is done or the client is closed.
+func (e *event) connection(ctx context.Context) (*amqp.Connection, *amqp.Channel, error) {
+	for {
+		e.mu.Lock()
+		conn, channel, ready := e.conn, e.channel, e.ready
+		e.mu.Unlock()
 
-	log.Println("Connected to RabbitMQ")
+		if conn != nil {
+			return conn, channel, nil
+		}
 
-	e.conn = conn
-	e.channel = ch
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-e.done:
+			return nil, nil, ErrEventClosed
+		}
+	}
 }
 
-// Publish sends a message to the RabbitMQ server
+// Publish sends the message as mandatory, so the broker returns it instead of dropping it when no
+// queue is bound for it. The broker sends the return before the confirm, so once the message is
+// confirmed its return, if any, is already waiting in returns.
 func (e *event) Publish(ctx context.Context, exchange, key string, msg []byte) error {
-	err := e.channel.Publish(exchange, key, false, false, amqp.Publishing{
-		ContentType: "text/plain",
-		Body:        msg,
+	e.publishMu.Lock()
+	defer e.publishMu.Unlock()
+
+	channel, returns, err := e.publisher(ctx)
+	if err != nil {
+		return err
+	}
+
+	// returns of earlier publishes that gave up before their confirm
+	returned(returns, "")
+
+	messageID := strconv.FormatUint(e.published.Add(1), 10)
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx, exchange, key, true, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    messageID,
+		Body:         msg,
 	})
 	if err != nil {
 		return err
 	}
 
+	// a channel closed before the confirm arrives nacks the message
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return errors.New("the broker did not accept the message")
+	}
+
+	if returned(returns, messageID) {
+		return fmt.Errorf("%w: %q on exchange %q", ErrEventUnroutable, key, exchange)
+	}
+
 	return nil
 }
 
-// Consume receives a message from the RabbitMQ server
-func (e *event) Consume(ctx context.Context, exchange, key string) (<-chan amqp.Delivery, error) {
-	msgs, err := e.channel.Consume(
-		key,
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+// publisher returns the publishing channel and its returns, taken from the same connection.
+func (e *event) publisher(ctx context.Context) (*amqp.Channel, <-chan amqp.Return, error) {
+	for {
+		_, channel, err := e.connection(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		e.mu.Lock()
+		current, returns := e.channel, e.returns
+		e.mu.Unlock()
+
+		if current == channel {
+			return channel, returns, nil
+		}
+	}
+}
+
+// returned reads the messages waiting in returns and reports whether one of them is messageID.
+func returned(returns <-chan amqp.Return, messageID string) bool {
+	found := false
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				return found
+			}
+			if messageID != "" && ret.MessageId == messageID {
+				found = true
+			}
+		default:
+			return found
+		}
+	}
+}
+
+func (e *event) Consume(ctx context.Context, queue string) (<-chan Message, error) {
+	select {
+	case <-e.done:
+		return nil, ErrEventClosed
+	default:
+	}
+
+	messages := make(chan Message)
+	go e.consume(ctx, queue, messages)
+
+	return messages, nil
+}
+
+// consume forwards the deliveries of queue to messages, opening a new channel after every
+// reconnection, and closes messages once ctx is done or the client is closed.
+func (e *event) consume(ctx context.Context, queue string, messages chan<- Message) {
+	defer close(messages)
+
+	for {
+		conn, _, err := e.connection(ctx)
+		if err != nil {
+			return
+		}
+
+		channel, deliveries, err := e.subscribe(ctx, conn, queue)
+		if err != nil {
+			e.logger.Log().Errorf("RabbitMQ: failed to consume %q, retrying in %s: %v", queue, eventReconnectDelay, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.done:
+				return
+			case <-time.After(eventReconnectDelay):
+			}
+			continue
+		}
+
+		for delivery := range deliveries {
+			select {
+			case messages <- toMessage(delivery):
+			case <-ctx.Done():
+				// closing the channel requeues the delivery
+				channel.Close()
+				return
+			}
+		}
+
+		// deliveries end when ctx is done or the channel or connection is gone
+		channel.Close()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (e *event) subscribe(ctx context.Context, conn *amqp.Connection, queue string) (*amqp.Channel, <-chan amqp.Delivery, error) {
+	channel, err := conn.Channel()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err := channel.Qos(e.prefetch, 0, false); err != nil {
+		channel.Close()
+		return nil, nil, err
+	}
+
+	deliveries, err := channel.ConsumeWithContext(ctx, queue, "", false, false, false, false, nil)
+	if err != nil {
+		channel.Close()
+		return nil, nil, err
 	}
 
-	return msgs, nil
+	return channel, deliveries, nil
 }
 
-func (e *event) DeclareQueue(name string) (amqp.Queue, error) {
-	q, err := e.channel.QueueDeclare(
-		name,
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+func toMessage(delivery amqp.Delivery) Message {
+	return Message{
+		Exchange:    delivery.Exchange,
+		RoutingKey:  delivery.RoutingKey,
+		Body:        delivery.Body,
+		Redelivered: delivery.Redelivered,
+		ack:         func() error { return delivery.Ack(false) },
+		nack:        func(requeue bool) error { return delivery.Nack(false, requeue) },
+	}
+}
 
-	e.FailOnError(err, "Failed to declare a queue")
+func (e *event) DeclareQueue(ctx context.Context, queue QueueConfig) error {
+	e.mu.Lock()
+	e.topology.Queues = append(slices.DeleteFunc(e.topology.Queues, func(q QueueConfig) bool { return q.Name == queue.Name }), queue)
+	e.mu.Unlock()
 
-	return q, nil
+	return e.declare(ctx, func(channel *amqp.Channel) error {
+		_, err := channel.QueueDeclare(queue.Name, true, false, false, false, queue.arguments())
+		return err
+	})
 }
 
-// FailOnError logs a fatal error if the given error is not nil
-func (e *event) FailOnError(err error, msg string) {
+func (e *event) BindQueue(ctx context.Context, queue, exchange, key string) error {
+	binding := BindingConfig{Queue: queue, Exchange: exchange, Key: key}
+
+	e.mu.Lock()
+	if !slices.Contains(e.topology.Bindings, binding) {
+		e.topology.Bindings = append(e.topology.Bindings, binding)
+	}
+	e.mu.Unlock()
+
+	return e.declare(ctx, func(channel *amqp.Channel) error {
+		return channel.QueueBind(queue, key, exchange, false, nil)
+	})
+}
+
+// declare runs fn on a channel of its own, as a failed declaration closes the channel it was made on.
+func (e *event) declare(ctx context.Context, fn func(channel *amqp.Channel) error) error {
+	conn, _, err := e.connection(ctx)
 	if err != nil {
-		log.Fatalf("RabbitMQ: %s: %s", msg, err)
+		return err
 	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	return fn(channel)
+}
+
+// Close disconnects from the broker and stops reconnecting. Consumers are closed with the connection.
+func (e *event) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		return e.conn.Close()
+	}
+	return nil
 }
@@ -0,0 +1,312 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// PublishedMessage is a message accepted by the in-memory broker.
+type PublishedMessage struct {
+	Exchange   string
+	RoutingKey string
+	Body       []byte
+}
+
+// MemoryEvent routes messages like RabbitMQ does, with direct, fanout and topic exchanges, acks,
+// requeues and dead-lettering, but keeps everything in memory. It is meant for tests and local development.
+type MemoryEvent struct {
+	mu        sync.Mutex
+	exchanges map[string]string
+	queues    map[string]*memoryQueue
+	bindings  []BindingConfig
+	published []PublishedMessage
+	closed    bool
+}
+
+type memoryQueue struct {
+	config   QueueConfig
+	messages []Message
+	// wake is closed when a message is added, and replaced by the consumer that sees it
+	wake chan struct{}
+}
+
+func NewMemoryEvent(topology EventTopology) *MemoryEvent {
+	m := &MemoryEvent{
+		exchanges: map[string]string{},
+		queues:    map[string]*memoryQueue{},
+	}
+
+	for _, exchange := range topology.Exchanges {
+		m.exchanges[exchange.Name] = exchange.Kind
+	}
+	for _, queue := range topology.Queues {
+		m.queues[queue.Name] = &memoryQueue{config: queue, wake: make(chan struct{})}
+	}
+	m.bindings = append(m.bindings, topology.Bindings...)
+
+	return m
+}
+
+func (m *MemoryEvent) Publish(ctx context.Context, exchange, key string, msg []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrEventClosed
+	}
+
+	routed, err := m.route(exchange, key, msg, false)
+	if err != nil {
+		return err
+	}
+	if !routed {
+		return fmt.Errorf("%w: %q on exchange %q", ErrEventUnroutable, key, exchange)
+	}
+
+	m.published = append(m.published, PublishedMessage{Exchange: exchange, RoutingKey: key, Body: msg})
+	return nil
+}
+
+// route adds the message to every queue bound to exchange with a matching key and reports whether
+// there was one. The default exchange "" routes to the queue named by the key.
+func (m *MemoryEvent) route(exchange, key string, body []byte, redelivered bool) (bool, error) {
+	if exchange == "" {
+		queue, ok := m.queues[key]
+		if ok {
+			m.enqueue(queue, Message{Exchange: exchange, RoutingKey: key, Body: body, Redelivered: redelivered})
+		}
+		return ok, nil
+	}
+
+	kind, ok := m.exchanges[exchange]
+	if !ok {
+		return false, fmt.Errorf("exchange %q is not declared", exchange)
+	}
+
+	routed := false
+	for _, binding := range m.bindings {
+		if binding.Exchange != exchange || !bindingMatches(kind, binding.Key, key) {
+			continue
+		}
+		if queue, ok := m.queues[binding.Queue]; ok {
+			m.enqueue(queue, Message{Exchange: exchange, RoutingKey: key, Body: body, Redelivered: redelivered})
+			routed = true
+		}
+	}
+
+	return routed, nil
+}
+
+func (m *MemoryEvent) enqueue(queue *memoryQueue, msg Message) {
+	queue.messages = append(queue.messages, msg)
+	m.wakeUp(queue)
+}
+
+func (m *MemoryEvent) wakeUp(queue *memoryQueue) {
+	close(queue.wake)
+	queue.wake = make(chan struct{})
+}
+
+func (m *MemoryEvent) Consume(ctx context.Context, queue string) (<-chan Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrEventClosed
+	}
+
+	q, ok := m.queues[queue]
+	if !ok {
+		return nil, fmt.Errorf("queue %q is not declared", queue)
+	}
+
+	messages := make(chan Message)
+	go m.consume(ctx, q, messages)
+
+	return messages, nil
+}
+
+func (m *MemoryEvent) consume(ctx context.Context, queue *memoryQueue, messages chan<- Message) {
+	defer close(messages)
+
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return
+		}
+		if len(queue.messages) == 0 {
+			wake := queue.wake
+			m.mu.Unlock()
+
+			select {
+			case <-wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		msg := queue.messages[0]
+		queue.messages = queue.messages[1:]
+		m.mu.Unlock()
+
+		msg = m.withAcknowledgement(queue, msg)
+
+		select {
+		case messages <- msg:
+		case <-ctx.Done():
+			// an undelivered message goes back to the front, as if the consumer had been cancelled
+			m.mu.Lock()
+			queue.messages = append([]Message{msg}, queue.messages...)
+			m.wakeUp(queue)
+			m.mu.Unlock()
+			return
+		}
+	}
+}
+
+// withAcknowledgement gives msg ack and nack functions that can be called once between them.
+func (m *MemoryEvent) withAcknowledgement(queue *memoryQueue, msg Message) Message {
+	var once sync.Once
+	settle := func(fn func()) error {
+		err := errors.New("the message was already acknowledged")
+		once.Do(func() {
+			err = nil
+			fn()
+		})
+		return err
+	}
+
+	body, exchange, key := msg.Body, msg.Exchange, msg.RoutingKey
+
+	msg.ack = func() error {
+		return settle(func() {})
+	}
+	msg.nack = func(requeue bool) error {
+		return settle(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			if requeue {
+				m.enqueue(queue, Message{Exchange: exchange, RoutingKey: key, Body: body, Redelivered: true})
+				return
+			}
+
+			if queue.config.DeadLetterExchange != "" {
+				if queue.config.DeadLetterRoutingKey != "" {
+					key = queue.config.DeadLetterRoutingKey
+				}
+				// RabbitMQ drops dead letters it cannot route, and so does this
+				_, _ = m.route(queue.config.DeadLetterExchange, key, body, false)
+			}
+		})
+	}
+
+	return msg
+}
+
+func (m *MemoryEvent) DeclareQueue(ctx context.Context, queue QueueConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.queues[queue.Name]; ok {
+		existing.config = queue
+		return nil
+	}
+
+	m.queues[queue.Name] = &memoryQueue{config: queue, wake: make(chan struct{})}
+	return nil
+}
+
+func (m *MemoryEvent) BindQueue(ctx context.Context, queue, exchange, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.queues[queue]; !ok {
+		return fmt.Errorf("queue %q is not declared", queue)
+	}
+	if _, ok := m.exchanges[exchange]; !ok {
+		return fmt.Errorf("exchange %q is not declared", exchange)
+	}
+
+	binding := BindingConfig{Queue: queue, Exchange: exchange, Key: key}
+	if !slices.Contains(m.bindings, binding) {
+		m.bindings = append(m.bindings, binding)
+	}
+	return nil
+}
+
+// Close stops the consumers. Messages left in the queues are kept.
+func (m *MemoryEvent) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.closed {
+		m.closed = true
+		for _, queue := range m.queues {
+			m.wakeUp(queue)
+		}
+	}
+	return nil
+}
+
+// Published returns the messages published so far, oldest first.
+func (m *MemoryEvent) Published() []PublishedMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]PublishedMessage(nil), m.published...)
+}
+
+// Pending returns how many messages wait in queue to be consumed.
+func (m *MemoryEvent) Pending(queue string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if q, ok := m.queues[queue]; ok {
+		return len(q.messages)
+	}
+	return 0
+}
+
+func bindingMatches(kind, pattern, key string) bool {
+	switch kind {
+	case "fanout":
+		return true
+	case "topic":
+		return topicMatches(strings.Split(pattern, "."), strings.Split(key, "."))
+	default:
+		return pattern == key
+	}
+}
+
+// topicMatches matches a routing key against a topic pattern, where * stands for exactly one word
+// and # for zero or more.
+func topicMatches(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if topicMatches(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && topicMatches(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && pattern[0] == key[0] && topicMatches(pattern[1:], key[1:])
+	}
+}
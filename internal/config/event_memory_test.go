@@ -0,0 +1,159 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func testTopology() EventTopology {
+	return EventTopology{
+		Exchanges: []ExchangeConfig{
+			{Name: "wallet.events", Kind: "topic"},
+			{Name: "wallet.broadcast", Kind: "fanout"},
+			{Name: "wallet.dlx", Kind: "fanout"},
+		},
+		Queues: []QueueConfig{
+			{Name: "transfers", DeadLetterExchange: "wallet.dlx"},
+			{Name: "all", DeadLetterExchange: "wallet.dlx"},
+			{Name: "audit"},
+			{Name: "dead"},
+		},
+		Bindings: []BindingConfig{
+			{Queue: "transfers", Exchange: "wallet.events", Key: "wallet.transfer.*"},
+			{Queue: "all", Exchange: "wallet.events", Key: "wallet.#"},
+			{Queue: "all", Exchange: "wallet.broadcast"},
+			{Queue: "audit", Exchange: "wallet.broadcast"},
+			{Queue: "dead", Exchange: "wallet.dlx"},
+		},
+	}
+}
+
+// receive waits for the next message of messages.
+func receive(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("the consumer was closed")
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message was delivered")
+	}
+	return Message{}
+}
+
+func TestMemoryEventRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		exchange string
+		key      string
+		want     map[string]int
+	}{
+		{name: "topic one word", exchange: "wallet.events", key: "wallet.transfer.completed", want: map[string]int{"transfers": 1, "all": 1}},
+		{name: "topic several words", exchange: "wallet.events", key: "wallet.funded", want: map[string]int{"all": 1}},
+		{name: "fanout ignores the key", exchange: "wallet.broadcast", key: "anything", want: map[string]int{"all": 1, "audit": 1}},
+		{name: "default exchange", exchange: "", key: "audit", want: map[string]int{"audit": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := NewMemoryEvent(testTopology())
+
+			if err := events.Publish(context.Background(), tt.exchange, tt.key, []byte(`{}`)); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, queue := range []string{"transfers", "all", "audit", "dead"} {
+				if got := events.Pending(queue); got != tt.want[queue] {
+					t.Errorf("%s has %d messages, want %d", queue, got, tt.want[queue])
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryEventUnroutable(t *testing.T) {
+	events := NewMemoryEvent(testTopology())
+
+	err := events.Publish(context.Background(), "wallet.events", "user.created", []byte(`{}`))
+	if !errors.Is(err, ErrEventUnroutable) {
+		t.Errorf("Publish = %v, want %v", err, ErrEventUnroutable)
+	}
+	if len(events.Published()) != 0 {
+		t.Error("an unroutable message was recorded as published")
+	}
+}
+
+func TestMemoryEventNack(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := NewMemoryEvent(testTopology())
+	messages, err := events.Consume(ctx, "transfers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := events.Publish(ctx, "wallet.events", "wallet.transfer.completed", []byte(`{"n":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// requeued, the message comes back marked as redelivered
+	msg := receive(t, messages)
+	if msg.Redelivered {
+		t.Error("the first delivery is marked as redelivered")
+	}
+	if err := msg.Nack(true); err != nil {
+		t.Fatal(err)
+	}
+
+	msg = receive(t, messages)
+	if !msg.Redelivered || string(msg.Body) != `{"n":1}` {
+		t.Errorf("redelivery = %+v", msg)
+	}
+
+	// rejected, it goes to the dead-letter exchange of the queue
+	if err := msg.Nack(false); err != nil {
+		t.Fatal(err)
+	}
+	if got := events.Pending("dead"); got != 1 {
+		t.Errorf("dead has %d messages, want 1", got)
+	}
+
+	if err := msg.Ack(); err == nil {
+		t.Error("a settled message was acknowledged again")
+	}
+}
+
+func TestMemoryEventConsumeStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := NewMemoryEvent(testTopology())
+
+	messages, err := events.Consume(ctx, "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Error("a message was delivered after the context was cancelled")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the consumer was not closed")
+	}
+
+	// what the cancelled consumer did not take waits for the next one
+	if err := events.Publish(context.Background(), "", "audit", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got := events.Pending("audit"); got != 1 {
+		t.Errorf("audit has %d messages, want 1", got)
+	}
+}
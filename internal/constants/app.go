@@ -2,4 +2,8 @@ package constants
 
 const (
 	APP_URL = "https://api.thryvo.buimas.com/v1"
+
+	// DEFAULT_CURRENCY is the currency of the wallet opened on registration and of
+	// wallet requests that do not name a currency.
+	DEFAULT_CURRENCY = "NGN"
 )
@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedPrefix marks a value sealed by Seal, so it can be told apart from one stored in the clear.
+const sealedPrefix = "aesgcm:v1:"
+
+var ErrNotSealed = errors.New("value is not sealed")
+
+// ParseEncryptionKey decodes a base64 AES-256 key.
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("the encryption key must be 32 bytes, base64 encoded")
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with AES-GCM under key. additionalData is authenticated but not encrypted,
+// and must be given again to Open.
+func Seal(key []byte, plaintext string, additionalData string) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value made by Seal. It returns ErrNotSealed for a value that was never sealed.
+func Open(key []byte, value string, additionalData string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return "", ErrNotSealed
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("sealed value is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt sealed value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
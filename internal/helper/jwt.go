@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -15,15 +14,38 @@ import (
 )
 
 type TokenType struct {
-	name   string
-	exp    int
-	secret string
+	name string
+	exp  time.Duration
+}
+
+// tokenTypes lists every kind of token and how long it stays valid. Unknown types are access tokens.
+var tokenTypes = map[string]TokenType{
+	"access":         {"access", time.Hour},
+	"refresh":        {"refresh", 168 * time.Hour},
+	"verify_email":   {"verify_email", 24 * time.Hour},
+	"reset_password": {"reset_password", time.Hour},
+	"mfa":            {"mfa", 5 * time.Minute},
+	"change_email":   {"change_email", 24 * time.Hour},
+	"unlock_account": {"unlock_account", 30 * time.Minute},
+}
+
+// LongestTokenLifetime is how long the longest lived token stays valid, and so how long a retired
+// signing key must still be accepted.
+func LongestTokenLifetime() time.Duration {
+	var longest time.Duration
+	for _, tokenType := range tokenTypes {
+		longest = max(longest, tokenType.exp)
+	}
+	return longest
 }
 
 type JwtInterface interface {
 	CreateToken(userID string, tokenType string) (string, error)
+	CreateTokenWithClaims(userID string, tokenType string, extra map[string]interface{}) (string, error)
 	ExtractUserID(token string, tokenType string) (uuid.UUID, error)
+	ExtractClaims(token string, tokenType string) (jwt.MapClaims, error)
 	ExtractBearerToken(r *fasthttp.Request) string
+	TokenLifetime(tokenType string) time.Duration
 }
 
 type auth struct{}
@@ -33,29 +55,46 @@ func NewJwt() JwtInterface {
 }
 
 func (a *auth) CheckTokenType(tokenType string) TokenType {
-	accessTokenType := TokenType{"access", 1, os.Getenv("JWT_ACCESS_SECRET")}
-
-	switch tokenType {
-	case "access":
-		return accessTokenType
-	case "refresh":
-		return TokenType{"refresh", 168, os.Getenv("JWT_REFRESH_SECRET")}
-	default:
-		return accessTokenType
+	if tType, ok := tokenTypes[tokenType]; ok {
+		return tType
 	}
+	return tokenTypes["access"]
+}
+
+// TokenLifetime is how long a token of the given type stays valid.
+func (a *auth) TokenLifetime(tokenType string) time.Duration {
+	return a.CheckTokenType(tokenType).exp
 }
 
 func (a *auth) CreateToken(userId string, tokenType string) (string, error) {
+	return a.CreateTokenWithClaims(userId, tokenType, nil)
+}
+
+// CreateTokenWithClaims signs a token carrying extra claims, such as the session and token IDs.
+// Standard claims set here take precedence over extra.
+func (a *auth) CreateTokenWithClaims(userId string, tokenType string, extra map[string]interface{}) (string, error) {
 	tType := a.CheckTokenType(tokenType)
 
-	token := jwt.New(jwt.SigningMethodHS256)
+	signingKey, err := currentKeySet().SigningKey(tType.name)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.New(signingKey.Method)
+	if signingKey.ID != "" {
+		token.Header["kid"] = signingKey.ID
+	}
 	claims := token.Claims.(jwt.MapClaims)
+	for key, value := range extra {
+		claims[key] = value
+	}
 	claims["sub"] = userId
+	claims["typ"] = tType.name
 	claims["iat"] = time.Now().Unix()
 	claims["ver"] = 1
-	claims["exp"] = time.Now().Add(time.Hour * time.Duration(tType.exp)).Unix()
+	claims["exp"] = time.Now().Add(tType.exp).Unix()
 
-	_token, err := token.SignedString([]byte(tType.secret))
+	_token, err := token.SignedString(signingKey.Key)
 
 	if err != nil {
 		return "", err
@@ -65,15 +104,11 @@ func (a *auth) CreateToken(userId string, tokenType string) (string, error) {
 }
 
 func (a *auth) ExtractUserID(token string, tokenType string) (uid uuid.UUID, err error) {
-	tType := a.CheckTokenType(tokenType)
-	tokenObj, err := a.ExtractTokenObject(token, tType.secret)
-
+	claims, err := a.ExtractClaims(token, tokenType)
 	if err != nil {
 		return uuid.Nil, err
 	}
 
-	claims := tokenObj.Claims.(jwt.MapClaims)
-
 	if claims["sub"] == nil {
 		return uuid.Nil, errors.New("invalid token: user id not found")
 	}
@@ -82,6 +117,25 @@ func (a *auth) ExtractUserID(token string, tokenType string) (uid uuid.UUID, err
 	return uuid.Parse(userID)
 }
 
+// ExtractClaims verifies the token and returns its claims. Tokens that do not name the expected
+// type are rejected, so a refresh token cannot be used as an access token.
+func (a *auth) ExtractClaims(token string, tokenType string) (jwt.MapClaims, error) {
+	tType := a.CheckTokenType(tokenType)
+	tokenObj, err := a.ExtractTokenObject(token, tType.name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims := tokenObj.Claims.(jwt.MapClaims)
+
+	if typ, _ := claims["typ"].(string); typ != tType.name {
+		return nil, errors.New("invalid token: unexpected token type")
+	}
+
+	return claims, nil
+}
+
 func (a *auth) ExtractBearerToken(r *fasthttp.Request) string {
 	keys := r.URI().QueryArgs()
 	token := string(keys.Peek("token"))
@@ -97,12 +151,12 @@ func (a *auth) ExtractBearerToken(r *fasthttp.Request) string {
 	return ""
 }
 
-func (a *auth) ExtractTokenObject(tokenString string, secret string) (*jwt.Token, error) {
+// ExtractTokenObject verifies a token of tokenType with the key its kid and alg headers name.
+// The key decides the algorithm, so a token cannot switch to one the key was not made for.
+func (a *auth) ExtractTokenObject(tokenString string, tokenType string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
+		kid, _ := token.Header["kid"].(string)
+		return currentKeySet().VerificationKey(tokenType, kid, token.Method.Alg())
 	})
 
 	if err != nil {
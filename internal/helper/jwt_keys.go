@@ -0,0 +1,197 @@
+package helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Algorithms tokens can be signed with. HS256 shares one secret per token family between signer
+// and verifier, RS256 and EdDSA let other services verify tokens with the published public keys.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+var ErrUnknownSigningKey = errors.New("invalid token: unknown signing key")
+
+// SigningKey is the key new tokens are signed with. ID goes into the kid header, HMAC keys have none.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Key    interface{}
+}
+
+// KeySet provides the keys tokens are signed and verified with.
+type KeySet interface {
+	SigningKey(tokenType string) (SigningKey, error)
+	// VerificationKey returns the key for the kid and alg headers of a token of tokenType.
+	VerificationKey(tokenType string, kid string, alg string) (interface{}, error)
+}
+
+var (
+	keySetMu sync.RWMutex
+	keySet   KeySet
+)
+
+// UseKeySet sets the keys every JwtInterface signs and verifies with. It is called once at start up;
+// until then tokens use HS256 with JWT_ACCESS_SECRET and JWT_REFRESH_SECRET.
+func UseKeySet(keys KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	keySet = keys
+}
+
+func currentKeySet() KeySet {
+	keySetMu.RLock()
+	keys := keySet
+	keySetMu.RUnlock()
+
+	if keys != nil {
+		return keys
+	}
+
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+
+	if keySet == nil {
+		keySet = NewHMACKeySet(os.Getenv("JWT_ACCESS_SECRET"), os.Getenv("JWT_REFRESH_SECRET"))
+	}
+	return keySet
+}
+
+type hmacKeySet struct {
+	accessSecret  []byte
+	refreshSecret []byte
+}
+
+// NewHMACKeySet signs refresh tokens with refreshSecret and every other token with accessSecret.
+func NewHMACKeySet(accessSecret string, refreshSecret string) KeySet {
+	return &hmacKeySet{accessSecret: []byte(accessSecret), refreshSecret: []byte(refreshSecret)}
+}
+
+func (k *hmacKeySet) SigningKey(tokenType string) (SigningKey, error) {
+	return SigningKey{Method: jwt.SigningMethodHS256, Key: k.secret(tokenType)}, nil
+}
+
+func (k *hmacKeySet) VerificationKey(tokenType string, kid string, alg string) (interface{}, error) {
+	if alg != AlgorithmHS256 {
+		return nil, fmt.Errorf("unexpected signing method: %v", alg)
+	}
+	return k.secret(tokenType), nil
+}
+
+func (k *hmacKeySet) secret(tokenType string) []byte {
+	if tokenType == "refresh" {
+		return k.refreshSecret
+	}
+	return k.accessSecret
+}
+
+// SigningMethod returns the JWT signing method of an algorithm.
+func SigningMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case AlgorithmHS256:
+		return jwt.SigningMethodHS256, nil
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", alg)
+	}
+}
+
+// GenerateSigningKey creates a private key for an asymmetric algorithm, PEM encoded as PKCS #8.
+func GenerateSigningKey(alg string) (string, error) {
+	var privateKey interface{}
+	var err error
+
+	switch alg {
+	case AlgorithmRS256:
+		privateKey, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("cannot generate a key for JWT algorithm %q", alg)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseSigningKey decodes a PEM private key made by GenerateSigningKey and checks it suits alg.
+func ParseSigningKey(alg string, pemKey string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if alg == AlgorithmRS256 {
+			return key, nil
+		}
+	case ed25519.PrivateKey:
+		if alg == AlgorithmEdDSA {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("signing key does not match JWT algorithm %q", alg)
+}
+
+// PublicJWK describes the public half of a signing key as a JSON Web Key (RFC 7517).
+func PublicJWK(kid string, alg string, key crypto.Signer) (map[string]interface{}, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch public := key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]interface{}{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": alg,
+			"kid": kid,
+			"n":   encode(public.N.Bytes()),
+			"e":   encode(big.NewInt(int64(public.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return map[string]interface{}{
+			"kty": "OKP",
+			"use": "sig",
+			"alg": alg,
+			"kid": kid,
+			"crv": "Ed25519",
+			"x":   encode(public),
+		}, nil
+	default:
+		return nil, errors.New("unsupported signing key type")
+	}
+}
@@ -0,0 +1,67 @@
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// signClaims signs claims as they are, with the key of tokenType.
+func signClaims(t *testing.T, tokenType string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	signingKey, err := currentKeySet().SigningKey(tokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.NewWithClaims(signingKey.Method, claims).SignedString(signingKey.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestExtractClaimsChecksTokenType(t *testing.T) {
+	UseKeySet(NewHMACKeySet("test-secret", "test-secret"))
+	t.Cleanup(func() { UseKeySet(nil) })
+
+	userID := uuid.NewString()
+	claims := func(typ string) jwt.MapClaims {
+		claims := jwt.MapClaims{"sub": userID, "exp": time.Now().Add(time.Hour).Unix()}
+		if typ != "" {
+			claims["typ"] = typ
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "expected type", token: signClaims(t, "access", claims("access"))},
+		{name: "other type", token: signClaims(t, "access", claims("refresh")), wantErr: true},
+		{name: "no type", token: signClaims(t, "access", claims("")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewJwt().ExtractClaims(tt.token, "access")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ExtractClaims accepted a token with typ %v", got["typ"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got["sub"] != userID {
+				t.Errorf("sub = %v, want %s", got["sub"], userID)
+			}
+		})
+	}
+}
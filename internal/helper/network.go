@@ -0,0 +1,33 @@
+package helper
+
+import "net/netip"
+
+// nonPublicPrefixes are the ranges that are not reachable on the public internet and are not
+// covered by the netip.Addr predicates: carrier-grade NAT, IETF protocol assignments,
+// benchmarking, the reserved class E range and NAT64.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// IsPublicIP reports whether addr is a public unicast address, so not a loopback, private,
+// link-local, multicast or otherwise reserved one.
+func IsPublicIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,11 +1,15 @@
 package job
 
 import (
+	"context"
+	"log"
+
 	"github.com/robfig/cron/v3"
 
 	"github.com/horlakz/wallet-sync.api/internal/config"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
 	"github.com/horlakz/wallet-sync.api/service"
 )
 
@@ -13,17 +17,52 @@ type CronService struct {
 	cron                  *cron.Cron
 	logger                *config.Logger
 	reconciliationService service.ReconciliationService
+	idempotencyService    service.IdempotencyServiceInterface
+	walletService         service.WalletServiceInterface
+	scheduleService       service.ScheduleServiceInterface
+	signingKeyService     service.SigningKeyServiceInterface
+	webhookService        service.WebhookServiceInterface
+	outboxRelay           service.OutboxRelayInterface
+	// relayEvents is false without RABBITMQ_SERVER or EVENT_DRIVER=memory, events then stay in the outbox
+	relayEvents bool
 }
 
 type CronServiceInterface interface {
 	Start()
 }
 
-func NewCronService(db database.DatabaseInterface) CronServiceInterface {
+func NewCronService(db database.DatabaseInterface, env config.Env) CronServiceInterface {
 	ledgerEntryRepo := core_repository.NewLedgerEntryRepository(db)
 	transactionRepo := core_repository.NewTransactionRepository(db)
 	accountRepo := core_repository.NewAccountRepository(db)
 	reconciliationLogRepo := core_repository.NewReconciliationLogRepository(db)
+	idempotencyKeyRepo := core_repository.NewIdempotencyKeyRepository(db)
+	holdRepo := core_repository.NewHoldRepository(db)
+	webhookRepo := core_repository.NewWebhookRepository(db)
+	outboxRepo := core_repository.NewOutboxRepository(db)
+	feeRuleRepo := core_repository.NewFeeRuleRepository(db)
+	limitRuleRepo := core_repository.NewLimitRuleRepository(db)
+	userRepo := user_repository.NewUserRepository(db)
+	scheduledTransferRepo := core_repository.NewScheduledTransferRepository(db)
+	signingKeyRepo := core_repository.NewSigningKeyRepository(db)
+
+	signingKeyConfig, err := service.SigningKeyConfigFromEnv(env)
+	if err != nil {
+		log.Fatalf("Invalid JWT signing configuration: %v", err)
+	}
+
+	walletService := service.NewWalletService(
+		accountRepo,
+		transactionRepo,
+		ledgerEntryRepo,
+		holdRepo,
+		webhookRepo,
+		outboxRepo,
+		service.NewFeeService(feeRuleRepo),
+		service.NewLimitService(limitRuleRepo, transactionRepo, userRepo),
+		service.NewAuditService(core_repository.NewAuditLogRepository(db)),
+		db,
+	)
 
 	reconciliationService := service.NewReconciliationService(
 		ledgerEntryRepo,
@@ -32,10 +71,26 @@ func NewCronService(db database.DatabaseInterface) CronServiceInterface {
 		reconciliationLogRepo,
 	)
 
+	// Events are published once RabbitMQ is configured
+	var events config.EventInterface
+	if config.EventsEnabled(env) {
+		events, err = config.NewEvent(env)
+		if err != nil {
+			log.Fatalf("Invalid RabbitMQ configuration: %v", err)
+		}
+	}
+
 	return &CronService{
 		cron:                  cron.New(cron.WithSeconds()),
 		logger:                config.NewLogger(),
 		reconciliationService: reconciliationService,
+		idempotencyService:    service.NewIdempotencyService(idempotencyKeyRepo),
+		walletService:         walletService,
+		scheduleService:       service.NewScheduleService(scheduledTransferRepo, accountRepo, walletService),
+		signingKeyService:     service.NewSigningKeyService(signingKeyRepo, db.Cache(), signingKeyConfig),
+		webhookService:        service.NewWebhookService(webhookRepo, service.NewAuditService(core_repository.NewAuditLogRepository(db))),
+		outboxRelay:           service.NewOutboxRelay(outboxRepo, events, config.EventsExchange(env)),
+		relayEvents:           events != nil,
 	}
 }
 
@@ -49,6 +104,71 @@ func (c *CronService) Start() {
 		}
 	})
 
+	// Run every 15 minutes
+	c.cron.AddFunc("@every 15m", func() {
+		if count, err := c.idempotencyService.SweepExpiredKeys(); err != nil {
+			c.logger.Log().Errorf("Failed to sweep expired idempotency keys: %v", err)
+		} else {
+			c.logger.Log().Infof("Swept %d expired idempotency keys", count)
+		}
+	})
+
+	// Run every minute
+	c.cron.AddFunc("@every 1m", func() {
+		if count, err := c.walletService.ExpireHolds(); err != nil {
+			c.logger.Log().Errorf("Failed to expire holds: %v", err)
+		} else if count > 0 {
+			c.logger.Log().Infof("Expired %d stale holds", count)
+		}
+	})
+
+	// Run every minute, schedules are claimed so every instance can run this job
+	c.cron.AddFunc("@every 1m", func() {
+		if count, err := c.scheduleService.RunDueSchedules(); err != nil {
+			c.logger.Log().Errorf("Failed to run scheduled transfers: %v", err)
+		} else if count > 0 {
+			c.logger.Log().Infof("Ran %d scheduled transfers", count)
+		}
+	})
+
+	// Run every 15 seconds, deliveries are claimed so every instance can run this job
+	c.cron.AddFunc("@every 15s", func() {
+		if count, err := c.webhookService.DeliverDueWebhooks(); err != nil {
+			c.logger.Log().Errorf("Failed to deliver webhooks: %v", err)
+		} else if count > 0 {
+			c.logger.Log().Infof("Sent %d webhook deliveries", count)
+		}
+	})
+
+	// Run every 5 seconds, events are claimed so every instance can run this job
+	if c.relayEvents {
+		c.cron.AddFunc("@every 5s", func() {
+			if count, err := c.outboxRelay.RelayEvents(context.Background()); err != nil {
+				c.logger.Log().Errorf("Failed to relay outbox events: %v", err)
+			} else if count > 0 {
+				c.logger.Log().Infof("Published %d outbox events", count)
+			}
+		})
+	}
+
+	// Run every hour
+	c.cron.AddFunc("@every 1h", func() {
+		if count, err := c.outboxRelay.PurgeSentEvents(); err != nil {
+			c.logger.Log().Errorf("Failed to purge sent outbox events: %v", err)
+		} else if count > 0 {
+			c.logger.Log().Infof("Purged %d sent outbox events", count)
+		}
+	})
+
+	// Run every hour, rotation takes a lock so only one instance makes the next signing key
+	c.cron.AddFunc("@every 1h", func() {
+		if created, err := c.signingKeyService.RotateKeys(); err != nil {
+			c.logger.Log().Errorf("Failed to rotate JWT signing keys: %v", err)
+		} else if created {
+			c.logger.Log().Info("Published the next JWT signing key")
+		}
+	})
+
 	c.logger.Log().Info("Cron service started")
 	c.cron.Start()
 }
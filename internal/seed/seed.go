@@ -2,6 +2,7 @@ package seed
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/horlakz/wallet-sync.api/internal/helper"
 	"github.com/horlakz/wallet-sync.api/lib/database"
@@ -29,9 +30,10 @@ func (s *seeder) SeedAdmin() {
 	users := []struct {
 		Name  string
 		Email string
+		Role  string
 	}{
-		{"Admin User", "admin@wallet-sync.com"},
-		{"Test User", "test@wallet-sync.com"},
+		{"Admin User", "admin@wallet-sync.com", model.RoleAdmin},
+		{"Test User", "test@wallet-sync.com", model.RoleUser},
 	}
 
 	hashedPassword, err := hashing.HashPassword("Pa$$w0rd!")
@@ -40,6 +42,9 @@ func (s *seeder) SeedAdmin() {
 		return
 	}
 
+	// seeded accounts are trusted, they do not go through email verification
+	verifiedAt := time.Now()
+
 	for _, userInfo := range users {
 		if exists := s.dbConn.Connection().Where("email = ?", userInfo.Email).First(&model.User{}).RowsAffected > 0; exists {
 			fmt.Printf("%s already exists in the database. Skipping seeding...\n", userInfo.Name)
@@ -47,9 +52,11 @@ func (s *seeder) SeedAdmin() {
 		}
 
 		user := model.User{
-			Name:     userInfo.Name,
-			Email:    userInfo.Email,
-			Password: hashedPassword,
+			Name:       userInfo.Name,
+			Email:      userInfo.Email,
+			Password:   hashedPassword,
+			Role:       userInfo.Role,
+			VerifiedAt: &verifiedAt,
 		}
 
 		if err := s.dbConn.Connection().Create(&user).Error; err != nil {
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -38,6 +40,11 @@ func Migrate(database DatabaseInterface) {
 		return
 	}
 
+	// os.ReadDir sorts by name, which would run V0.10 before V0.2
+	slices.SortStableFunc(files, func(a, b os.DirEntry) int {
+		return compareMigrationVersions(a.Name(), b.Name())
+	})
+
 	// Run migrations
 	for _, file := range files {
 		// Skip directories
@@ -97,3 +104,35 @@ func Migrate(database DatabaseInterface) {
 	fmt.Println("All migrations have been applied.")
 
 }
+
+// compareMigrationVersions orders files named V<major>.<minor>__<name>.sql by their numeric version.
+func compareMigrationVersions(a, b string) int {
+	versionA, versionB := migrationVersion(a), migrationVersion(b)
+
+	for i := 0; i < len(versionA) && i < len(versionB); i++ {
+		if versionA[i] != versionB[i] {
+			return versionA[i] - versionB[i]
+		}
+	}
+
+	if len(versionA) != len(versionB) {
+		return len(versionA) - len(versionB)
+	}
+
+	return strings.Compare(a, b)
+}
+
+func migrationVersion(filename string) []int {
+	version, _, _ := strings.Cut(strings.TrimPrefix(filename, "V"), "__")
+
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		parts = append(parts, number)
+	}
+
+	return parts
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/horlakz/wallet-sync.api/internal/config"
@@ -10,6 +11,9 @@ import (
 
 var ctx = context.Background()
 
+// ErrCacheMiss is returned by GetValue when the key does not exist or has expired.
+var ErrCacheMiss = redis.Nil
+
 type redisClient struct {
 	client *redis.Client
 }
@@ -17,6 +21,11 @@ type redisClient struct {
 type RedisClientInterface interface {
 	Set(key string, value interface{}) error
 	Get(key string, batchSize int64) ([]string, error)
+	SetValue(key string, value interface{}, ttl time.Duration) error
+	SetValueNX(key string, value interface{}, ttl time.Duration) (bool, error)
+	GetValue(key string) (string, error)
+	Increment(key string, ttl time.Duration) (int64, error)
+	Delete(keys ...string) (int64, error)
 }
 
 func NewRedisClient(env config.Env) RedisClientInterface {
@@ -54,3 +63,40 @@ func (c *redisClient) Get(key string, batchSize int64) ([]string, error) {
 
 	return val, nil
 }
+
+// SetValue stores a plain key that expires after ttl, a zero ttl keeps it forever.
+func (c *redisClient) SetValue(key string, value interface{}, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetValueNX stores the key only if it does not exist yet and reports whether it was stored.
+func (c *redisClient) SetValueNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// GetValue reads a plain key, returning ErrCacheMiss when it is not set.
+func (c *redisClient) GetValue(key string) (string, error) {
+	return c.client.Get(ctx, key).Result()
+}
+
+// Increment adds one to a counter and returns the new value. The ttl is set when the counter is
+// created, so it counts within a fixed window from the first increment.
+func (c *redisClient) Increment(key string, ttl time.Duration) (int64, error) {
+	count, err := c.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 1 && ttl > 0 {
+		if err := c.client.Expire(ctx, key, ttl).Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+// Delete removes the keys and reports how many of them existed.
+func (c *redisClient) Delete(keys ...string) (int64, error) {
+	return c.client.Del(ctx, keys...).Result()
+}
@@ -24,7 +24,7 @@ func main() {
 	app.Use(recover.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, Idempotency-Key, X-OTP-Code, X-API-Key, X-Signature, X-Signature-Timestamp, X-Signature-Nonce",
 	}))
 	app.Use(limiter.New(limiter.Config{
 		Max:               1000,
@@ -48,7 +48,7 @@ func main() {
 	seed.NewSeeder(dbConn).Seed()
 
 	// Initialize cron jobs
-	cronService := job.NewCronService(dbConn)
+	cronService := job.NewCronService(dbConn, env)
 	cronService.Start()
 
 	log.Fatal(app.Listen("0.0.0.0:" + env.PORT))
@@ -1,26 +1,173 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
 	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+	"github.com/horlakz/wallet-sync.api/service"
 )
 
-func Protected() fiber.Handler {
+// APIKeyHeader carries an API key. A key may also be sent as the bearer token.
+const APIKeyHeader = "X-API-Key"
+
+const (
+	CodeAPIKeyNotAllowed   = "API_KEY_NOT_ALLOWED"
+	CodeAPIKeyScope        = "API_KEY_SCOPE_MISSING"
+	CodeAPIKeyIPNotAllowed = "API_KEY_IP_NOT_ALLOWED"
+)
+
+// Protected requires a valid access token whose session has not been revoked, or an API key
+// granted all of the given scopes. Without scopes only access tokens are accepted.
+// It stores the user ID in "userId" and the scopes of the request in "scopes", which for an
+// access token are all of them. An access token also sets the session ID in "sessionId" and the
+// role and permissions in "role" and "permissions", an API key sets its ID in "apiKeyId" and
+// itself in "apiKey".
+func Protected(db database.DatabaseInterface, scopes ...string) fiber.Handler {
+	return protect(db, scopes, true)
+}
+
+// Authenticated is Protected for a whole group of routes. It accepts an access token or an API
+// key with any scopes, so middleware mounted on the group after it sees the caller, and each route
+// names the scopes it needs with RequireScopes.
+func Authenticated(db database.DatabaseInterface) fiber.Handler {
+	return protect(db, nil, false)
+}
+
+// RequireScopes lets an API key through only if it was granted all of the given scopes. Without
+// scopes API keys are refused. Access tokens always pass. It must be mounted after Authenticated.
+func RequireScopes(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		apiKey, _ := c.Locals("apiKey").(*model.APIKey)
+		if apiKey == nil {
+			return c.Next()
+		}
+
+		if resp := apiKeyScopeError(apiKey, scopes); resp != nil {
+			return c.Status(resp.Status).JSON(*resp)
+		}
+
+		return c.Next()
+	}
+}
+
+func protect(db database.DatabaseInterface, scopes []string, checkScopes bool) fiber.Handler {
 	authHelper := helper.NewJwt()
+	tokenStore := service.NewTokenStore(db.Cache())
+	auditService := service.NewAuditService(core_repository.NewAuditLogRepository(db))
+	sessionService := service.NewSessionService(user_repository.NewSessionRepository(db), tokenStore, auditService, db.Cache())
+	// the router refuses to start without a valid key
+	encryptionKey, _ := helper.ParseEncryptionKey(config.GetEnv().JWT_KEY_ENCRYPTION_KEY)
+	apiKeyService := service.NewAPIKeyService(user_repository.NewAPIKeyRepository(db), auditService, encryptionKey)
 
 	return func(c *fiber.Ctx) (err error) {
 		token := authHelper.ExtractBearerToken(c.Request())
-		userId, err := authHelper.ExtractUserID(token, "access")
+
+		if key := c.Get(APIKeyHeader); key != "" || service.IsAPIKey(token) {
+			if key == "" {
+				key = token
+			}
+			return authenticateAPIKey(c, apiKeyService, key, scopes, checkScopes)
+		}
+
+		claims, err := authHelper.ExtractClaims(token, "access")
 
 		if err != nil {
 			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
 		}
 
+		subject, _ := claims["sub"].(string)
+		userId, err := uuid.Parse(subject)
+		if err != nil {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "invalid token: user id not found"})
+		}
+
+		sessionId, _ := claims["sid"].(string)
+		if sessionId == "" {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": "invalid token: session not found"})
+		}
+
+		active, err := tokenStore.SessionActive(sessionId)
+		if err != nil {
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+
+		if !active {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": service.ErrSessionRevoked.Error()})
+		}
+
+		sessionService.Touch(sessionId, dto.ClientInfo{IPAddress: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)})
+
 		c.Locals("userId", userId)
+		c.Locals("sessionId", sessionId)
+		c.Locals("role", claimString(claims["role"]))
+		c.Locals("permissions", claimStrings(claims["permissions"]))
+		c.Locals("scopes", model.APIKeyScopes)
 
 		return c.Next()
 	}
 }
+
+func authenticateAPIKey(c *fiber.Ctx, apiKeyService service.APIKeyServiceInterface, key string, scopes []string, checkScopes bool) error {
+	var resp response.Response
+
+	if checkScopes && len(scopes) == 0 {
+		return c.Status(http.StatusForbidden).JSON(*apiKeyScopeError(nil, scopes))
+	}
+
+	apiKey, err := apiKeyService.Authenticate(key, c.IP())
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+		case errors.Is(err, service.ErrAPIKeyIPNotAllowed):
+			resp.Status = http.StatusForbidden
+			resp.Message = err.Error()
+			resp.Code = CodeAPIKeyIPNotAllowed
+			return c.Status(resp.Status).JSON(resp)
+		default:
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	if checkScopes {
+		if resp := apiKeyScopeError(apiKey, scopes); resp != nil {
+			return c.Status(resp.Status).JSON(*resp)
+		}
+	}
+
+	c.Locals("userId", apiKey.UserID)
+	c.Locals("apiKeyId", apiKey.ID)
+	c.Locals("apiKey", apiKey)
+	c.Locals("role", "")
+	c.Locals("permissions", []string{})
+	c.Locals("scopes", []string(apiKey.Scopes))
+
+	return c.Next()
+}
+
+// apiKeyScopeError is the response for an API key that may not use a route needing scopes, or nil
+// if it may.
+func apiKeyScopeError(apiKey *model.APIKey, scopes []string) *response.Response {
+	if len(scopes) == 0 {
+		return &response.Response{Status: http.StatusForbidden, Message: "API keys cannot be used for this endpoint", Code: CodeAPIKeyNotAllowed}
+	}
+
+	for _, scope := range scopes {
+		if !apiKey.HasScope(scope) {
+			return &response.Response{Status: http.StatusForbidden, Message: "the API key is missing the " + scope + " scope", Code: CodeAPIKeyScope}
+		}
+	}
+
+	return nil
+}
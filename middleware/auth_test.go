@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+func TestRequireScopes(t *testing.T) {
+	walletReader := &model.APIKey{Scopes: []string{model.APIKeyScopeWalletRead}}
+
+	tests := []struct {
+		name       string
+		apiKey     *model.APIKey
+		scopes     []string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "access token", scopes: []string{model.APIKeyScopeTransfer}, wantStatus: http.StatusOK},
+		{name: "access token on a token only route", wantStatus: http.StatusOK},
+		{name: "key with the scope", apiKey: walletReader, scopes: []string{model.APIKeyScopeWalletRead}, wantStatus: http.StatusOK},
+		{name: "key without the scope", apiKey: walletReader, scopes: []string{model.APIKeyScopeTransfer}, wantStatus: http.StatusForbidden, wantCode: CodeAPIKeyScope},
+		{name: "key on a token only route", apiKey: walletReader, wantStatus: http.StatusForbidden, wantCode: CodeAPIKeyNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(func(c *fiber.Ctx) error {
+				if tt.apiKey != nil {
+					c.Locals("apiKey", tt.apiKey)
+				}
+				return c.Next()
+			})
+			app.Get("/v1/wallet", RequireScopes(tt.scopes...), func(c *fiber.Ctx) error {
+				return c.SendStatus(http.StatusOK)
+			})
+
+			status, code := do(t, app, httptest.NewRequest(http.MethodGet, "/v1/wallet", nil))
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
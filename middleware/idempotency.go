@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency replays the stored response for a repeated Idempotency-Key instead of
+// running the handler again. It must be mounted after Protected as keys are scoped per user.
+func Idempotency(idempotencyService service.IdempotencyServiceInterface) fiber.Handler {
+	logger := config.NewLogger()
+
+	return func(c *fiber.Ctx) error {
+		var resp response.Response
+
+		key := strings.TrimSpace(c.Get(IdempotencyKeyHeader))
+		if key == "" {
+			return c.Next()
+		}
+
+		if len(key) > 255 {
+			resp.Status = http.StatusBadRequest
+			resp.Message = "Idempotency-Key must not exceed 255 characters"
+			return c.Status(resp.Status).JSON(resp)
+		}
+
+		userId := c.Locals("userId").(uuid.UUID)
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Method()))
+		hash.Write([]byte(c.Path()))
+		hash.Write(c.Body())
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		record, replay, err := idempotencyService.Begin(userId, key, requestHash)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrIdempotencyKeyInUse):
+				resp.Status = http.StatusConflict
+			case errors.Is(err, service.ErrIdempotencyKeyMismatch):
+				resp.Status = http.StatusUnprocessableEntity
+			default:
+				resp.Status = http.StatusInternalServerError
+			}
+			resp.Message = err.Error()
+			return c.Status(resp.Status).JSON(resp)
+		}
+
+		if replay {
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return c.Status(record.ResponseStatus).Send(record.ResponseBody)
+		}
+
+		c.Locals("idempotencyKey", key)
+
+		if err := c.Next(); err != nil {
+			if releaseErr := idempotencyService.Release(record); releaseErr != nil {
+				logger.Log().Errorf("failed to release idempotency key %s: %v", key, releaseErr)
+			}
+			return err
+		}
+
+		status := c.Response().StatusCode()
+
+		// server errors are not cached so that the client can retry. If the error came after the
+		// postings were committed, the retry finds the transaction stored with the key and returns it
+		if status >= http.StatusInternalServerError {
+			if err := idempotencyService.Release(record); err != nil {
+				logger.Log().Errorf("failed to release idempotency key %s: %v", key, err)
+			}
+			return nil
+		}
+
+		reference, _ := c.Locals("transactionReference").(string)
+
+		// the transaction already carries the key, so a lost response only means a retry rebuilds it
+		if err := idempotencyService.Complete(record, status, c.Response().Body(), reference); err != nil {
+			logger.Log().Errorf("failed to store idempotent response for key %s: %v", key, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/payload/response"
+)
+
+const CodeForbidden = "FORBIDDEN"
+
+// RequireRole only lets users with one of the given roles through. It must be mounted after Protected.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+
+		if !slices.Contains(roles, role) {
+			return forbidden(c)
+		}
+
+		return c.Next()
+	}
+}
+
+// RequirePermission only lets users whose role grants all of the given permissions through.
+// It must be mounted after Protected.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, _ := c.Locals("permissions").([]string)
+
+		for _, permission := range permissions {
+			if !slices.Contains(granted, permission) {
+				return forbidden(c)
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+func forbidden(c *fiber.Ctx) error {
+	var resp response.Response
+
+	resp.Status = http.StatusForbidden
+	resp.Message = "you do not have permission to access this resource"
+	resp.Code = CodeForbidden
+	return c.Status(resp.Status).JSON(resp)
+}
+
+func claimString(claim interface{}) string {
+	value, _ := claim.(string)
+	return value
+}
+
+// claimStrings reads a list claim, which the JSON decoding of the token leaves as []interface{}.
+func claimStrings(claim interface{}) []string {
+	items, _ := claim.([]interface{})
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
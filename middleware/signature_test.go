@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+const testSigningSecret = "signing-secret"
+
+// fakeNonceCache remembers the keys set with SetValueNX.
+type fakeNonceCache struct {
+	database.RedisClientInterface
+
+	keys map[string]bool
+}
+
+func (c *fakeNonceCache) SetValueNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	if c.keys[key] {
+		return false, nil
+	}
+	c.keys[key] = true
+	return true, nil
+}
+
+var testSignatureNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newSignedApp serves POST /v1/wallet/fund behind Signed, authenticated as an API key.
+func newSignedApp(apiKey *model.APIKey) *fiber.App {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("apiKey", apiKey)
+		return c.Next()
+	})
+	app.Use(Signed(SignatureConfig{
+		Cache: &fakeNonceCache{keys: map[string]bool{}},
+		Now:   func() time.Time { return testSignatureNow },
+	}))
+	app.Post("/v1/wallet/fund", func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	})
+	return app
+}
+
+type signedRequest struct {
+	path      string
+	body      string
+	signedAt  time.Time
+	nonce     string
+	signature string
+}
+
+// request builds the request, signing path and body at signedAt unless a signature is given.
+func (r signedRequest) request() *http.Request {
+	timestamp := strconv.FormatInt(r.signedAt.Unix(), 10)
+	signature := r.signature
+	if signature == "" {
+		signature = Sign(testSigningSecret, SignatureBase(http.MethodPost, r.path, timestamp, r.nonce, []byte(r.body)))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/wallet/fund", strings.NewReader(r.body))
+	req.Header.Set(SignatureHeader, signature)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, r.nonce)
+	return req
+}
+
+// do sends req and returns the status and the error code of the response.
+func do(t *testing.T, app *fiber.App, req *http.Request) (int, string) {
+	t.Helper()
+
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&body)
+	return res.StatusCode, body.Code
+}
+
+func TestSigned(t *testing.T) {
+	valid := signedRequest{path: "/v1/wallet/fund", body: `{"amount":"10"}`, signedAt: testSignatureNow, nonce: "nonce-1"}
+
+	tests := []struct {
+		name       string
+		modify     func(r *signedRequest)
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "inside the window", modify: func(r *signedRequest) {}, wantStatus: http.StatusOK},
+		{name: "oldest accepted", modify: func(r *signedRequest) { r.signedAt = testSignatureNow.Add(-DefaultSignatureSkew) }, wantStatus: http.StatusOK},
+		{name: "newest accepted", modify: func(r *signedRequest) { r.signedAt = testSignatureNow.Add(DefaultSignatureSkew) }, wantStatus: http.StatusOK},
+		{
+			name:       "too old",
+			modify:     func(r *signedRequest) { r.signedAt = testSignatureNow.Add(-DefaultSignatureSkew - time.Second) },
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   CodeSignatureExpired,
+		},
+		{
+			name:       "too far ahead",
+			modify:     func(r *signedRequest) { r.signedAt = testSignatureNow.Add(DefaultSignatureSkew + time.Second) },
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   CodeSignatureExpired,
+		},
+		{
+			name: "tampered body",
+			modify: func(r *signedRequest) {
+				r.signature = Sign(testSigningSecret, SignatureBase(http.MethodPost, r.path, strconv.FormatInt(r.signedAt.Unix(), 10), r.nonce, []byte(r.body)))
+				r.body = `{"amount":"10000"}`
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   CodeSignatureInvalid,
+		},
+		{
+			name:       "other path",
+			modify:     func(r *signedRequest) { r.path = "/v1/wallet/withdraw" },
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   CodeSignatureInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newSignedApp(&model.APIKey{SigningSecret: testSigningSecret})
+
+			r := valid
+			tt.modify(&r)
+
+			status, code := do(t, app, r.request())
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Errorf("got %d %q, want %d %q", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestSignedRefusesReplayedNonce(t *testing.T) {
+	apiKey := &model.APIKey{SigningSecret: testSigningSecret}
+	apiKey.ID = uuid.New()
+	app := newSignedApp(apiKey)
+
+	request := signedRequest{path: "/v1/wallet/fund", body: `{"amount":"10"}`, signedAt: testSignatureNow, nonce: "nonce-1"}
+
+	if status, code := do(t, app, request.request()); status != http.StatusOK {
+		t.Fatalf("first request: %d %q", status, code)
+	}
+
+	status, code := do(t, app, request.request())
+	if status != http.StatusUnauthorized || code != CodeSignatureReplayed {
+		t.Errorf("replay: got %d %q, want %d %q", status, code, http.StatusUnauthorized, CodeSignatureReplayed)
+	}
+
+	// a fresh nonce is accepted
+	request.nonce = "nonce-2"
+	if status, code := do(t, app, request.request()); status != http.StatusOK {
+		t.Errorf("new nonce: %d %q", status, code)
+	}
+}
+
+func TestSignedRequiresSignature(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/wallet/fund", strings.NewReader(`{}`))
+
+	status, code := do(t, newSignedApp(&model.APIKey{SigningSecret: testSigningSecret, RequireSignature: true}), req)
+	if status != http.StatusUnauthorized || code != CodeSignatureRequired {
+		t.Errorf("got %d %q, want %d %q", status, code, http.StatusUnauthorized, CodeSignatureRequired)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/wallet/fund", strings.NewReader(`{}`))
+	if status, _ := do(t, newSignedApp(&model.APIKey{SigningSecret: testSigningSecret}), req); status != http.StatusOK {
+		t.Errorf("unsigned request to a key without require_signature: %d", status)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/payload/response"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+const CodeEmailNotVerified = "EMAIL_NOT_VERIFIED"
+
+// Verified rejects users who have not verified their email yet. It must be mounted after Protected or Authenticated.
+func Verified(db database.DatabaseInterface) fiber.Handler {
+	userRepository := user_repository.NewUserRepository(db)
+
+	return func(c *fiber.Ctx) error {
+		var resp response.Response
+
+		userId := c.Locals("userId").(uuid.UUID)
+
+		user, err := userRepository.FindByID(userId)
+		if err != nil {
+			resp.Status = http.StatusUnauthorized
+			resp.Message = "user not found"
+			return c.Status(resp.Status).JSON(resp)
+		}
+
+		if user.VerifiedAt == nil {
+			resp.Status = http.StatusForbidden
+			resp.Message = "verify your email address to continue"
+			resp.Code = CodeEmailNotVerified
+			return c.Status(resp.Status).JSON(resp)
+		}
+
+		return c.Next()
+	}
+}
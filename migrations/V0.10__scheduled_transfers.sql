@@ -0,0 +1,39 @@
+-- Scheduled Transfers Table
+CREATE TABLE
+    scheduled_transfers (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        to_account_number VARCHAR(20) NOT NULL,
+        currency VARCHAR(3) NOT NULL,
+        amount DECIMAL(32, 4) NOT NULL,
+        description VARCHAR(255) NULL,
+        cron_expression VARCHAR(100) NULL,
+        interval_minutes INT NOT NULL DEFAULT 0,
+        next_run_at DATETIME NOT NULL,
+        last_run_at DATETIME NULL,
+        status ENUM ('active', 'paused', 'completed') DEFAULT 'active' NOT NULL,
+        failure_count INT NOT NULL DEFAULT 0,
+        last_error VARCHAR(255) NULL,
+        claimed_until DATETIME NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_scheduled_transfers_user_id (user_id),
+        INDEX idx_scheduled_transfers_due (status, next_run_at),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+-- Scheduled Transfer Runs Table, the execution and failure history of a schedule
+CREATE TABLE
+    scheduled_transfer_runs (
+        id CHAR(36) PRIMARY KEY,
+        schedule_id CHAR(36) NOT NULL,
+        status ENUM ('succeeded', 'failed') NOT NULL,
+        transaction_reference VARCHAR(255) NULL,
+        error VARCHAR(255) NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_scheduled_transfer_runs_schedule_id (schedule_id),
+        FOREIGN KEY (schedule_id) REFERENCES scheduled_transfers (id)
+    );
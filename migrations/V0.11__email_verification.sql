@@ -0,0 +1,8 @@
+-- Users must verify their email before using their wallet
+ALTER TABLE users
+ADD COLUMN verified_at DATETIME NULL AFTER kyc_level;
+
+-- Accounts created before verification existed are treated as verified
+UPDATE users
+SET verified_at = created_at
+WHERE verified_at IS NULL;
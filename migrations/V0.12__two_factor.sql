@@ -0,0 +1,18 @@
+-- TOTP secret, pending until totp_enabled_at is set
+ALTER TABLE users
+ADD COLUMN totp_secret VARCHAR(64) NULL AFTER verified_at,
+ADD COLUMN totp_enabled_at DATETIME NULL AFTER totp_secret;
+
+-- Recovery Codes Table, argon2id hashes of single-use codes
+CREATE TABLE
+    recovery_codes (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        code_hash VARCHAR(255) NOT NULL,
+        used_at DATETIME NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_recovery_codes_user_id (user_id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
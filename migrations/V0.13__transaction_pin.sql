@@ -0,0 +1,21 @@
+-- Transaction PIN, an argon2id hash separate from the login password
+ALTER TABLE users
+ADD COLUMN pin_hash VARCHAR(255) NULL AFTER totp_enabled_at,
+ADD COLUMN pin_set_at DATETIME NULL AFTER pin_hash;
+
+-- Audit Logs Table, security relevant events such as PIN failures
+CREATE TABLE
+    audit_logs (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NULL,
+        action VARCHAR(64) NOT NULL,
+        ip_address VARCHAR(45) NULL,
+        user_agent VARCHAR(255) NULL,
+        metadata JSON NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_audit_logs_user_id (user_id),
+        INDEX idx_audit_logs_action (action),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
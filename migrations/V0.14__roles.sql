@@ -0,0 +1,11 @@
+-- Users have a role that decides which admin routes they may use
+ALTER TABLE users
+ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'user' AFTER password;
+
+UPDATE users
+SET role = 'admin'
+WHERE email = 'admin@wallet-sync.com';
+
+-- Wallets can be frozen by an admin, a frozen wallet neither sends nor receives money
+ALTER TABLE accounts
+ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active' AFTER number;
@@ -0,0 +1,21 @@
+-- Frozen wallets become frozen_all, the freeze that blocks money in both directions
+UPDATE accounts
+SET status = 'frozen_all'
+WHERE status = 'frozen';
+
+-- Account Status Changes Table, the history of every account state transition
+CREATE TABLE
+    account_status_changes (
+        id CHAR(36) PRIMARY KEY,
+        account_id CHAR(36) NOT NULL,
+        actor_id CHAR(36) NULL,
+        from_status VARCHAR(16) NOT NULL,
+        to_status VARCHAR(16) NOT NULL,
+        reason VARCHAR(255) NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_account_status_changes_account_id (account_id),
+        FOREIGN KEY (account_id) REFERENCES accounts (id),
+        FOREIGN KEY (actor_id) REFERENCES users (id)
+    );
@@ -0,0 +1,19 @@
+-- API Keys Table, server-to-server credentials stored as SHA-256 hashes
+CREATE TABLE
+    api_keys (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        name VARCHAR(64) NOT NULL,
+        prefix VARCHAR(16) NOT NULL,
+        key_hash VARCHAR(64) NOT NULL,
+        scopes JSON NOT NULL,
+        allowed_ips JSON NULL,
+        last_used_at DATETIME NULL,
+        revoked_at DATETIME NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        UNIQUE INDEX idx_api_keys_prefix (prefix),
+        INDEX idx_api_keys_user_id (user_id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
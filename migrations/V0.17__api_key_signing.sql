@@ -0,0 +1,4 @@
+-- Shared secret for HMAC signed requests, keys created before it cannot sign
+ALTER TABLE api_keys
+ADD COLUMN signing_secret VARCHAR(64) NOT NULL DEFAULT '' AFTER allowed_ips,
+ADD COLUMN require_signature BOOLEAN NOT NULL DEFAULT FALSE AFTER signing_secret;
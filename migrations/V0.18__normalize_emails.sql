@@ -0,0 +1,3 @@
+-- Emails are stored lowercase, earlier rows kept the case they were registered with
+UPDATE users
+SET email = LOWER(TRIM(email));
@@ -0,0 +1,17 @@
+-- Sessions Table, one row per login for the user's list of devices
+CREATE TABLE
+    sessions (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        device_name VARCHAR(100) NOT NULL,
+        user_agent VARCHAR(255) NULL,
+        ip_address VARCHAR(45) NULL,
+        last_seen_at DATETIME NOT NULL,
+        expires_at DATETIME NOT NULL,
+        revoked_at DATETIME NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_sessions_user_id (user_id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
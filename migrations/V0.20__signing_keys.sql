@@ -0,0 +1,12 @@
+-- Signing Keys Table, the RS256 or EdDSA keys access and refresh tokens are signed with
+CREATE TABLE
+    signing_keys (
+        id CHAR(36) PRIMARY KEY,
+        algorithm VARCHAR(16) NOT NULL,
+        private_key TEXT NOT NULL,
+        activates_at DATETIME NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_signing_keys_algorithm (algorithm)
+    );
@@ -0,0 +1,55 @@
+-- Webhook Endpoints Table, the URLs users receive wallet events at
+CREATE TABLE
+    webhook_endpoints (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        url VARCHAR(2048) NOT NULL,
+        description VARCHAR(255) NULL,
+        events JSON NOT NULL,
+        secret VARCHAR(64) NOT NULL,
+        active BOOLEAN NOT NULL DEFAULT TRUE,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_webhook_endpoints_user_id (user_id),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+-- Webhook Deliveries Table, the outbox of events written with the money movement they report
+CREATE TABLE
+    webhook_deliveries (
+        id CHAR(36) PRIMARY KEY,
+        endpoint_id CHAR(36) NOT NULL,
+        event_id CHAR(36) NOT NULL,
+        event_type VARCHAR(64) NOT NULL,
+        payload JSON NOT NULL,
+        status ENUM ('pending', 'delivered', 'dead') DEFAULT 'pending' NOT NULL,
+        attempts INT NOT NULL DEFAULT 0,
+        next_attempt_at DATETIME NOT NULL,
+        last_attempt_at DATETIME NULL,
+        delivered_at DATETIME NULL,
+        last_error VARCHAR(255) NULL,
+        claimed_until DATETIME NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_webhook_deliveries_endpoint_id (endpoint_id),
+        INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+        FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints (id)
+    );
+
+-- Webhook Attempts Table, the log of every request made for a delivery
+CREATE TABLE
+    webhook_attempts (
+        id CHAR(36) PRIMARY KEY,
+        delivery_id CHAR(36) NOT NULL,
+        status_code INT NOT NULL DEFAULT 0,
+        response_body TEXT NULL,
+        error VARCHAR(255) NULL,
+        duration_ms BIGINT NOT NULL DEFAULT 0,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_webhook_attempts_delivery_id (delivery_id),
+        FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries (id)
+    );
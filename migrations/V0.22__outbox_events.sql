@@ -0,0 +1,21 @@
+-- Outbox Events Table, events written with the operation they report and relayed to RabbitMQ
+CREATE TABLE
+    outbox_events (
+        id CHAR(36) PRIMARY KEY,
+        event_id CHAR(36) NOT NULL,
+        event_type VARCHAR(64) NOT NULL,
+        version INT NOT NULL,
+        routing_key VARCHAR(128) NOT NULL,
+        payload JSON NOT NULL,
+        status ENUM ('pending', 'sent') DEFAULT 'pending' NOT NULL,
+        attempts INT NOT NULL DEFAULT 0,
+        next_attempt_at DATETIME NOT NULL,
+        last_error VARCHAR(255) NULL,
+        sent_at DATETIME NULL,
+        claimed_until DATETIME NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_outbox_events_due (status, next_attempt_at),
+        INDEX idx_outbox_events_sent_at (status, sent_at)
+    );
@@ -0,0 +1,17 @@
+-- Keep only the newest rule per user or KYC level, operation and currency
+DELETE older FROM limit_rules older
+JOIN limit_rules newer ON COALESCE(older.user_id, CONCAT('kyc:', older.kyc_level)) = COALESCE(newer.user_id, CONCAT('kyc:', newer.kyc_level))
+AND older.operation = newer.operation
+AND older.currency = newer.currency
+AND (
+    older.created_at < newer.created_at
+    OR (
+        older.created_at = newer.created_at
+        AND older.id < newer.id
+    )
+);
+
+-- A rule applies to one user, or to a KYC level when no user is set
+ALTER TABLE limit_rules
+ADD COLUMN scope VARCHAR(40) AS (COALESCE(user_id, CONCAT('kyc:', kyc_level))) STORED AFTER kyc_level,
+ADD UNIQUE INDEX idx_limit_rules_scope (scope, operation, currency);
@@ -0,0 +1,4 @@
+-- A transaction posted for an Idempotency-Key keeps the key, so a retry finds it instead of posting again
+ALTER TABLE transactions
+ADD COLUMN idempotency_key VARCHAR(255) NULL AFTER reference,
+ADD UNIQUE INDEX idx_transactions_idempotency_key (user_id, idempotency_key);
@@ -0,0 +1,3 @@
+-- Events no queue is bound for are set aside instead of holding up the ones behind them
+ALTER TABLE outbox_events
+MODIFY status ENUM ('pending', 'sent', 'unroutable') DEFAULT 'pending' NOT NULL;
@@ -0,0 +1,3 @@
+-- The occurrence a retried run pays, while next_run_at holds the time of the retry
+ALTER TABLE scheduled_transfers
+ADD COLUMN planned_run_at DATETIME NULL AFTER next_run_at;
@@ -0,0 +1,3 @@
+-- Signing secrets are stored sealed with JWT_KEY_ENCRYPTION_KEY, which needs more room
+ALTER TABLE api_keys
+MODIFY signing_secret VARCHAR(255) NOT NULL DEFAULT '';
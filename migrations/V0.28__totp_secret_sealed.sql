@@ -0,0 +1,3 @@
+-- TOTP secrets are stored sealed with JWT_KEY_ENCRYPTION_KEY, which needs more room
+ALTER TABLE users
+MODIFY totp_secret VARCHAR(255) NULL;
@@ -0,0 +1,4 @@
+-- Holds get their own operation, so an Idempotency-Key used for a withdrawal cannot replay as a hold
+UPDATE transactions
+SET operation = 'hold'
+WHERE id IN (SELECT transaction_id FROM holds);
@@ -0,0 +1,19 @@
+-- Idempotency Keys Table
+CREATE TABLE
+    idempotency_keys (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        idempotency_key VARCHAR(255) NOT NULL,
+        request_hash CHAR(64) NOT NULL,
+        status ENUM ('processing', 'completed') DEFAULT 'processing' NOT NULL,
+        response_status INT NULL,
+        response_body JSON,
+        transaction_reference VARCHAR(255) NULL,
+        expires_at DATETIME NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        UNIQUE KEY idx_idempotency_user_key (user_id, idempotency_key),
+        INDEX idx_idempotency_expires_at (expires_at),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
@@ -0,0 +1,3 @@
+-- Optimistic concurrency control for account balances
+ALTER TABLE accounts
+ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,10 @@
+-- System accounts (reserve, fee) and their postings are not owned by a user
+ALTER TABLE accounts MODIFY user_id CHAR(36) NULL;
+
+ALTER TABLE accounts ADD UNIQUE KEY idx_accounts_number (number);
+
+ALTER TABLE ledger_entries MODIFY user_id CHAR(36) NULL;
+
+-- Business operation a journal transaction was posted for
+ALTER TABLE transactions
+ADD COLUMN operation VARCHAR(32) NOT NULL DEFAULT '' AFTER status;
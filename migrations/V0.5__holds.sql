@@ -0,0 +1,24 @@
+-- Funds reserved by active holds, available balance = balance - held_balance
+ALTER TABLE accounts
+ADD COLUMN held_balance DECIMAL(32, 2) NOT NULL DEFAULT 0.00 AFTER balance;
+
+-- Holds Table
+CREATE TABLE
+    holds (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NOT NULL,
+        account_id CHAR(36) NOT NULL,
+        transaction_id CHAR(36) NOT NULL,
+        amount DECIMAL(32, 2) NOT NULL,
+        captured_amount DECIMAL(32, 2) NOT NULL DEFAULT 0.00,
+        status ENUM ('active', 'captured', 'voided', 'expired') DEFAULT 'active' NOT NULL,
+        expires_at DATETIME NOT NULL,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        UNIQUE KEY idx_holds_transaction_id (transaction_id),
+        INDEX idx_holds_status_expires_at (status, expires_at),
+        FOREIGN KEY (user_id) REFERENCES users (id),
+        FOREIGN KEY (account_id) REFERENCES accounts (id),
+        FOREIGN KEY (transaction_id) REFERENCES transactions (id)
+    );
@@ -0,0 +1,25 @@
+-- Widen money columns to the largest ISO 4217 minor unit (4 digits, e.g. CLF),
+-- amounts are rounded to the precision of their own currency by the service layer
+ALTER TABLE accounts
+MODIFY balance DECIMAL(32, 4) NOT NULL DEFAULT 0,
+MODIFY held_balance DECIMAL(32, 4) NOT NULL DEFAULT 0,
+MODIFY currency VARCHAR(3) DEFAULT 'NGN' NOT NULL;
+
+ALTER TABLE transactions
+MODIFY amount DECIMAL(32, 4) NOT NULL,
+MODIFY currency VARCHAR(3) DEFAULT 'NGN' NOT NULL;
+
+ALTER TABLE ledger_entries MODIFY amount DECIMAL(32, 4) NOT NULL;
+
+ALTER TABLE holds
+MODIFY amount DECIMAL(32, 4) NOT NULL,
+MODIFY captured_amount DECIMAL(32, 4) NOT NULL DEFAULT 0;
+
+ALTER TABLE reconciliation_logs
+MODIFY computed_balance DECIMAL(32, 4) NOT NULL,
+MODIFY stored_balance DECIMAL(32, 4) NOT NULL,
+MODIFY discrepancy DECIMAL(32, 4) GENERATED ALWAYS AS (stored_balance - computed_balance) STORED;
+
+-- A user holds at most one wallet per currency
+ALTER TABLE accounts
+ADD UNIQUE KEY idx_accounts_user_type_currency (user_id, account_type, currency);
@@ -0,0 +1,3 @@
+-- FX position accounts per currency and the account collecting FX gains and losses
+ALTER TABLE accounts
+MODIFY account_type ENUM ('wallet', 'fee', 'reserve', 'fx', 'fx_pnl') DEFAULT 'wallet' NOT NULL;
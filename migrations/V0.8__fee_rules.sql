@@ -0,0 +1,18 @@
+-- Fee Rules Table, one rule per operation and currency
+CREATE TABLE
+    fee_rules (
+        id CHAR(36) PRIMARY KEY,
+        operation VARCHAR(32) NOT NULL,
+        currency VARCHAR(3) NOT NULL,
+        kind ENUM ('flat', 'percentage', 'tiered') NOT NULL,
+        flat_amount DECIMAL(32, 4) NOT NULL DEFAULT 0,
+        percentage DECIMAL(10, 6) NOT NULL DEFAULT 0,
+        min_fee DECIMAL(32, 4) NOT NULL DEFAULT 0,
+        max_fee DECIMAL(32, 4) NOT NULL DEFAULT 0,
+        tiers JSON NULL,
+        active BOOLEAN NOT NULL DEFAULT TRUE,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        UNIQUE KEY idx_fee_rules_operation_currency (operation, currency)
+    );
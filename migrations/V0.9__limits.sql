@@ -0,0 +1,27 @@
+-- KYC level drives which limit rules apply to a user
+ALTER TABLE users
+ADD COLUMN kyc_level INT NOT NULL DEFAULT 0 AFTER password;
+
+-- Limit Rules Table, per KYC level or per user override
+CREATE TABLE
+    limit_rules (
+        id CHAR(36) PRIMARY KEY,
+        user_id CHAR(36) NULL,
+        kyc_level INT NOT NULL DEFAULT 0,
+        operation VARCHAR(32) NOT NULL,
+        currency VARCHAR(3) NOT NULL,
+        per_transaction DECIMAL(32, 4) NOT NULL DEFAULT 0,
+        daily DECIMAL(32, 4) NOT NULL DEFAULT 0,
+        monthly DECIMAL(32, 4) NOT NULL DEFAULT 0,
+        velocity_count INT NOT NULL DEFAULT 0,
+        velocity_window_minutes INT NOT NULL DEFAULT 0,
+        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+        updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+        deleted_at DATETIME NULL,
+        INDEX idx_limit_rules_level (kyc_level, operation, currency),
+        INDEX idx_limit_rules_user (user_id, operation, currency),
+        FOREIGN KEY (user_id) REFERENCES users (id)
+    );
+
+-- Usage lookups sum a user's transactions per operation over a period
+CREATE INDEX idx_transactions_user_operation ON transactions (user_id, operation, currency, created_at);
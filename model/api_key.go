@@ -0,0 +1,72 @@
+package model
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// API key scopes, what a server holding the key may do on behalf of its user.
+const (
+	APIKeyScopeWalletRead       = "wallet:read"
+	APIKeyScopeTransactionsRead = "transactions:read"
+	APIKeyScopeTransfer         = "transfer"
+)
+
+// APIKeyScopes lists every scope a key can be granted.
+var APIKeyScopes = []string{APIKeyScopeWalletRead, APIKeyScopeTransactionsRead, APIKeyScopeTransfer}
+
+// APIKey lets a user's backend call the API without a login. Only a SHA-256 hash of the key is
+// stored, Prefix is its public part used to find it and to tell keys apart. SigningSecret keys
+// the HMAC of signed requests, which a key with RequireSignature must send; it is stored encrypted
+// with JWT_KEY_ENCRYPTION_KEY.
+type APIKey struct {
+	database.BaseModel
+
+	UserID           uuid.UUID                   `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name             string                      `json:"name" gorm:"type:varchar(64);not null"`
+	Prefix           string                      `json:"prefix" gorm:"type:varchar(16);not null;uniqueIndex"`
+	KeyHash          string                      `json:"-" gorm:"type:varchar(64);not null"`
+	Scopes           datatypes.JSONSlice[string] `json:"scopes" gorm:"type:json;not null"`
+	AllowedIPs       datatypes.JSONSlice[string] `json:"allowed_ips" gorm:"type:json"`
+	SigningSecret    string                      `json:"-" gorm:"type:varchar(255);not null;default:''"`
+	RequireSignature bool                        `json:"require_signature" gorm:"not null;default:false"`
+	LastUsedAt       *time.Time                  `json:"last_used_at"`
+	RevokedAt        *time.Time                  `json:"revoked_at"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// AllowsIP reports whether a request from ip may use the key. Entries are single addresses or
+// CIDR ranges, and an empty list allows every address.
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+
+	address := net.ParseIP(ip)
+	if address == nil {
+		return false
+	}
+
+	for _, allowed := range k.AllowedIPs {
+		if strings.Contains(allowed, "/") {
+			if _, network, err := net.ParseCIDR(allowed); err == nil && network.Contains(address) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(address) {
+			return true
+		}
+	}
+
+	return false
+}
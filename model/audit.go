@@ -0,0 +1,55 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// Audit actions, named <subject>.<event>.
+const (
+	AuditActionPinSet     = "pin.set"
+	AuditActionPinChanged = "pin.changed"
+	AuditActionPinReset   = "pin.reset"
+	AuditActionPinFailed  = "pin.failed"
+	AuditActionPinLocked  = "pin.locked"
+
+	AuditActionPinResetFailed = "pin.reset_failed"
+	AuditActionPinResetLocked = "pin.reset_locked"
+
+	AuditActionRoleChanged          = "user.role_changed"
+	AuditActionPasswordChanged      = "user.password_changed"
+	AuditActionEmailChangeRequested = "user.email_change_requested"
+	AuditActionEmailChanged         = "user.email_changed"
+
+	AuditActionLoginThrottled = "login.throttled"
+	AuditActionLoginLocked    = "login.locked"
+	AuditActionLoginIPBlocked = "login.ip_blocked"
+	AuditActionLoginUnlocked  = "login.unlocked"
+
+	AuditActionTwoFactorFailed = "two_factor.failed"
+	AuditActionTwoFactorLocked = "two_factor.locked"
+
+	AuditActionSessionRevoked  = "session.revoked"
+	AuditActionSessionsRevoked = "session.others_revoked"
+
+	AuditActionAPIKeyCreated = "api_key.created"
+	AuditActionAPIKeyRevoked = "api_key.revoked"
+
+	AuditActionWebhookCreated = "webhook.created"
+	AuditActionWebhookDeleted = "webhook.deleted"
+
+	AuditActionTransactionReversed = "transaction.reversed"
+)
+
+// AuditLog records a security relevant event. UserID is empty for events without a known user.
+type AuditLog struct {
+	database.BaseModel
+
+	UserID    *uuid.UUID     `json:"user_id" gorm:"type:uuid;index"`
+	Action    string         `json:"action" gorm:"type:varchar(64);not null;index"`
+	IPAddress string         `json:"ip_address" gorm:"type:varchar(45)"`
+	UserAgent string         `json:"user_agent" gorm:"type:varchar(255)"`
+	Metadata  datatypes.JSON `json:"metadata"`
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"slices"
+
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -10,14 +12,73 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+const (
+	AccountTypeWallet  = "wallet"
+	AccountTypeFee     = "fee"
+	AccountTypeReserve = "reserve"
+	AccountTypeFx      = "fx"
+	AccountTypeFxPnl   = "fx_pnl"
+)
+
+// Account states. A frozen_debit account can still receive money, a frozen_all account can
+// neither send nor receive it, and a closed account is final.
+const (
+	AccountStatusActive      = "active"
+	AccountStatusFrozenDebit = "frozen_debit"
+	AccountStatusFrozenAll   = "frozen_all"
+	AccountStatusClosed      = "closed"
+)
+
+// accountStatusTransitions lists the states an account may move to with a status change.
+// Closing is a separate operation, as the balance has to be settled first.
+var accountStatusTransitions = map[string][]string{
+	AccountStatusActive:      {AccountStatusFrozenDebit, AccountStatusFrozenAll},
+	AccountStatusFrozenDebit: {AccountStatusActive, AccountStatusFrozenAll},
+	AccountStatusFrozenAll:   {AccountStatusActive, AccountStatusFrozenDebit},
+}
+
 type Account struct {
 	database.BaseModel
 
 	UserID      *uuid.UUID      `json:"user_id" gorm:"index:idx_user_id;type:uuid"`
 	AccountType string          `json:"account_type" gorm:"default:'wallet';not null"`
-	Currency    string          `json:"currency" gorm:"type:varchar(10);default:'NGN';not null"`
-	Balance     decimal.Decimal `json:"balance" gorm:"not null; type:decimal(32,2)"`
+	Currency    string          `json:"currency" gorm:"type:varchar(3);default:'NGN';not null"`
+	Balance     decimal.Decimal `json:"balance" gorm:"not null; type:decimal(32,4)"`
+	HeldBalance decimal.Decimal `json:"held_balance" gorm:"not null;type:decimal(32,4);default:0"`
 	Number      string          `json:"number" gorm:"type:varchar(20);not null"`
+	Status      string          `json:"status" gorm:"type:varchar(16);not null;default:'active'"`
+	Version     int64           `json:"version" gorm:"not null;default:0"`
+}
+
+// CanDebit reports whether money may leave the account.
+func (a *Account) CanDebit() bool {
+	return a.Status == AccountStatusActive
+}
+
+// CanCredit reports whether money may be paid into the account.
+func (a *Account) CanCredit() bool {
+	return a.Status == AccountStatusActive || a.Status == AccountStatusFrozenDebit
+}
+
+// CanTransitionTo reports whether the account may change from its current status to status.
+func (a *Account) CanTransitionTo(status string) bool {
+	return slices.Contains(accountStatusTransitions[a.Status], status)
+}
+
+// AccountStatusChange records one transition of an account's state and why it was made.
+type AccountStatusChange struct {
+	database.BaseModel
+
+	AccountID  uuid.UUID  `json:"account_id" gorm:"type:uuid;not null;index"`
+	ActorID    *uuid.UUID `json:"actor_id" gorm:"type:uuid"`
+	FromStatus string     `json:"from_status" gorm:"type:varchar(16);not null"`
+	ToStatus   string     `json:"to_status" gorm:"type:varchar(16);not null"`
+	Reason     string     `json:"reason" gorm:"type:varchar(255);not null"`
+}
+
+// AvailableBalance is the balance that can be spent, excluding funds reserved by active holds.
+func (a *Account) AvailableBalance() decimal.Decimal {
+	return a.Balance.Sub(a.HeldBalance)
 }
 
 type TransactionType string
@@ -30,27 +91,44 @@ const (
 	TransactionFailed    string          = "failed"
 )
 
+// Operations describe the business action a journal transaction was posted for.
+const (
+	OperationFunding    = "funding"
+	OperationWithdrawal = "withdrawal"
+	OperationTransfer   = "transfer"
+	OperationReversal   = "reversal"
+	OperationConversion = "conversion"
+	OperationClosure    = "closure"
+	// OperationHold is an authorization, which is limited like a withdrawal and settles as one when captured
+	OperationHold = "hold"
+)
+
 type Transaction struct {
 	database.BaseModel
 
-	UserID      uuid.UUID       `json:"user_id" gorm:"type:uuid"`
-	Reference   string          `json:"reference" gorm:"not null"`
-	Type        TransactionType `json:"type" gorm:"type:enum('credit','debit');not null"`
-	Status      string          `json:"status" gorm:"type:enum('pending','completed','failed');default:'pending';not null"`
-	Amount      decimal.Decimal `json:"amount" gorm:"not null, type:decimal(32,2)"`
-	Currency    string          `json:"currency" gorm:"type:varchar(10);default:'NGN';not null"`
-	Description string          `json:"description" gorm:"type:varchar(255);not null"`
-	Metadata    datatypes.JSON
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	Reference string    `json:"reference" gorm:"not null"`
+	// IdempotencyKey is the Idempotency-Key the transaction was posted for, unique per user.
+	IdempotencyKey *string         `json:"-" gorm:"type:varchar(255)"`
+	Type           TransactionType `json:"type" gorm:"type:enum('credit','debit');not null"`
+	Status         string          `json:"status" gorm:"type:enum('pending','completed','failed');default:'pending';not null"`
+	Operation      string          `json:"operation" gorm:"type:varchar(32);not null"`
+	Amount         decimal.Decimal `json:"amount" gorm:"not null, type:decimal(32,4)"`
+	Currency       string          `json:"currency" gorm:"type:varchar(3);default:'NGN';not null"`
+	Description    string          `json:"description" gorm:"type:varchar(255);not null"`
+	Metadata       datatypes.JSON
 }
 
+// LedgerEntry is a single posting of a journal transaction. Postings of system
+// accounts (reserve, fee) have no user.
 type LedgerEntry struct {
 	database.BaseModel
 
-	UserID        uuid.UUID       `json:"user_id" gorm:"type:uuid"`
+	UserID        *uuid.UUID      `json:"user_id" gorm:"type:uuid"`
 	AccountID     uuid.UUID       `json:"account_id" gorm:"type:uuid"`
 	TransactionID uuid.UUID       `json:"transaction_id" gorm:"type:uuid"`
 	EntryType     string          `json:"entry_type" gorm:"type:enum('debit','credit');not null"`
-	Amount        decimal.Decimal `json:"amount" gorm:"not null; type:decimal(32,2)"`
+	Amount        decimal.Decimal `json:"amount" gorm:"not null; type:decimal(32,4)"`
 	Description   string          `json:"description" gorm:"type:varchar(255);not null"`
 }
 
@@ -58,9 +136,9 @@ type ReconciliationLog struct {
 	database.BaseModel
 
 	AccountID       uuid.UUID       `json:"account_id"  gorm:"type:uuid; not null"`
-	ComputedBalance decimal.Decimal `json:"computed_balance" gorm:"type:decimal(32,2);not null"`
-	StoredBalance   decimal.Decimal `json:"stored_balance" gorm:"type:decimal(32,2);not null"`
-	Discrepancy     decimal.Decimal `json:"discrepancy" gorm:"type:decimal(32, 2);generated always as (actual_balance - computed_balance) stored"`
+	ComputedBalance decimal.Decimal `json:"computed_balance" gorm:"type:decimal(32,4);not null"`
+	StoredBalance   decimal.Decimal `json:"stored_balance" gorm:"type:decimal(32,4);not null"`
+	Discrepancy     decimal.Decimal `json:"discrepancy" gorm:"->;type:decimal(32,4)"`
 }
 
 func (t *Transaction) BeforeCreate(tx *gorm.DB) (err error) {
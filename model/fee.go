@@ -0,0 +1,38 @@
+package model
+
+import (
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+const (
+	FeeKindFlat       = "flat"
+	FeeKindPercentage = "percentage"
+	FeeKindTiered     = "tiered"
+)
+
+// FeeRule is the fee charged for one operation in one currency. Percentages are fractions,
+// 0.015 is 1.5%. A zero MaxFee means the fee is not capped.
+type FeeRule struct {
+	database.BaseModel
+
+	Operation  string          `json:"operation" gorm:"type:varchar(32);not null;uniqueIndex:idx_fee_rules_operation_currency"`
+	Currency   string          `json:"currency" gorm:"type:varchar(3);not null;uniqueIndex:idx_fee_rules_operation_currency"`
+	Kind       string          `json:"kind" gorm:"type:enum('flat','percentage','tiered');not null"`
+	FlatAmount decimal.Decimal `json:"flat_amount" gorm:"not null;type:decimal(32,4);default:0"`
+	Percentage decimal.Decimal `json:"percentage" gorm:"not null;type:decimal(10,6);default:0"`
+	MinFee     decimal.Decimal `json:"min_fee" gorm:"not null;type:decimal(32,4);default:0"`
+	MaxFee     decimal.Decimal `json:"max_fee" gorm:"not null;type:decimal(32,4);default:0"`
+	Tiers      datatypes.JSON  `json:"tiers"`
+	Active     bool            `json:"active" gorm:"not null;default:true"`
+}
+
+// FeeTier applies to amounts up to and including UpTo, the last tier may leave UpTo at zero
+// to cover every larger amount. Each tier charges its flat amount plus its percentage.
+type FeeTier struct {
+	UpTo       decimal.Decimal `json:"up_to"`
+	FlatAmount decimal.Decimal `json:"flat_amount"`
+	Percentage decimal.Decimal `json:"percentage"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldVoided   = "voided"
+	HoldExpired  = "expired"
+)
+
+// Hold reserves part of a wallet's balance for a pending transaction until it is
+// captured, voided or expires.
+type Hold struct {
+	database.BaseModel
+
+	UserID         uuid.UUID       `json:"user_id" gorm:"type:uuid;not null"`
+	AccountID      uuid.UUID       `json:"account_id" gorm:"type:uuid;not null"`
+	TransactionID  uuid.UUID       `json:"transaction_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Amount         decimal.Decimal `json:"amount" gorm:"not null;type:decimal(32,4)"`
+	CapturedAmount decimal.Decimal `json:"captured_amount" gorm:"not null;type:decimal(32,4)"`
+	Status         string          `json:"status" gorm:"type:enum('active','captured','voided','expired');default:'active';not null"`
+	ExpiresAt      time.Time       `json:"expires_at" gorm:"not null;index"`
+}
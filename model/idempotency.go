@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+type IdempotencyKey struct {
+	database.BaseModel
+
+	UserID               uuid.UUID      `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_user_key"`
+	Key                  string         `json:"key" gorm:"column:idempotency_key;type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash          string         `json:"request_hash" gorm:"type:char(64);not null"`
+	Status               string         `json:"status" gorm:"type:enum('processing','completed');default:'processing';not null"`
+	ResponseStatus       int            `json:"response_status"`
+	ResponseBody         datatypes.JSON `json:"response_body"`
+	TransactionReference string         `json:"transaction_reference" gorm:"type:varchar(255)"`
+	ExpiresAt            time.Time      `json:"expires_at" gorm:"not null;index"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// LimitRule caps an operation in one currency for every user of a KYC level, or for a single
+// user when UserID is set. A user rule replaces the rule of the user's KYC level.
+// Zero amounts and counts mean the corresponding limit is not enforced.
+type LimitRule struct {
+	database.BaseModel
+
+	UserID                *uuid.UUID      `json:"user_id" gorm:"type:uuid;index"`
+	KycLevel              int             `json:"kyc_level" gorm:"not null;default:0"`
+	Operation             string          `json:"operation" gorm:"type:varchar(32);not null"`
+	Currency              string          `json:"currency" gorm:"type:varchar(3);not null"`
+	PerTransaction        decimal.Decimal `json:"per_transaction" gorm:"not null;type:decimal(32,4);default:0"`
+	Daily                 decimal.Decimal `json:"daily" gorm:"not null;type:decimal(32,4);default:0"`
+	Monthly               decimal.Decimal `json:"monthly" gorm:"not null;type:decimal(32,4);default:0"`
+	VelocityCount         int             `json:"velocity_count" gorm:"not null;default:0"`
+	VelocityWindowMinutes int             `json:"velocity_window_minutes" gorm:"not null;default:0"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// Events published to RabbitMQ, one per wallet operation. The routing key is <type>.v<version>.
+const (
+	EventWalletFunded         = "wallet.funded"
+	EventWalletWithdrawn      = "wallet.withdrawn"
+	EventTransferCompleted    = "transfer.completed"
+	EventTransactionReversed  = "transaction.reversed"
+	EventHoldAuthorized       = "hold.authorized"
+	EventHoldCaptured         = "hold.captured"
+	EventHoldVoided           = "hold.voided"
+	EventHoldExpired          = "hold.expired"
+	EventFundsConverted       = "funds.converted"
+	EventAccountStatusChanged = "account.status_changed"
+)
+
+// EventVersions is the current schema version of each event. A breaking change to an event's data
+// gets a new version, and with it a new routing key, so consumers can move over at their own pace.
+var EventVersions = map[string]int{
+	EventWalletFunded:         1,
+	EventWalletWithdrawn:      1,
+	EventTransferCompleted:    1,
+	EventTransactionReversed:  1,
+	EventHoldAuthorized:       1,
+	EventHoldCaptured:         1,
+	EventHoldVoided:           1,
+	EventHoldExpired:          1,
+	EventFundsConverted:       1,
+	EventAccountStatusChanged: 1,
+}
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	// OutboxUnroutable is an event the broker returned because no queue is bound for it. It is
+	// not retried, so it cannot hold up the events behind it; set it back to pending once bound.
+	OutboxUnroutable = "unroutable"
+)
+
+// OutboxEvent is an event waiting to be published. It is written in the same DB transaction as
+// the operation it reports, and the relay publishes it once the broker confirms it, so an event
+// exists exactly when the operation was committed. ClaimedUntil is set by the instance
+// publishing it so that other instances skip it.
+type OutboxEvent struct {
+	database.BaseModel
+
+	EventID       uuid.UUID  `json:"event_id" gorm:"type:uuid;not null"`
+	EventType     string     `json:"event_type" gorm:"type:varchar(64);not null"`
+	Version       int        `json:"version" gorm:"not null"`
+	RoutingKey    string     `json:"routing_key" gorm:"type:varchar(128);not null"`
+	Payload       string     `json:"payload" gorm:"type:json;not null"`
+	Status        string     `json:"status" gorm:"type:enum('pending','sent','unroutable');default:'pending';not null"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null"`
+	LastError     string     `json:"last_error" gorm:"type:varchar(255)"`
+	SentAt        *time.Time `json:"sent_at"`
+	ClaimedUntil  *time.Time `json:"-"`
+}
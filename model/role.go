@@ -0,0 +1,46 @@
+package model
+
+// Roles a user can have, every new user starts as RoleUser.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// Permissions granted by roles, named <resource>:<action>.
+const (
+	PermissionUsersRead           = "users:read"
+	PermissionUsersManage         = "users:manage"
+	PermissionWalletsRead         = "wallets:read"
+	PermissionAccountsManage      = "accounts:manage"
+	PermissionTransactionsReverse = "transactions:reverse"
+	PermissionLimitsManage        = "limits:manage"
+	PermissionReconciliationRead  = "reconciliation:read"
+	PermissionSystemMonitor       = "system:monitor"
+)
+
+// RolePermissions lists what each role may do. Support staff can look but not change anything.
+var RolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleSupport: {
+		PermissionUsersRead,
+		PermissionWalletsRead,
+		PermissionReconciliationRead,
+	},
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersManage,
+		PermissionWalletsRead,
+		PermissionAccountsManage,
+		PermissionTransactionsReverse,
+		PermissionLimitsManage,
+		PermissionReconciliationRead,
+		PermissionSystemMonitor,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
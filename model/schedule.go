@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCompleted = "completed"
+)
+
+const (
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+// ScheduledTransfer is a transfer run by the cron service at NextRunAt. It runs once when neither
+// CronExpression nor IntervalMinutes is set, otherwise it recurs. While a failed run waits to be
+// retried, PlannedRunAt keeps the occurrence it is for. ClaimedUntil is set by the instance
+// executing it so that other instances skip it.
+type ScheduledTransfer struct {
+	database.BaseModel
+
+	UserID          uuid.UUID       `json:"user_id" gorm:"type:uuid;not null;index"`
+	ToAccountNumber string          `json:"to_account_number" gorm:"type:varchar(20);not null"`
+	Currency        string          `json:"currency" gorm:"type:varchar(3);not null"`
+	Amount          decimal.Decimal `json:"amount" gorm:"not null;type:decimal(32,4)"`
+	Description     string          `json:"description" gorm:"type:varchar(255)"`
+	CronExpression  string          `json:"cron_expression" gorm:"type:varchar(100)"`
+	IntervalMinutes int             `json:"interval_minutes" gorm:"not null;default:0"`
+	NextRunAt       time.Time       `json:"next_run_at" gorm:"not null;index"`
+	PlannedRunAt    *time.Time      `json:"planned_run_at"`
+	LastRunAt       *time.Time      `json:"last_run_at"`
+	Status          string          `json:"status" gorm:"type:enum('active','paused','completed');default:'active';not null"`
+	FailureCount    int             `json:"failure_count" gorm:"not null;default:0"`
+	LastError       string          `json:"last_error" gorm:"type:varchar(255)"`
+	ClaimedUntil    *time.Time      `json:"-"`
+}
+
+// IsRecurring reports whether the schedule runs more than once.
+func (s *ScheduledTransfer) IsRecurring() bool {
+	return s.CronExpression != "" || s.IntervalMinutes > 0
+}
+
+// Occurrence is the planned time of the run that is due, which NextRunAt moves past while the run
+// is retried.
+func (s *ScheduledTransfer) Occurrence() time.Time {
+	if s.PlannedRunAt != nil {
+		return *s.PlannedRunAt
+	}
+	return s.NextRunAt
+}
+
+// ScheduledTransferRun records one execution attempt of a schedule.
+type ScheduledTransferRun struct {
+	database.BaseModel
+
+	ScheduleID           uuid.UUID `json:"schedule_id" gorm:"type:uuid;not null;index"`
+	Status               string    `json:"status" gorm:"type:enum('succeeded','failed');not null"`
+	TransactionReference string    `json:"transaction_reference" gorm:"type:varchar(255)"`
+	Error                string    `json:"error" gorm:"type:varchar(255)"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// Session records one login, so users can see where they are signed in. Its ID is the sid claim of
+// the session's tokens. Whether a session still works is decided by the token store in Redis; the
+// record keeps the device details and when the session was revoked.
+type Session struct {
+	database.BaseModel
+
+	UserID     uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	DeviceName string     `json:"device_name" gorm:"type:varchar(100);not null"`
+	UserAgent  string     `json:"user_agent" gorm:"type:varchar(255)"`
+	IPAddress  string     `json:"ip_address" gorm:"type:varchar(45)"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// SigningKey is an asymmetric key tokens are signed with, its ID is the kid header. The newest key
+// whose ActivatesAt has passed signs new tokens; a newer key is published ahead of ActivatesAt so
+// verifiers can fetch it first, and an older one stays published until its tokens have expired.
+// PrivateKey is the PEM key encrypted with JWT_KEY_ENCRYPTION_KEY.
+type SigningKey struct {
+	database.BaseModel
+
+	Algorithm   string    `json:"algorithm" gorm:"type:varchar(16);not null;index"`
+	PrivateKey  string    `json:"-" gorm:"type:text;not null"`
+	ActivatesAt time.Time `json:"activates_at" gorm:"not null"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the authenticator is lost.
+// Only its argon2id hash is stored.
+type RecoveryCode struct {
+	database.BaseModel
+
+	UserID   uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	CodeHash string     `json:"-" gorm:"type:varchar(255);not null"`
+	UsedAt   *time.Time `json:"used_at"`
+}
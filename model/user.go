@@ -2,7 +2,9 @@ package model
 
 import (
 	"strings"
+	"time"
 
+	"github.com/horlakz/wallet-sync.api/internal/constants"
 	"github.com/horlakz/wallet-sync.api/internal/helper"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/shopspring/decimal"
@@ -12,22 +14,58 @@ import (
 type User struct {
 	database.BaseModel
 
-	Name     string `json:"name" gorm:"not null"`
-	Email    string `json:"email" gorm:"not null"`
-	Password string `json:"password" gorm:"not null"`
+	Name       string     `json:"name" gorm:"not null"`
+	Email      string     `json:"email" gorm:"not null"`
+	Password   string     `json:"password" gorm:"not null"`
+	Role       string     `json:"role" gorm:"type:varchar(32);not null;default:'user'"`
+	KycLevel   int        `json:"kyc_level" gorm:"not null;default:0"`
+	VerifiedAt *time.Time `json:"verified_at"`
+
+	// TotpSecret is set during enrolment, encrypted with JWT_KEY_ENCRYPTION_KEY, two-factor
+	// authentication is on once TotpEnabledAt is set
+	TotpSecret    string     `json:"-" gorm:"type:varchar(255)"`
+	TotpEnabledAt *time.Time `json:"totp_enabled_at"`
+
+	// PinHash is the argon2id hash of the transaction PIN that authorizes withdrawals and transfers
+	PinHash  string     `json:"-" gorm:"type:varchar(255)"`
+	PinSetAt *time.Time `json:"pin_set_at"`
 }
 
-func (u *User) AfterCreate(tx *gorm.DB) (err error) {
-	// convert email to lowercase before saving
-	u.Email = strings.ToLower(u.Email)
+// TwoFactorEnabled reports whether the user confirmed a TOTP authenticator.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TotpEnabledAt != nil
+}
+
+// Permissions returns the permissions granted by the user's role.
+func (u *User) Permissions() []string {
+	return RolePermissions[u.Role]
+}
+
+// HasPin reports whether the user set a transaction PIN.
+func (u *User) HasPin() bool {
+	return u.PinHash != ""
+}
 
+// NormalizeEmail is the form emails are stored and looked up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (u *User) BeforeSave(tx *gorm.DB) (err error) {
+	u.Email = NormalizeEmail(u.Email)
+
+	return nil
+}
+
+func (u *User) AfterCreate(tx *gorm.DB) (err error) {
 	// create account model
 	account := &Account{
 		UserID:      &u.ID,
-		AccountType: "wallet",
-		Currency:    "NGN",
+		AccountType: AccountTypeWallet,
+		Currency:    constants.DEFAULT_CURRENCY,
 		Balance:     decimal.NewFromFloat(0.00),
 		Number:      helper.GenerateAccountNumber(),
+		Status:      AccountStatusActive,
 	}
 
 	if err := tx.Create(account).Error; err != nil {
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// Wallet events a webhook endpoint can subscribe to.
+const (
+	WebhookEventWalletFunded        = "wallet.funded"
+	WebhookEventWalletWithdrawn     = "wallet.withdrawn"
+	WebhookEventTransferSent        = "transfer.sent"
+	WebhookEventTransferReceived    = "transfer.received"
+	WebhookEventTransactionReversed = "transaction.reversed"
+)
+
+// WebhookEvents lists every event an endpoint can subscribe to.
+var WebhookEvents = []string{
+	WebhookEventWalletFunded,
+	WebhookEventWalletWithdrawn,
+	WebhookEventTransferSent,
+	WebhookEventTransferReceived,
+	WebhookEventTransactionReversed,
+}
+
+// Delivery states. A pending delivery is retried with backoff until it is delivered, or until it
+// runs out of attempts and is dead, which only a manual redelivery brings back.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookEndpoint is a URL of the user's that receives the events it subscribes to. Secret keys
+// the HMAC signature of every payload sent to it.
+type WebhookEndpoint struct {
+	database.BaseModel
+
+	UserID      uuid.UUID                   `json:"user_id" gorm:"type:uuid;not null;index"`
+	URL         string                      `json:"url" gorm:"type:varchar(2048);not null"`
+	Description string                      `json:"description" gorm:"type:varchar(255)"`
+	Events      datatypes.JSONSlice[string] `json:"events" gorm:"type:json;not null"`
+	Secret      string                      `json:"-" gorm:"type:varchar(64);not null"`
+	Active      bool                        `json:"active" gorm:"not null;default:true"`
+}
+
+// Subscribes reports whether the endpoint receives event.
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	return slices.Contains(e.Events, event)
+}
+
+// WebhookDelivery is one event to send to one endpoint. It is written in the same DB transaction as
+// the money movement it reports, so an event exists exactly when the movement was committed. Every
+// endpoint receiving an event gets its own delivery with the same EventID. ClaimedUntil is set by the
+// instance sending it so that other instances skip it.
+type WebhookDelivery struct {
+	database.BaseModel
+
+	EndpointID    uuid.UUID  `json:"endpoint_id" gorm:"type:uuid;not null;index"`
+	EventID       uuid.UUID  `json:"event_id" gorm:"type:uuid;not null"`
+	EventType     string     `json:"event_type" gorm:"type:varchar(64);not null"`
+	Payload       string     `json:"payload" gorm:"type:json;not null"`
+	Status        string     `json:"status" gorm:"type:enum('pending','delivered','dead');default:'pending';not null"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	LastError     string     `json:"last_error" gorm:"type:varchar(255)"`
+	ClaimedUntil  *time.Time `json:"-"`
+}
+
+// WebhookAttempt records one request made for a delivery and what the endpoint answered.
+type WebhookAttempt struct {
+	database.BaseModel
+
+	DeliveryID   uuid.UUID `json:"delivery_id" gorm:"type:uuid;not null;index"`
+	StatusCode   int       `json:"status_code" gorm:"not null;default:0"`
+	ResponseBody string    `json:"response_body" gorm:"type:text"`
+	Error        string    `json:"error" gorm:"type:varchar(255)"`
+	DurationMs   int64     `json:"duration_ms" gorm:"not null;default:0"`
+}
@@ -0,0 +1,36 @@
+package request
+
+type TransactionReverseRequest struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+type LimitRuleRequest struct {
+	UserID                string  `json:"user_id"`
+	KycLevel              int     `json:"kyc_level"`
+	Operation             string  `json:"operation"`
+	Currency              string  `json:"currency"`
+	PerTransaction        float64 `json:"per_transaction"`
+	Daily                 float64 `json:"daily"`
+	Monthly               float64 `json:"monthly"`
+	VelocityCount         int     `json:"velocity_count"`
+	VelocityWindowMinutes int     `json:"velocity_window_minutes"`
+}
+
+type KycLevelRequest struct {
+	KycLevel int `json:"kyc_level"`
+}
+
+type RoleRequest struct {
+	Role string `json:"role"`
+}
+
+type AccountStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type AccountCloseRequest struct {
+	Reason               string `json:"reason"`
+	SweepToAccountNumber string `json:"sweep_to_account_number"`
+}
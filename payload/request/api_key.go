@@ -0,0 +1,8 @@
+package request
+
+type APIKeyRequest struct {
+	Name             string   `json:"name"`
+	Scopes           []string `json:"scopes"`
+	AllowedIPs       []string `json:"allowed_ips"`
+	RequireSignature bool     `json:"require_signature"`
+}
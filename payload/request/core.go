@@ -1,14 +1,77 @@
 package request
 
+type WalletOpenRequest struct {
+	Currency string `json:"currency"`
+}
+
 type WalletFundRequest struct {
-	Amount float64 `json:"amount"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
 }
 
 type WalletWithdrawRequest struct {
-	Amount float64 `json:"amount"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Pin      string  `json:"pin"`
 }
 
 type WalletTransferRequest struct {
 	ToAccountNumber string  `json:"to_account_number"`
 	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	QuoteID         string  `json:"quote_id"`
+	Pin             string  `json:"pin"`
+}
+
+type WalletHoldRequest struct {
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Description string  `json:"description"`
+	Pin         string  `json:"pin"`
+}
+
+type WalletCaptureRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+type WalletQuoteRequest struct {
+	FromCurrency string  `json:"from_currency"`
+	ToCurrency   string  `json:"to_currency"`
+	Amount       float64 `json:"amount"`
+}
+
+type WalletConvertRequest struct {
+	QuoteID string `json:"quote_id"`
+}
+
+type WalletFeeQuoteRequest struct {
+	Operation string  `json:"operation"`
+	Currency  string  `json:"currency"`
+	Amount    float64 `json:"amount"`
+}
+
+type ScheduledTransferRequest struct {
+	ToAccountNumber string  `json:"to_account_number"`
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	Description     string  `json:"description"`
+	RunAt           string  `json:"run_at"`
+	Cron            string  `json:"cron"`
+	IntervalMinutes int     `json:"interval_minutes"`
+	Pin             string  `json:"pin"`
+}
+
+type WalletSetPinRequest struct {
+	Pin string `json:"pin"`
+}
+
+type WalletChangePinRequest struct {
+	CurrentPin string `json:"current_pin"`
+	NewPin     string `json:"new_pin"`
+}
+
+type WalletResetPinRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+	NewPin   string `json:"new_pin"`
 }
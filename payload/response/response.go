@@ -3,6 +3,7 @@ package response
 type Response struct {
 	Status  int    `json:"status"`
 	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
 	Data    any    `json:"data,omitempty"`
 	Meta    any    `json:"meta,omitempty"`
 }
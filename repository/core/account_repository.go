@@ -1,20 +1,36 @@
 package core_repository
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/google/uuid"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/model"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrStaleAccount is returned when an account was modified after it was read.
+var ErrStaleAccount = errors.New("account was modified by another transaction")
+
 type AccountRepository interface {
 	CreateAccount(account *model.Account) error
 	GetAccountByUserID(userID uuid.UUID) (*model.Account, error)
-	GetWalletAccountByUserID(userID uuid.UUID) (*model.Account, error)
+	GetWalletAccountByUserID(userID uuid.UUID, currency string) (*model.Account, error)
+	GetWalletAccountsByUserID(userID uuid.UUID) ([]*model.Account, error)
 	GetAccountByNumber(accountNumber string) (*model.Account, error)
-	UpdateAccountBalance(userID uuid.UUID, amount decimal.Decimal) error
+	GetAccountByID(accountID uuid.UUID) (*model.Account, error)
+	GetAccountByIDForUpdate(accountID uuid.UUID) (*model.Account, error)
+	UpdateAccountStatus(accountID uuid.UUID, status string) error
+	CreateStatusChange(change *model.AccountStatusChange) error
+	GetStatusChanges(accountID uuid.UUID) ([]model.AccountStatusChange, error)
+	UpdateAccountBalance(account *model.Account, amount decimal.Decimal) error
+	UpdateAccountHeldBalance(account *model.Account, amount decimal.Decimal) error
 	GetAllAccounts() ([]*model.Account, error)
+	GetOrCreateSystemAccount(accountType string, currency string) (*model.Account, error)
 	WithTx(tx *gorm.DB) AccountRepository
 }
 
@@ -43,15 +59,24 @@ func (r *accountRepository) GetAccountByUserID(userID uuid.UUID) (*model.Account
 	return &account, nil
 }
 
-func (r *accountRepository) GetWalletAccountByUserID(userID uuid.UUID) (*model.Account, error) {
+func (r *accountRepository) GetWalletAccountByUserID(userID uuid.UUID, currency string) (*model.Account, error) {
 	var account model.Account
-	err := r.db.Connection().Where("user_id = ? AND account_type = ?", userID, "wallet").First(&account).Error
+	err := r.db.Connection().Where("user_id = ? AND account_type = ? AND currency = ?", userID, model.AccountTypeWallet, currency).First(&account).Error
 	if err != nil {
 		return nil, err
 	}
 	return &account, nil
 }
 
+func (r *accountRepository) GetWalletAccountsByUserID(userID uuid.UUID) ([]*model.Account, error) {
+	var accounts []*model.Account
+	err := r.db.Connection().Where("user_id = ? AND account_type = ?", userID, model.AccountTypeWallet).Order("created_at").Find(&accounts).Error
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
 func (r *accountRepository) GetAccountByNumber(accountNumber string) (*model.Account, error) {
 	var account model.Account
 	err := r.db.Connection().Where("number = ?", accountNumber).First(&account).Error
@@ -61,15 +86,102 @@ func (r *accountRepository) GetAccountByNumber(accountNumber string) (*model.Acc
 	return &account, nil
 }
 
-func (r *accountRepository) UpdateAccountBalance(userID uuid.UUID, amount decimal.Decimal) error {
+func (r *accountRepository) GetAccountByID(accountID uuid.UUID) (*model.Account, error) {
 	var account model.Account
-	err := r.db.Connection().Where("user_id = ?", userID).First(&account).Error
+	err := r.db.Connection().Where("id = ?", accountID).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetAccountByIDForUpdate reads the account with SELECT ... FOR UPDATE, it must be called inside a transaction.
+func (r *accountRepository) GetAccountByIDForUpdate(accountID uuid.UUID) (*model.Account, error) {
+	var account model.Account
+	err := r.db.Connection().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", accountID).First(&account).Error
 	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// UpdateAccountBalance adds amount to the balance of the given account. The write only
+// succeeds if the stored version still matches the one that was read, otherwise
+// ErrStaleAccount is returned and the caller should retry with a fresh read.
+func (r *accountRepository) UpdateAccountBalance(account *model.Account, amount decimal.Decimal) error {
+	balance := account.Balance.Add(amount)
+
+	if err := r.updateVersioned(account, "balance", balance); err != nil {
 		return err
 	}
 
-	account.Balance = account.Balance.Add(amount)
-	return r.db.Connection().Save(&account).Error
+	account.Balance = balance
+	return nil
+}
+
+// UpdateAccountHeldBalance adds amount to the funds reserved by holds, with the same version check as UpdateAccountBalance.
+func (r *accountRepository) UpdateAccountHeldBalance(account *model.Account, amount decimal.Decimal) error {
+	heldBalance := account.HeldBalance.Add(amount)
+
+	if err := r.updateVersioned(account, "held_balance", heldBalance); err != nil {
+		return err
+	}
+
+	account.HeldBalance = heldBalance
+	return nil
+}
+
+// UpdateAccountStatus sets the status and bumps the version, so a concurrent balance update of the account retries.
+func (r *accountRepository) UpdateAccountStatus(accountID uuid.UUID, status string) error {
+	result := r.db.Connection().
+		Model(&model.Account{}).
+		Where("id = ?", accountID).
+		Updates(map[string]interface{}{
+			"status":  status,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *accountRepository) CreateStatusChange(change *model.AccountStatusChange) error {
+	return r.db.Connection().Create(change).Error
+}
+
+// GetStatusChanges returns the state transitions of the account, oldest first.
+func (r *accountRepository) GetStatusChanges(accountID uuid.UUID) ([]model.AccountStatusChange, error) {
+	var changes []model.AccountStatusChange
+	err := r.db.Connection().Where("account_id = ?", accountID).Order("created_at").Find(&changes).Error
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *accountRepository) updateVersioned(account *model.Account, column string, value decimal.Decimal) error {
+	result := r.db.Connection().
+		Model(&model.Account{}).
+		Where("id = ? AND version = ?", account.ID, account.Version).
+		Updates(map[string]interface{}{
+			column:    value,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrStaleAccount
+	}
+
+	account.Version++
+	return nil
 }
 
 func (r *accountRepository) GetAllAccounts() ([]*model.Account, error) {
@@ -80,3 +192,23 @@ func (r *accountRepository) GetAllAccounts() ([]*model.Account, error) {
 	}
 	return accounts, nil
 }
+
+// GetOrCreateSystemAccount returns the system account (reserve, fee) of the given type and currency,
+// creating it on first use. System accounts have no user and a deterministic number, so concurrent
+// callers converge on the same row.
+func (r *accountRepository) GetOrCreateSystemAccount(accountType string, currency string) (*model.Account, error) {
+	account := &model.Account{
+		AccountType: accountType,
+		Currency:    currency,
+		Balance:     decimal.Zero,
+		Number:      fmt.Sprintf("SYS-%s-%s", strings.ToUpper(accountType), currency),
+		Status:      model.AccountStatusActive,
+	}
+
+	err := r.db.Connection().Clauses(clause.OnConflict{DoNothing: true}).Create(account).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetAccountByNumber(account.Number)
+}
@@ -0,0 +1,22 @@
+package core_repository
+
+import (
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type AuditLogRepository interface {
+	Create(log *model.AuditLog) error
+}
+
+type auditLogRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewAuditLogRepository(db database.DatabaseInterface) AuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+func (r *auditLogRepository) Create(log *model.AuditLog) error {
+	return r.db.Connection().Create(log).Error
+}
@@ -0,0 +1,27 @@
+package core_repository
+
+import (
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type FeeRuleRepository interface {
+	GetActiveFeeRule(operation string, currency string) (*model.FeeRule, error)
+}
+
+type feeRuleRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewFeeRuleRepository(db database.DatabaseInterface) FeeRuleRepository {
+	return &feeRuleRepository{db: db}
+}
+
+func (r *feeRuleRepository) GetActiveFeeRule(operation string, currency string) (*model.FeeRule, error) {
+	var rule model.FeeRule
+	err := r.db.Connection().Where("operation = ? AND currency = ? AND active = ?", operation, currency, true).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
@@ -0,0 +1,63 @@
+package core_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type HoldRepository interface {
+	CreateHold(hold *model.Hold) error
+	GetHoldByTransactionIDForUpdate(transactionID uuid.UUID) (*model.Hold, error)
+	UpdateHold(hold *model.Hold) error
+	GetExpiredHolds(before time.Time, limit int) ([]model.Hold, error)
+	WithTx(tx *gorm.DB) HoldRepository
+}
+
+type holdRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewHoldRepository(db database.DatabaseInterface) HoldRepository {
+	return &holdRepository{db: db}
+}
+
+func (r *holdRepository) WithTx(tx *gorm.DB) HoldRepository {
+	return &holdRepository{db: database.Wrap(tx)}
+}
+
+func (r *holdRepository) CreateHold(hold *model.Hold) error {
+	return r.db.Connection().Create(hold).Error
+}
+
+// GetHoldByTransactionIDForUpdate reads the hold with SELECT ... FOR UPDATE, it must be called inside a transaction.
+func (r *holdRepository) GetHoldByTransactionIDForUpdate(transactionID uuid.UUID) (*model.Hold, error) {
+	var hold model.Hold
+	err := r.db.Connection().Clauses(clause.Locking{Strength: "UPDATE"}).Where("transaction_id = ?", transactionID).First(&hold).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *holdRepository) UpdateHold(hold *model.Hold) error {
+	return r.db.Connection().Save(hold).Error
+}
+
+func (r *holdRepository) GetExpiredHolds(before time.Time, limit int) ([]model.Hold, error) {
+	var holds []model.Hold
+	err := r.db.Connection().
+		Where("status = ? AND expires_at < ?", model.HoldActive, before).
+		Order("expires_at").
+		Limit(limit).
+		Find(&holds).Error
+	if err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
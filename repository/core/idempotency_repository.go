@@ -0,0 +1,62 @@
+package core_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type IdempotencyKeyRepository interface {
+	CreateIdempotencyKey(key *model.IdempotencyKey) (bool, error)
+	GetIdempotencyKey(userID uuid.UUID, key string) (*model.IdempotencyKey, error)
+	UpdateIdempotencyKey(key *model.IdempotencyKey) error
+	DeleteIdempotencyKey(key *model.IdempotencyKey) error
+	DeleteExpiredIdempotencyKeys(before time.Time) (int64, error)
+}
+
+type idempotencyKeyRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewIdempotencyKeyRepository(db database.DatabaseInterface) IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{db: db}
+}
+
+// CreateIdempotencyKey inserts the key and reports whether this call created it.
+// A false result means another request already holds the same user/key pair.
+func (r *idempotencyKeyRepository) CreateIdempotencyKey(key *model.IdempotencyKey) (bool, error) {
+	result := r.db.Connection().Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *idempotencyKeyRepository) GetIdempotencyKey(userID uuid.UUID, key string) (*model.IdempotencyKey, error) {
+	var record model.IdempotencyKey
+	err := r.db.Connection().Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *idempotencyKeyRepository) UpdateIdempotencyKey(key *model.IdempotencyKey) error {
+	return r.db.Connection().Save(key).Error
+}
+
+func (r *idempotencyKeyRepository) DeleteIdempotencyKey(key *model.IdempotencyKey) error {
+	return r.db.Connection().Unscoped().Delete(key).Error
+}
+
+func (r *idempotencyKeyRepository) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	result := r.db.Connection().Unscoped().Where("expires_at < ?", before).Delete(&model.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
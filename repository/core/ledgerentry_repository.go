@@ -14,8 +14,10 @@ type LedgerEntryRepository interface {
 	GetLedgerEntriesByUserID(userID string) ([]model.LedgerEntry, error)
 	UpdateLedgerEntry(entry *model.LedgerEntry) error
 	GetLedgerEntryByTransactionID(transactionID uuid.UUID) (*model.LedgerEntry, error)
+	GetLedgerEntriesByTransactionID(transactionID uuid.UUID) ([]model.LedgerEntry, error)
 	GetTotalCreditsByAccountID(accountID uuid.UUID) (decimal.Decimal, error)
 	GetTotalDebitsByAccountID(accountID uuid.UUID) (decimal.Decimal, error)
+	GetLedgerTotals() (credits decimal.Decimal, debits decimal.Decimal, err error)
 	WithTx(tx *gorm.DB) LedgerEntryRepository
 }
 
@@ -57,6 +59,15 @@ func (r *ledgerEntryRepository) GetLedgerEntryByTransactionID(transactionID uuid
 	return &entry, nil
 }
 
+func (r *ledgerEntryRepository) GetLedgerEntriesByTransactionID(transactionID uuid.UUID) ([]model.LedgerEntry, error) {
+	var entries []model.LedgerEntry
+	err := r.db.Connection().Where("transaction_id = ?", transactionID).Order("created_at").Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
 func (r *ledgerEntryRepository) GetTotalCreditsByAccountID(accountID uuid.UUID) (decimal.Decimal, error) {
 	var total decimal.Decimal
 	err := r.db.Connection().
@@ -82,3 +93,22 @@ func (r *ledgerEntryRepository) GetTotalDebitsByAccountID(accountID uuid.UUID) (
 	}
 	return total, nil
 }
+
+// GetLedgerTotals sums every posting in the ledger, credits and debits must be equal.
+func (r *ledgerEntryRepository) GetLedgerTotals() (credits decimal.Decimal, debits decimal.Decimal, err error) {
+	var totals struct {
+		Credits decimal.Decimal
+		Debits  decimal.Decimal
+	}
+
+	err = r.db.Connection().
+		Model(&model.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN entry_type = ? THEN amount ELSE 0 END), 0) AS credits, "+
+			"COALESCE(SUM(CASE WHEN entry_type = ? THEN amount ELSE 0 END), 0) AS debits", model.Credit, model.Debit).
+		Scan(&totals).Error
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	return totals.Credits, totals.Debits, nil
+}
@@ -0,0 +1,77 @@
+package core_repository
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type LimitRuleRepository interface {
+	GetUserLimitRule(userID uuid.UUID, operation string, currency string) (*model.LimitRule, error)
+	GetKycLimitRule(kycLevel int, operation string, currency string) (*model.LimitRule, error)
+	ListLimitRules() ([]model.LimitRule, error)
+	SaveLimitRule(rule *model.LimitRule) error
+}
+
+type limitRuleRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewLimitRuleRepository(db database.DatabaseInterface) LimitRuleRepository {
+	return &limitRuleRepository{db: db}
+}
+
+func (r *limitRuleRepository) GetUserLimitRule(userID uuid.UUID, operation string, currency string) (*model.LimitRule, error) {
+	var rule model.LimitRule
+	err := r.db.Connection().Where("user_id = ? AND operation = ? AND currency = ?", userID, operation, currency).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *limitRuleRepository) GetKycLimitRule(kycLevel int, operation string, currency string) (*model.LimitRule, error) {
+	var rule model.LimitRule
+	err := r.db.Connection().Where("user_id IS NULL AND kyc_level = ? AND operation = ? AND currency = ?", kycLevel, operation, currency).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *limitRuleRepository) ListLimitRules() ([]model.LimitRule, error) {
+	var rules []model.LimitRule
+	err := r.db.Connection().Order("kyc_level, operation, currency").Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveLimitRule inserts the rule, or updates the limits of the rule already stored for the same user
+// or KYC level, operation and currency, and loads the stored rule back into rule.
+func (r *limitRuleRepository) SaveLimitRule(rule *model.LimitRule) error {
+	err := r.db.Connection().Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{
+			"per_transaction", "daily", "monthly", "velocity_count", "velocity_window_minutes", "updated_at",
+		}),
+	}).Create(rule).Error
+	if err != nil {
+		return err
+	}
+
+	var stored *model.LimitRule
+	if rule.UserID != nil {
+		stored, err = r.GetUserLimitRule(*rule.UserID, rule.Operation, rule.Currency)
+	} else {
+		stored, err = r.GetKycLimitRule(rule.KycLevel, rule.Operation, rule.Currency)
+	}
+	if err != nil {
+		return err
+	}
+
+	*rule = *stored
+	return nil
+}
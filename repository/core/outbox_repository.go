@@ -0,0 +1,80 @@
+package core_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type OutboxRepository interface {
+	CreateEvent(event *model.OutboxEvent) error
+	GetDueEvents(now time.Time, limit int) ([]model.OutboxEvent, error)
+	ClaimEvent(id uuid.UUID, now time.Time, until time.Time) (bool, error)
+	SaveEventResult(event *model.OutboxEvent) error
+	DeleteSentEvents(before time.Time) (int64, error)
+	WithTx(tx *gorm.DB) OutboxRepository
+}
+
+type outboxRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewOutboxRepository(db database.DatabaseInterface) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) WithTx(tx *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: database.Wrap(tx)}
+}
+
+func (r *outboxRepository) CreateEvent(event *model.OutboxEvent) error {
+	return r.db.Connection().Create(event).Error
+}
+
+// GetDueEvents lists pending events ready to be published, oldest first.
+func (r *outboxRepository) GetDueEvents(now time.Time, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.db.Connection().
+		Where("status = ? AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)", model.OutboxPending, now, now).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ClaimEvent marks a due event as being published until the given time and reports whether this
+// call won the claim. Only one instance can claim an event, the others see zero rows affected.
+func (r *outboxRepository) ClaimEvent(id uuid.UUID, now time.Time, until time.Time) (bool, error) {
+	result := r.db.Connection().
+		Model(&model.OutboxEvent{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)", id, model.OutboxPending, now, now).
+		Update("claimed_until", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SaveEventResult stores the outcome of a publish attempt and releases the claim.
+func (r *outboxRepository) SaveEventResult(event *model.OutboxEvent) error {
+	return r.db.Connection().
+		Model(event).
+		Select("status", "attempts", "next_attempt_at", "last_error", "sent_at", "claimed_until").
+		Updates(event).Error
+}
+
+// DeleteSentEvents removes events published before the given time, returning how many were removed.
+func (r *outboxRepository) DeleteSentEvents(before time.Time) (int64, error) {
+	result := r.db.Connection().
+		Unscoped().
+		Where("status = ? AND sent_at < ?", model.OutboxSent, before).
+		Delete(&model.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -1,6 +1,8 @@
 package core_repository
 
 import (
+	"github.com/google/uuid"
+
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/model"
 )
@@ -9,6 +11,7 @@ type ReconciliationLogRepository interface {
 	CreateReconciliationLog(log *model.ReconciliationLog) error
 	GetReconciliationLogsByUserID(userID string) ([]model.ReconciliationLog, error)
 	UpdateReconciliationLog(log *model.ReconciliationLog) error
+	ListReconciliationLogs(accountID *uuid.UUID, pageable Pageable) ([]model.ReconciliationLog, Pagination, error)
 }
 
 type reconciliationLogRepository struct {
@@ -35,3 +38,26 @@ func (r *reconciliationLogRepository) GetReconciliationLogsByUserID(userID strin
 func (r *reconciliationLogRepository) UpdateReconciliationLog(log *model.ReconciliationLog) error {
 	return r.db.Connection().Save(log).Error
 }
+
+// ListReconciliationLogs lists discrepancies newest first, optionally for a single account.
+func (r *reconciliationLogRepository) ListReconciliationLogs(accountID *uuid.UUID, pageable Pageable) ([]model.ReconciliationLog, Pagination, error) {
+	var logs []model.ReconciliationLog
+	var totalItems int64
+
+	query := r.db.Connection().Model(&model.ReconciliationLog{})
+
+	if accountID != nil {
+		query = query.Where("account_id = ?", *accountID)
+	}
+
+	if err := query.Count(&totalItems).Error; err != nil {
+		return nil, Pagination{}, err
+	}
+
+	offset := (pageable.Page - 1) * pageable.Size
+	if err := query.Order("created_at desc").Offset(offset).Limit(pageable.Size).Find(&logs).Error; err != nil {
+		return nil, Pagination{}, err
+	}
+
+	return logs, NewPagination(pageable, totalItems), nil
+}
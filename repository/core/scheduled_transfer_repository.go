@@ -0,0 +1,121 @@
+package core_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type ScheduledTransferRepository interface {
+	CreateSchedule(schedule *model.ScheduledTransfer) error
+	GetScheduleByID(userID uuid.UUID, id uuid.UUID) (*model.ScheduledTransfer, error)
+	GetSchedulesByUserID(userID uuid.UUID) ([]model.ScheduledTransfer, error)
+	UpdateSchedule(schedule *model.ScheduledTransfer, now time.Time) (bool, error)
+	DeleteSchedule(schedule *model.ScheduledTransfer) error
+	GetDueSchedules(now time.Time, limit int) ([]model.ScheduledTransfer, error)
+	ClaimSchedule(id uuid.UUID, now time.Time, until time.Time) (bool, error)
+	SaveRunResult(schedule *model.ScheduledTransfer) error
+	CreateRun(run *model.ScheduledTransferRun) error
+	GetRunsByScheduleID(scheduleID uuid.UUID, limit int) ([]model.ScheduledTransferRun, error)
+}
+
+type scheduledTransferRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewScheduledTransferRepository(db database.DatabaseInterface) ScheduledTransferRepository {
+	return &scheduledTransferRepository{db: db}
+}
+
+func (r *scheduledTransferRepository) CreateSchedule(schedule *model.ScheduledTransfer) error {
+	return r.db.Connection().Create(schedule).Error
+}
+
+func (r *scheduledTransferRepository) GetScheduleByID(userID uuid.UUID, id uuid.UUID) (*model.ScheduledTransfer, error) {
+	var schedule model.ScheduledTransfer
+	err := r.db.Connection().Where("id = ? AND user_id = ?", id, userID).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduledTransferRepository) GetSchedulesByUserID(userID uuid.UUID) ([]model.ScheduledTransfer, error) {
+	var schedules []model.ScheduledTransfer
+	err := r.db.Connection().Where("user_id = ?", userID).Order("next_run_at").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// UpdateSchedule writes the columns a user can change and reports whether the schedule was updated.
+// A schedule claimed by a run is left alone, so an edit can neither release the claim nor move the
+// occurrence that is being paid.
+func (r *scheduledTransferRepository) UpdateSchedule(schedule *model.ScheduledTransfer, now time.Time) (bool, error) {
+	result := r.db.Connection().
+		Model(schedule).
+		Where("claimed_until IS NULL OR claimed_until < ?", now).
+		Select("to_account_number", "currency", "amount", "description", "cron_expression", "interval_minutes",
+			"next_run_at", "planned_run_at", "status", "failure_count", "last_error").
+		Updates(schedule)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *scheduledTransferRepository) DeleteSchedule(schedule *model.ScheduledTransfer) error {
+	return r.db.Connection().Delete(schedule).Error
+}
+
+func (r *scheduledTransferRepository) GetDueSchedules(now time.Time, limit int) ([]model.ScheduledTransfer, error) {
+	var schedules []model.ScheduledTransfer
+	err := r.db.Connection().
+		Where("status = ? AND next_run_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)", model.ScheduleActive, now, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// ClaimSchedule marks a due schedule as being executed until the given time and reports whether
+// this call won the claim. Only one instance can claim a schedule, the others see zero rows affected.
+func (r *scheduledTransferRepository) ClaimSchedule(id uuid.UUID, now time.Time, until time.Time) (bool, error) {
+	result := r.db.Connection().
+		Model(&model.ScheduledTransfer{}).
+		Where("id = ? AND status = ? AND next_run_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)", id, model.ScheduleActive, now, now).
+		Update("claimed_until", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SaveRunResult stores the outcome of an execution and releases the claim. Only the columns the
+// run changes are written, so amount or destination edits made meanwhile are kept.
+func (r *scheduledTransferRepository) SaveRunResult(schedule *model.ScheduledTransfer) error {
+	return r.db.Connection().
+		Model(schedule).
+		Select("next_run_at", "planned_run_at", "last_run_at", "status", "failure_count", "last_error", "claimed_until").
+		Updates(schedule).Error
+}
+
+func (r *scheduledTransferRepository) CreateRun(run *model.ScheduledTransferRun) error {
+	return r.db.Connection().Create(run).Error
+}
+
+func (r *scheduledTransferRepository) GetRunsByScheduleID(scheduleID uuid.UUID, limit int) ([]model.ScheduledTransferRun, error) {
+	var runs []model.ScheduledTransferRun
+	err := r.db.Connection().Where("schedule_id = ?", scheduleID).Order("created_at desc").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,48 @@
+package core_repository
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type SigningKeyRepository interface {
+	CreateSigningKey(key *model.SigningKey) error
+	GetSigningKeys(algorithm string) ([]model.SigningKey, error)
+	UpdateSigningKeyPrivateKey(key *model.SigningKey) error
+	DeleteSigningKeys(ids []uuid.UUID) error
+}
+
+type signingKeyRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewSigningKeyRepository(db database.DatabaseInterface) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+func (r *signingKeyRepository) CreateSigningKey(key *model.SigningKey) error {
+	return r.db.Connection().Create(key).Error
+}
+
+// GetSigningKeys lists the keys of an algorithm, oldest activation first.
+func (r *signingKeyRepository) GetSigningKeys(algorithm string) ([]model.SigningKey, error) {
+	var keys []model.SigningKey
+	err := r.db.Connection().Where("algorithm = ?", algorithm).Order("activates_at ASC").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *signingKeyRepository) UpdateSigningKeyPrivateKey(key *model.SigningKey) error {
+	return r.db.Connection().Model(key).Update("private_key", key.PrivateKey).Error
+}
+
+func (r *signingKeyRepository) DeleteSigningKeys(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Connection().Unscoped().Where("id IN ?", ids).Delete(&model.SigningKey{}).Error
+}
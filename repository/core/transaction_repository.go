@@ -1,10 +1,16 @@
 package core_repository
 
 import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 	"github.com/horlakz/wallet-sync.api/dto"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Pageable struct {
@@ -22,12 +28,26 @@ type Pagination struct {
 	TotalItems  int64 `json:"total_items"`
 }
 
+// NewPagination describes the page of pageable out of totalItems results.
+func NewPagination(pageable Pageable, totalItems int64) Pagination {
+	return Pagination{
+		CurrentPage: int64(pageable.Page),
+		TotalPages:  (totalItems + int64(pageable.Size) - 1) / int64(pageable.Size),
+		TotalItems:  totalItems,
+	}
+}
+
 type TransactionRepository interface {
 	CreateTransaction(transaction *model.Transaction) error
 	GetTransactionByReference(reference string) (*model.Transaction, error)
+	GetTransactionByIdempotencyKey(userID uuid.UUID, key string) (*model.Transaction, error)
+	GetTransactionByIDForUpdate(id uuid.UUID) (*model.Transaction, error)
+	GetTransactionByReferenceForUpdate(reference string) (*model.Transaction, error)
+	UpdateTransaction(transaction *model.Transaction) error
 	UpdateTransactionStatus(reference string, status string) error
 	FindTransactionsByUserID(userID string, pageable Pageable) ([]dto.TransactionDto, Pagination, error)
 	GetAllTransactions() ([]model.Transaction, error)
+	GetTransactionUsage(userID uuid.UUID, operations []string, currency string, since time.Time) (decimal.Decimal, int64, error)
 	WithTx(tx *gorm.DB) TransactionRepository
 }
 
@@ -56,6 +76,39 @@ func (r *transactionRepository) GetTransactionByReference(reference string) (*mo
 	return &transaction, nil
 }
 
+func (r *transactionRepository) GetTransactionByIdempotencyKey(userID uuid.UUID, key string) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := r.db.Connection().Where("user_id = ? AND idempotency_key = ?", userID, key).First(&transaction).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// GetTransactionByIDForUpdate reads the transaction with SELECT ... FOR UPDATE, it must be called inside a transaction.
+func (r *transactionRepository) GetTransactionByIDForUpdate(id uuid.UUID) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := r.db.Connection().Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&transaction).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// GetTransactionByReferenceForUpdate reads the transaction with SELECT ... FOR UPDATE, it must be called inside a transaction.
+func (r *transactionRepository) GetTransactionByReferenceForUpdate(reference string) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := r.db.Connection().Clauses(clause.Locking{Strength: "UPDATE"}).Where("reference = ?", reference).First(&transaction).Error
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *transactionRepository) UpdateTransaction(transaction *model.Transaction) error {
+	return r.db.Connection().Save(transaction).Error
+}
+
 func (r *transactionRepository) UpdateTransactionStatus(reference string, status string) error {
 	var transaction model.Transaction
 	err := r.db.Connection().Where("reference = ?", reference).First(&transaction).Error
@@ -76,42 +129,69 @@ func (r *transactionRepository) GetTransactionsByUserID(userID string) ([]model.
 	return transactions, nil
 }
 
+// transactionSortColumns maps the sortable fields of the history to their qualified columns.
+var transactionSortColumns = map[string]string{
+	"created_at": "ledger_entries.created_at",
+	"updated_at": "ledger_entries.updated_at",
+	"amount":     "ledger_entries.amount",
+	"type":       "ledger_entries.entry_type",
+	"status":     "transactions.status",
+	"reference":  "transactions.reference",
+}
+
+// FindTransactionsByUserID lists the user's side of every journal transaction they took part in,
+// one row per posting, so incoming transfers show up as credits for the receiver.
 func (r *transactionRepository) FindTransactionsByUserID(userID string, pageable Pageable) ([]dto.TransactionDto, Pagination, error) {
 	var transactions []dto.TransactionDto
 	var totalItems int64
 
-	query := r.db.Connection().Model(&model.Transaction{}).Where("user_id = ?", userID)
+	query := r.db.Connection().
+		Model(&model.LedgerEntry{}).
+		Joins("JOIN transactions ON transactions.id = ledger_entries.transaction_id").
+		Where("ledger_entries.user_id = ?", userID)
 
 	if pageable.Type != "" {
-		query = query.Where("type = ?", pageable.Type)
+		query = query.Where("ledger_entries.entry_type = ?", pageable.Type)
 	}
 
 	if pageable.Status != "" {
-		query = query.Where("status = ?", pageable.Status)
+		query = query.Where("transactions.status = ?", pageable.Status)
 	}
 
 	if err := query.Count(&totalItems).Error; err != nil {
 		return nil, Pagination{}, err
 	}
 
-	if pageable.SortBy != "" {
-		query = query.Order(pageable.SortBy + " " + pageable.SortDirection)
+	sortColumn, ok := transactionSortColumns[pageable.SortBy]
+	if !ok {
+		sortColumn = transactionSortColumns["created_at"]
+	}
+
+	sortDirection := "desc"
+	if strings.EqualFold(pageable.SortDirection, "asc") {
+		sortDirection = "asc"
 	}
 
+	query = query.Order(sortColumn + " " + sortDirection)
+
 	offset := (pageable.Page - 1) * pageable.Size
 	query = query.Offset(offset).Limit(pageable.Size)
 
-	if err := query.Find(&transactions).Error; err != nil {
+	err := query.Select(
+		"transactions.reference",
+		"ledger_entries.entry_type AS type",
+		"transactions.status",
+		"ledger_entries.amount",
+		"transactions.currency",
+		"ledger_entries.description",
+		"ledger_entries.created_at",
+		"ledger_entries.updated_at",
+	).Scan(&transactions).Error
+	if err != nil {
 		return nil, Pagination{}, err
 	}
 
-	totalPages := (totalItems + int64(pageable.Size) - 1) / int64(pageable.Size)
-
-	return transactions, Pagination{
-		CurrentPage: int64(pageable.Page),
-		TotalPages:  totalPages,
-		TotalItems:  totalItems,
-	}, nil
+	return transactions, NewPagination(pageable, totalItems), nil
 }
 
 func (r *transactionRepository) GetAllTransactions() ([]model.Transaction, error) {
@@ -122,3 +202,28 @@ func (r *transactionRepository) GetAllTransactions() ([]model.Transaction, error
 	}
 	return transactions, nil
 }
+
+// unreversedAmount is the part of a transaction's amount that has not been refunded by a reversal.
+const unreversedAmount = "amount - COALESCE(CAST(JSON_UNQUOTE(JSON_EXTRACT(metadata, '$.reversed_amount')) AS DECIMAL(32, 8)), 0)"
+
+// GetTransactionUsage sums the user's completed transactions of the given operations and currency since the given time,
+// along with pending ones, which are holds that still reserve their amount. Reversed amounts are left out, and
+// fully reversed transactions are not counted.
+func (r *transactionRepository) GetTransactionUsage(userID uuid.UUID, operations []string, currency string, since time.Time) (decimal.Decimal, int64, error) {
+	var usage struct {
+		Total decimal.Decimal
+		Count int64
+	}
+
+	err := r.db.Connection().
+		Model(&model.Transaction{}).
+		Select("COALESCE(SUM("+unreversedAmount+"), 0) AS total, COUNT(*) AS count").
+		Where("user_id = ? AND operation IN ? AND currency = ? AND status IN ? AND created_at >= ?", userID, operations, currency, []string{model.TransactionCompleted, model.TransactionPending}, since).
+		Where(unreversedAmount + " > 0").
+		Scan(&usage).Error
+	if err != nil {
+		return decimal.Zero, 0, err
+	}
+
+	return usage.Total, usage.Count, nil
+}
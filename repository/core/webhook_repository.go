@@ -0,0 +1,177 @@
+package core_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(endpoint *model.WebhookEndpoint) error
+	GetEndpointByID(userID uuid.UUID, id uuid.UUID) (*model.WebhookEndpoint, error)
+	GetEndpointsByUserID(userID uuid.UUID) ([]model.WebhookEndpoint, error)
+	GetSubscribedEndpoints(userID uuid.UUID, event string) ([]model.WebhookEndpoint, error)
+	GetEndpointForDelivery(id uuid.UUID) (*model.WebhookEndpoint, error)
+	CountEndpoints(userID uuid.UUID) (int64, error)
+	UpdateEndpoint(endpoint *model.WebhookEndpoint) error
+	DeleteEndpoint(endpoint *model.WebhookEndpoint) error
+	CreateDelivery(delivery *model.WebhookDelivery) error
+	GetDeliveryByID(endpointID uuid.UUID, id uuid.UUID) (*model.WebhookDelivery, error)
+	GetDeliveriesByEndpointID(endpointID uuid.UUID, status string, limit int) ([]model.WebhookDelivery, error)
+	GetDueDeliveries(now time.Time, limit int) ([]model.WebhookDelivery, error)
+	ClaimDelivery(id uuid.UUID, now time.Time, until time.Time) (bool, error)
+	SaveDeliveryResult(delivery *model.WebhookDelivery) error
+	CreateAttempt(attempt *model.WebhookAttempt) error
+	GetAttemptsByDeliveryID(deliveryID uuid.UUID) ([]model.WebhookAttempt, error)
+	WithTx(tx *gorm.DB) WebhookRepository
+}
+
+type webhookRepository struct {
+	db database.DatabaseInterface
+}
+
+func NewWebhookRepository(db database.DatabaseInterface) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) WithTx(tx *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: database.Wrap(tx)}
+}
+
+func (r *webhookRepository) CreateEndpoint(endpoint *model.WebhookEndpoint) error {
+	return r.db.Connection().Create(endpoint).Error
+}
+
+func (r *webhookRepository) GetEndpointByID(userID uuid.UUID, id uuid.UUID) (*model.WebhookEndpoint, error) {
+	var endpoint model.WebhookEndpoint
+	err := r.db.Connection().Where("id = ? AND user_id = ?", id, userID).First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *webhookRepository) GetEndpointsByUserID(userID uuid.UUID) ([]model.WebhookEndpoint, error) {
+	var endpoints []model.WebhookEndpoint
+	err := r.db.Connection().Where("user_id = ?", userID).Order("created_at").Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// GetSubscribedEndpoints lists the user's active endpoints that receive event.
+func (r *webhookRepository) GetSubscribedEndpoints(userID uuid.UUID, event string) ([]model.WebhookEndpoint, error) {
+	var endpoints []model.WebhookEndpoint
+	err := r.db.Connection().
+		Where("user_id = ? AND active = ? AND JSON_CONTAINS(events, JSON_QUOTE(?))", userID, true, event).
+		Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// GetEndpointForDelivery reads an endpoint by ID alone, including deleted ones, so that the
+// deliveries of a deleted endpoint can be settled.
+func (r *webhookRepository) GetEndpointForDelivery(id uuid.UUID) (*model.WebhookEndpoint, error) {
+	var endpoint model.WebhookEndpoint
+	err := r.db.Connection().Unscoped().Where("id = ?", id).First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *webhookRepository) CountEndpoints(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Connection().Model(&model.WebhookEndpoint{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *webhookRepository) UpdateEndpoint(endpoint *model.WebhookEndpoint) error {
+	return r.db.Connection().Save(endpoint).Error
+}
+
+func (r *webhookRepository) DeleteEndpoint(endpoint *model.WebhookEndpoint) error {
+	return r.db.Connection().Delete(endpoint).Error
+}
+
+func (r *webhookRepository) CreateDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Connection().Create(delivery).Error
+}
+
+func (r *webhookRepository) GetDeliveryByID(endpointID uuid.UUID, id uuid.UUID) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	err := r.db.Connection().Where("id = ? AND endpoint_id = ?", id, endpointID).First(&delivery).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetDeliveriesByEndpointID lists the endpoint's latest deliveries, only those in status if it is set.
+func (r *webhookRepository) GetDeliveriesByEndpointID(endpointID uuid.UUID, status string, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	query := r.db.Connection().Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Order("created_at desc").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) GetDueDeliveries(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.Connection().
+		Where("status = ? AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)", model.WebhookDeliveryPending, now, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery marks a due delivery as being sent until the given time and reports whether this
+// call won the claim. Only one instance can claim a delivery, the others see zero rows affected.
+func (r *webhookRepository) ClaimDelivery(id uuid.UUID, now time.Time, until time.Time) (bool, error) {
+	result := r.db.Connection().
+		Model(&model.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ?)", id, model.WebhookDeliveryPending, now, now).
+		Update("claimed_until", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SaveDeliveryResult stores the outcome of an attempt, or a redelivery, and releases the claim.
+func (r *webhookRepository) SaveDeliveryResult(delivery *model.WebhookDelivery) error {
+	return r.db.Connection().
+		Model(delivery).
+		Select("status", "attempts", "next_attempt_at", "last_attempt_at", "delivered_at", "last_error", "claimed_until").
+		Updates(delivery).Error
+}
+
+func (r *webhookRepository) CreateAttempt(attempt *model.WebhookAttempt) error {
+	return r.db.Connection().Create(attempt).Error
+}
+
+func (r *webhookRepository) GetAttemptsByDeliveryID(deliveryID uuid.UUID) ([]model.WebhookAttempt, error) {
+	var attempts []model.WebhookAttempt
+	err := r.db.Connection().Where("delivery_id = ?", deliveryID).Order("created_at").Find(&attempts).Error
+	if err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
@@ -0,0 +1,84 @@
+package user_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(key *model.APIKey) error
+	GetAPIKeyByID(userID uuid.UUID, id uuid.UUID) (*model.APIKey, error)
+	GetAPIKeyByPrefix(prefix string) (*model.APIKey, error)
+	GetAPIKeysByUserID(userID uuid.UUID) ([]model.APIKey, error)
+	CountActiveAPIKeys(userID uuid.UUID) (int64, error)
+	RevokeAPIKey(key *model.APIKey, revokedAt time.Time) (bool, error)
+	TouchAPIKey(id uuid.UUID, usedAt time.Time) error
+	UpdateAPIKeySigningSecret(key *model.APIKey) error
+}
+
+type apiKeyRepo struct {
+	db database.DatabaseInterface
+}
+
+func NewAPIKeyRepository(db database.DatabaseInterface) APIKeyRepository {
+	return &apiKeyRepo{db: db}
+}
+
+func (r *apiKeyRepo) CreateAPIKey(key *model.APIKey) error {
+	return r.db.Connection().Create(key).Error
+}
+
+func (r *apiKeyRepo) GetAPIKeyByID(userID uuid.UUID, id uuid.UUID) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Connection().Where("id = ? AND user_id = ?", id, userID).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByPrefix finds an unrevoked key by its public prefix.
+func (r *apiKeyRepo) GetAPIKeyByPrefix(prefix string) (*model.APIKey, error) {
+	var key model.APIKey
+	err := r.db.Connection().Where("prefix = ? AND revoked_at IS NULL", prefix).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepo) GetAPIKeysByUserID(userID uuid.UUID) ([]model.APIKey, error) {
+	var keys []model.APIKey
+	err := r.db.Connection().Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepo) CountActiveAPIKeys(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Connection().Model(&model.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// RevokeAPIKey reports whether this call revoked the key, false means it was already revoked.
+func (r *apiKeyRepo) RevokeAPIKey(key *model.APIKey, revokedAt time.Time) (bool, error) {
+	result := r.db.Connection().Model(&model.APIKey{}).Where("id = ? AND revoked_at IS NULL", key.ID).Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *apiKeyRepo) TouchAPIKey(id uuid.UUID, usedAt time.Time) error {
+	return r.db.Connection().Model(&model.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
+
+func (r *apiKeyRepo) UpdateAPIKeySigningSecret(key *model.APIKey) error {
+	return r.db.Connection().Model(&model.APIKey{}).Where("id = ?", key.ID).Update("signing_secret", key.SigningSecret).Error
+}
@@ -0,0 +1,58 @@
+package user_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type RecoveryCodeRepository interface {
+	ReplaceRecoveryCodes(userID uuid.UUID, codes []model.RecoveryCode) error
+	GetUnusedRecoveryCodes(userID uuid.UUID) ([]model.RecoveryCode, error)
+	MarkRecoveryCodeUsed(code *model.RecoveryCode, usedAt time.Time) (bool, error)
+	DeleteRecoveryCodes(userID uuid.UUID) error
+}
+
+type recoveryCodeRepo struct {
+	db database.DatabaseInterface
+}
+
+func NewRecoveryCodeRepository(db database.DatabaseInterface) RecoveryCodeRepository {
+	return &recoveryCodeRepo{db: db}
+}
+
+// ReplaceRecoveryCodes drops the user's previous codes and stores the new set in one transaction.
+func (r *recoveryCodeRepo) ReplaceRecoveryCodes(userID uuid.UUID, codes []model.RecoveryCode) error {
+	return r.db.Connection().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *recoveryCodeRepo) GetUnusedRecoveryCodes(userID uuid.UUID) ([]model.RecoveryCode, error) {
+	var codes []model.RecoveryCode
+	err := r.db.Connection().Where("user_id = ? AND used_at IS NULL", userID).Find(&codes).Error
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// MarkRecoveryCodeUsed reports whether this call used the code, false means it was used concurrently.
+func (r *recoveryCodeRepo) MarkRecoveryCodeUsed(code *model.RecoveryCode, usedAt time.Time) (bool, error) {
+	result := r.db.Connection().Model(&model.RecoveryCode{}).Where("id = ? AND used_at IS NULL", code.ID).Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *recoveryCodeRepo) DeleteRecoveryCodes(userID uuid.UUID) error {
+	return r.db.Connection().Unscoped().Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error
+}
@@ -0,0 +1,74 @@
+package user_repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+type SessionRepository interface {
+	CreateSession(session *model.Session) error
+	GetSessionByID(userID uuid.UUID, id uuid.UUID) (*model.Session, error)
+	GetActiveSessions(userID uuid.UUID, now time.Time) ([]model.Session, error)
+	TouchSession(id uuid.UUID, ipAddress string, seenAt time.Time) error
+	ExtendSession(id uuid.UUID, seenAt time.Time, expiresAt time.Time) error
+	RevokeSessions(ids []uuid.UUID, revokedAt time.Time) error
+}
+
+type sessionRepo struct {
+	db database.DatabaseInterface
+}
+
+func NewSessionRepository(db database.DatabaseInterface) SessionRepository {
+	return &sessionRepo{db: db}
+}
+
+func (r *sessionRepo) CreateSession(session *model.Session) error {
+	return r.db.Connection().Create(session).Error
+}
+
+func (r *sessionRepo) GetSessionByID(userID uuid.UUID, id uuid.UUID) (*model.Session, error) {
+	var session model.Session
+	err := r.db.Connection().Where("id = ? AND user_id = ?", id, userID).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActiveSessions lists the user's unrevoked, unexpired sessions, most recently seen first.
+func (r *sessionRepo) GetActiveSessions(userID uuid.UUID, now time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.db.Connection().
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepo) TouchSession(id uuid.UUID, ipAddress string, seenAt time.Time) error {
+	return r.db.Connection().Model(&model.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"ip_address":   ipAddress,
+		"last_seen_at": seenAt,
+	}).Error
+}
+
+func (r *sessionRepo) ExtendSession(id uuid.UUID, seenAt time.Time, expiresAt time.Time) error {
+	return r.db.Connection().Model(&model.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_seen_at": seenAt,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+func (r *sessionRepo) RevokeSessions(ids []uuid.UUID, revokedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Connection().Model(&model.Session{}).Where("id IN ? AND revoked_at IS NULL", ids).Update("revoked_at", revokedAt).Error
+}
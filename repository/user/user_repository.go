@@ -1,13 +1,31 @@
 package user_repository
 
 import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
 )
 
 type UserRepository interface {
 	Create(user *model.User) error
 	FindByEmail(email string) (*model.User, error)
+	FindByID(id uuid.UUID) (*model.User, error)
+	Update(user *model.User) error
+	FindAll(role string, pageable core_repository.Pageable) ([]model.User, core_repository.Pagination, error)
+	UpdateRole(id uuid.UUID, role string) error
+	UpdateKycLevel(id uuid.UUID, kycLevel int) error
+	MarkVerified(id uuid.UUID, verifiedAt time.Time) error
+	UpdatePassword(id uuid.UUID, password string) error
+	SetTotpSecret(id uuid.UUID, secret string) error
+	UpdateTotpSecret(id uuid.UUID, secret string) error
+	EnableTotp(id uuid.UUID, enabledAt time.Time) error
+	DisableTotp(id uuid.UUID) error
+	UpdatePin(id uuid.UUID, pinHash string, setAt time.Time) error
 }
 
 type userRepo struct {
@@ -24,9 +42,107 @@ func (r *userRepo) Create(user *model.User) error {
 
 func (r *userRepo) FindByEmail(email string) (*model.User, error) {
 	var user model.User
-	err := r.db.Connection().Where("email = ?", email).First(&user).Error
+	err := r.db.Connection().Where("email = ?", model.NormalizeEmail(email)).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
+
+func (r *userRepo) FindByID(id uuid.UUID) (*model.User, error) {
+	var user model.User
+	err := r.db.Connection().Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Update saves the user's profile: name, email and email verification.
+func (r *userRepo) Update(user *model.User) error {
+	return r.db.Connection().Model(user).Select("name", "email", "verified_at").Updates(user).Error
+}
+
+// FindAll lists users newest first, Pageable.Status filters by role.
+func (r *userRepo) FindAll(role string, pageable core_repository.Pageable) ([]model.User, core_repository.Pagination, error) {
+	var users []model.User
+	var totalItems int64
+
+	query := r.db.Connection().Model(&model.User{})
+
+	if role != "" {
+		query = query.Where("role = ?", role)
+	}
+
+	if err := query.Count(&totalItems).Error; err != nil {
+		return nil, core_repository.Pagination{}, err
+	}
+
+	offset := (pageable.Page - 1) * pageable.Size
+	if err := query.Order("created_at desc").Offset(offset).Limit(pageable.Size).Find(&users).Error; err != nil {
+		return nil, core_repository.Pagination{}, err
+	}
+
+	return users, core_repository.NewPagination(pageable, totalItems), nil
+}
+
+func (r *userRepo) UpdateRole(id uuid.UUID, role string) error {
+	result := r.db.Connection().Model(&model.User{}).Where("id = ?", id).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) UpdateKycLevel(id uuid.UUID, kycLevel int) error {
+	result := r.db.Connection().Model(&model.User{}).Where("id = ?", id).Update("kyc_level", kycLevel)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepo) MarkVerified(id uuid.UUID, verifiedAt time.Time) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ? AND verified_at IS NULL", id).Update("verified_at", verifiedAt).Error
+}
+
+func (r *userRepo) UpdatePassword(id uuid.UUID, password string) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ?", id).Update("password", password).Error
+}
+
+// SetTotpSecret stores a pending secret, it has no effect until EnableTotp confirms it.
+func (r *userRepo) SetTotpSecret(id uuid.UUID, secret string) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_secret":     secret,
+		"totp_enabled_at": nil,
+	}).Error
+}
+
+// UpdateTotpSecret replaces the stored secret and leaves two-factor authentication as it is.
+func (r *userRepo) UpdateTotpSecret(id uuid.UUID, secret string) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ?", id).Update("totp_secret", secret).Error
+}
+
+func (r *userRepo) EnableTotp(id uuid.UUID, enabledAt time.Time) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ?", id).Update("totp_enabled_at", enabledAt).Error
+}
+
+func (r *userRepo) DisableTotp(id uuid.UUID) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_secret":     "",
+		"totp_enabled_at": nil,
+	}).Error
+}
+
+func (r *userRepo) UpdatePin(id uuid.UUID, pinHash string, setAt time.Time) error {
+	return r.db.Connection().Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"pin_hash":   pinHash,
+		"pin_set_at": setAt,
+	}).Error
+}
@@ -0,0 +1,66 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/monitor"
+
+	"github.com/horlakz/wallet-sync.api/handler"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/middleware"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+func InitializeAdminRouter(router fiber.Router, db database.DatabaseInterface, env config.Env) {
+	// Repositories
+	accountRepository := core_repository.NewAccountRepository(db)
+	transactionRepository := core_repository.NewTransactionRepository(db)
+	ledgerEntryRepository := core_repository.NewLedgerEntryRepository(db)
+	holdRepository := core_repository.NewHoldRepository(db)
+	webhookRepository := core_repository.NewWebhookRepository(db)
+	outboxRepository := core_repository.NewOutboxRepository(db)
+	feeRuleRepository := core_repository.NewFeeRuleRepository(db)
+	limitRuleRepository := core_repository.NewLimitRuleRepository(db)
+	reconciliationLogRepository := core_repository.NewReconciliationLogRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+	userRepository := user_repository.NewUserRepository(db)
+
+	// Services
+	feeService := service.NewFeeService(feeRuleRepository)
+	limitService := service.NewLimitService(limitRuleRepository, transactionRepository, userRepository)
+	auditService := service.NewAuditService(auditLogRepository)
+	walletService := service.NewWalletService(accountRepository, transactionRepository, ledgerEntryRepository, holdRepository, webhookRepository, outboxRepository, feeService, limitService, auditService, db)
+	adminService := service.NewAdminService(userRepository, reconciliationLogRepository, auditService)
+
+	// Handlers
+	adminHandler := handler.NewAdminHandler(walletService, limitService, adminService)
+
+	// middlewares
+	authMiddleware := middleware.Protected(db)
+	staffMiddleware := middleware.RequireRole(model.RoleSupport, model.RoleAdmin)
+	can := middleware.RequirePermission
+
+	// Base routes
+	adminRoute := router.Group("/admin", authMiddleware, staffMiddleware)
+
+	// Routes
+	adminRoute.Get("/users", can(model.PermissionUsersRead), adminHandler.ListUsers)
+	adminRoute.Get("/users/:id", can(model.PermissionUsersRead), adminHandler.GetUser)
+	adminRoute.Get("/users/:id/wallets", can(model.PermissionWalletsRead), adminHandler.GetUserWallets)
+	adminRoute.Put("/users/:id/role", can(model.PermissionUsersManage), adminHandler.SetRole)
+	adminRoute.Put("/users/:id/kyc", can(model.PermissionLimitsManage), adminHandler.SetKycLevel)
+	adminRoute.Put("/accounts/:id/status", can(model.PermissionAccountsManage), adminHandler.SetAccountStatus)
+	adminRoute.Post("/accounts/:id/close", can(model.PermissionAccountsManage), adminHandler.CloseAccount)
+	adminRoute.Get("/accounts/:id/status-history", can(model.PermissionWalletsRead), adminHandler.GetAccountStatusHistory)
+	adminRoute.Post("/transactions/:reference/reverse", can(model.PermissionTransactionsReverse), adminHandler.ReverseTransaction)
+	adminRoute.Get("/limits", can(model.PermissionLimitsManage), adminHandler.ListLimitRules)
+	adminRoute.Put("/limits", can(model.PermissionLimitsManage), adminHandler.SaveLimitRule)
+	adminRoute.Get("/reconciliation-logs", can(model.PermissionReconciliationRead), adminHandler.ListReconciliationLogs)
+	adminRoute.Get("/monitor", can(model.PermissionSystemMonitor), monitor.New(monitor.Config{Title: "Wallet Sync API Monitor"}))
+	adminRoute.Get("/logs/:key", can(model.PermissionSystemMonitor), func(c *fiber.Ctx) error {
+		return handler.GetLogs(c, db)
+	})
+}
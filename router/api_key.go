@@ -0,0 +1,38 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/handler"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/middleware"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+func InitializeAPIKeyRouter(router fiber.Router, db database.DatabaseInterface, env config.Env) {
+	// Repositories
+	apiKeyRepository := user_repository.NewAPIKeyRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+
+	// Services
+	auditService := service.NewAuditService(auditLogRepository)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepository, auditService, encryptionKey(env))
+
+	// Handlers
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+
+	// middlewares, keys are managed with a login session only
+	authMiddleware := middleware.Protected(db)
+	verifiedMiddleware := middleware.Verified(db)
+
+	// Base routes
+	apiKeyRoute := router.Group("/api-keys", authMiddleware, verifiedMiddleware)
+
+	// Routes
+	apiKeyRoute.Get("/", apiKeyHandler.List)
+	apiKeyRoute.Post("/", apiKeyHandler.Create)
+	apiKeyRoute.Delete("/:id", apiKeyHandler.Revoke)
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/horlakz/wallet-sync.api/handler"
 	"github.com/horlakz/wallet-sync.api/internal/config"
 	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/middleware"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
 	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
 	"github.com/horlakz/wallet-sync.api/service"
 )
@@ -13,17 +15,42 @@ import (
 func InitializeUserRouter(router fiber.Router, db database.DatabaseInterface, env config.Env) {
 	// Repositories
 	userRepository := user_repository.NewUserRepository(db)
+	recoveryCodeRepository := user_repository.NewRecoveryCodeRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+	sessionRepository := user_repository.NewSessionRepository(db)
 
 	// Services
-	authService := service.NewAuthService(userRepository)
+	auditService := service.NewAuditService(auditLogRepository)
+	twoFactorService := service.NewTwoFactorService(userRepository, recoveryCodeRepository, auditService, db.Cache(), encryptionKey(env), stepUpThresholds(env))
+	tokenStore := service.NewTokenStore(db.Cache())
+	sessionService := service.NewSessionService(sessionRepository, tokenStore, auditService, db.Cache())
+	authService := service.NewAuthService(userRepository, tokenStore, sessionService, twoFactorService, auditService, db.Cache(), config.NewEmail(env), env.APP_URL)
 
 	// Handler
-	authHandler := handler.NewAuthHandler(authService)
+	authHandler := handler.NewAuthHandler(authService, sessionService)
+
+	// middlewares
+	authMiddleware := middleware.Protected(db)
 
 	// Routers
 	authRoute := router.Group("/auth")
 
 	// Routes
 	authRoute.Post("/login", authHandler.Login)
+	authRoute.Post("/login/2fa", authHandler.LoginTwoFactor)
+	authRoute.Post("/unlock", authHandler.UnlockAccount)
 	authRoute.Post("/register", authHandler.Register)
+	authRoute.Post("/refresh", authHandler.Refresh)
+	authRoute.Post("/logout", authMiddleware, authHandler.Logout)
+	authRoute.Post("/verify-email", authHandler.VerifyEmail)
+	authRoute.Post("/verify-email/resend", authMiddleware, authHandler.ResendVerification)
+	authRoute.Post("/forgot-password", authHandler.ForgotPassword)
+	authRoute.Post("/reset-password", authHandler.ResetPassword)
+	authRoute.Post("/2fa/setup", authMiddleware, authHandler.SetupTwoFactor)
+	authRoute.Post("/2fa/confirm", authMiddleware, authHandler.ConfirmTwoFactor)
+	authRoute.Post("/2fa/disable", authMiddleware, authHandler.DisableTwoFactor)
+	authRoute.Post("/2fa/recovery-codes", authMiddleware, authHandler.RegenerateRecoveryCodes)
+	authRoute.Get("/sessions", authMiddleware, authHandler.ListSessions)
+	authRoute.Post("/sessions/revoke-others", authMiddleware, authHandler.RevokeOtherSessions)
+	authRoute.Delete("/sessions/:id", authMiddleware, authHandler.RevokeSession)
 }
@@ -0,0 +1,41 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/handler"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/middleware"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+func InitializeProfileRouter(router fiber.Router, db database.DatabaseInterface, env config.Env) {
+	// Repositories
+	userRepository := user_repository.NewUserRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+	sessionRepository := user_repository.NewSessionRepository(db)
+
+	// Services
+	auditService := service.NewAuditService(auditLogRepository)
+	sessionService := service.NewSessionService(sessionRepository, service.NewTokenStore(db.Cache()), auditService, db.Cache())
+	profileService := service.NewProfileService(userRepository, sessionService, auditService, config.NewEmail(env), env.APP_URL)
+
+	// Handlers
+	profileHandler := handler.NewProfileHandler(profileService)
+
+	// middlewares, unverified users can still fix their email
+	authMiddleware := middleware.Protected(db)
+
+	// Base routes
+	profileRoute := router.Group("/me")
+
+	// Routes
+	profileRoute.Get("/", authMiddleware, profileHandler.Get)
+	profileRoute.Patch("/", authMiddleware, profileHandler.Update)
+	profileRoute.Put("/password", authMiddleware, profileHandler.ChangePassword)
+	profileRoute.Post("/email", authMiddleware, profileHandler.ChangeEmail)
+	profileRoute.Post("/email/confirm", profileHandler.ConfirmEmailChange)
+}
@@ -1,13 +1,17 @@
 package router
 
 import (
+	"log"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/monitor"
 
 	"github.com/horlakz/wallet-sync.api/handler"
 	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
 	"github.com/horlakz/wallet-sync.api/lib/database"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	"github.com/horlakz/wallet-sync.api/service"
 )
 
 func InitializeRouter(router *fiber.App, dbConn database.DatabaseInterface, env config.Env) {
@@ -17,25 +21,65 @@ func InitializeRouter(router *fiber.App, dbConn database.DatabaseInterface, env
 		},
 	}, logger.ConfigDefault))
 
+	// Tokens are signed and verified with the configured algorithm's keys from here on
+	signingKeyService := service.NewSigningKeyService(
+		core_repository.NewSigningKeyRepository(dbConn),
+		dbConn.Cache(),
+		signingKeyConfig(env),
+	)
+	helper.UseKeySet(signingKeyService)
+
 	main := router.Group("/v1", func(c *fiber.Ctx) error {
 		c.Set("Version", "v1")
 		return c.Next()
 	})
 
-	main.Get("/monitor", monitor.New(monitor.Config{Title: "Wallet Sync API Monitor"}))
-
 	InitializeUserRouter(main, dbConn, env)
+	InitializeProfileRouter(main, dbConn, env)
 	InitializeWalletRouter(main, dbConn, env)
 	InitializeTransactionRouter(main, dbConn, env)
+	InitializeAdminRouter(main, dbConn, env)
+	InitializeAPIKeyRouter(main, dbConn, env)
+	InitializeWebhookRouter(main, dbConn, env)
 
 	router.Get("/health", func(c *fiber.Ctx) error {
 		return c.SendString("OK")
 	})
 
-	router.Get("/logs/:key", func(c *fiber.Ctx) error {
-		return handler.GetLogs(c, dbConn)
-	})
+	router.Get("/.well-known/jwks.json", handler.NewJWKSHandler(signingKeyService).Get)
+
 	router.Get("/", handler.Index)
 	router.Get("*", handler.NotFound)
 
 }
+
+// stepUpThresholds reads STEP_UP_THRESHOLD, the amounts per currency above which withdrawals and
+// transfers need a second factor. An empty value turns step-up verification off.
+func stepUpThresholds(env config.Env) service.StepUpThresholds {
+	thresholds, err := service.ParseStepUpThresholds(env.STEP_UP_THRESHOLD)
+	if err != nil {
+		log.Fatalf("Invalid STEP_UP_THRESHOLD: %v", err)
+	}
+
+	return thresholds
+}
+
+// signingKeyConfig reads JWT_ALGORITHM and JWT_KEY_ROTATION_DAYS.
+func signingKeyConfig(env config.Env) service.SigningKeyConfig {
+	signingConfig, err := service.SigningKeyConfigFromEnv(env)
+	if err != nil {
+		log.Fatalf("Invalid JWT signing configuration: %v", err)
+	}
+
+	return signingConfig
+}
+
+// encryptionKey reads JWT_KEY_ENCRYPTION_KEY, the key API key signing secrets are stored encrypted with.
+func encryptionKey(env config.Env) []byte {
+	key, err := helper.ParseEncryptionKey(env.JWT_KEY_ENCRYPTION_KEY)
+	if err != nil {
+		log.Fatalf("Invalid JWT_KEY_ENCRYPTION_KEY: %v", err)
+	}
+
+	return key
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/handler"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/middleware"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+// InitializeScheduleRouter mounts /schedules on the given wallet group, which authenticates, verifies
+// signatures and requires a verified email.
+func InitializeScheduleRouter(router fiber.Router, db database.DatabaseInterface, env config.Env) {
+	// Repositories
+	accountRepository := core_repository.NewAccountRepository(db)
+	transactionRepository := core_repository.NewTransactionRepository(db)
+	ledgerEntryRepository := core_repository.NewLedgerEntryRepository(db)
+	holdRepository := core_repository.NewHoldRepository(db)
+	webhookRepository := core_repository.NewWebhookRepository(db)
+	outboxRepository := core_repository.NewOutboxRepository(db)
+	feeRuleRepository := core_repository.NewFeeRuleRepository(db)
+	limitRuleRepository := core_repository.NewLimitRuleRepository(db)
+	scheduledTransferRepository := core_repository.NewScheduledTransferRepository(db)
+	userRepository := user_repository.NewUserRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+	recoveryCodeRepository := user_repository.NewRecoveryCodeRepository(db)
+
+	// Services
+	feeService := service.NewFeeService(feeRuleRepository)
+	limitService := service.NewLimitService(limitRuleRepository, transactionRepository, userRepository)
+	auditService := service.NewAuditService(auditLogRepository)
+	walletService := service.NewWalletService(accountRepository, transactionRepository, ledgerEntryRepository, holdRepository, webhookRepository, outboxRepository, feeService, limitService, auditService, db)
+	twoFactorService := service.NewTwoFactorService(userRepository, recoveryCodeRepository, auditService, db.Cache(), encryptionKey(env), stepUpThresholds(env))
+	pinService := service.NewPinService(userRepository, twoFactorService, auditService, db.Cache())
+	scheduleService := service.NewScheduleService(scheduledTransferRepository, accountRepository, walletService)
+
+	// Handlers
+	scheduleHandler := handler.NewScheduleHandler(scheduleService, twoFactorService, pinService)
+
+	// middlewares
+	tokenOnlyMiddleware := middleware.RequireScopes()
+
+	// Base routes
+	scheduleRoute := router.Group("/schedules", tokenOnlyMiddleware)
+
+	// Routes
+	scheduleRoute.Get("/", scheduleHandler.List)
+	scheduleRoute.Post("/", scheduleHandler.Create)
+	scheduleRoute.Get("/:id", scheduleHandler.Get)
+	scheduleRoute.Put("/:id", scheduleHandler.Update)
+	scheduleRoute.Delete("/:id", scheduleHandler.Delete)
+	scheduleRoute.Post("/:id/pause", scheduleHandler.Pause)
+	scheduleRoute.Post("/:id/resume", scheduleHandler.Resume)
+	scheduleRoute.Get("/:id/runs", scheduleHandler.Runs)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/horlakz/wallet-sync.api/internal/config"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/middleware"
+	"github.com/horlakz/wallet-sync.api/model"
 	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
 	"github.com/horlakz/wallet-sync.api/service"
 )
@@ -22,7 +23,7 @@ func InitializeTransactionRouter(router fiber.Router, db database.DatabaseInterf
 	transactionHandler := handler.NewTransactionHandler(transactionService)
 
 	// middlewares
-	authMiddleware := middleware.Protected()
+	authMiddleware := middleware.Protected(db, model.APIKeyScopeTransactionsRead)
 
 	// Base routes
 	transactionRoute := router.Group("/transaction", authMiddleware)
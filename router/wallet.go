@@ -1,13 +1,18 @@
 package router
 
 import (
+	"log"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/shopspring/decimal"
 
 	"github.com/horlakz/wallet-sync.api/handler"
 	"github.com/horlakz/wallet-sync.api/internal/config"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/middleware"
+	"github.com/horlakz/wallet-sync.api/model"
 	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
 	"github.com/horlakz/wallet-sync.api/service"
 )
 
@@ -16,22 +21,72 @@ func InitializeWalletRouter(router fiber.Router, db database.DatabaseInterface,
 	accountRepository := core_repository.NewAccountRepository(db)
 	transactionRepository := core_repository.NewTransactionRepository(db)
 	ledgerEntryRepository := core_repository.NewLedgerEntryRepository(db)
+	holdRepository := core_repository.NewHoldRepository(db)
+	webhookRepository := core_repository.NewWebhookRepository(db)
+	outboxRepository := core_repository.NewOutboxRepository(db)
+	idempotencyKeyRepository := core_repository.NewIdempotencyKeyRepository(db)
+	feeRuleRepository := core_repository.NewFeeRuleRepository(db)
+	limitRuleRepository := core_repository.NewLimitRuleRepository(db)
+	userRepository := user_repository.NewUserRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+	recoveryCodeRepository := user_repository.NewRecoveryCodeRepository(db)
 
 	// Services
-	walletService := service.NewWalletService(accountRepository, transactionRepository, ledgerEntryRepository, db)
+	feeService := service.NewFeeService(feeRuleRepository)
+	limitService := service.NewLimitService(limitRuleRepository, transactionRepository, userRepository)
+	auditService := service.NewAuditService(auditLogRepository)
+	walletService := service.NewWalletService(accountRepository, transactionRepository, ledgerEntryRepository, holdRepository, webhookRepository, outboxRepository, feeService, limitService, auditService, db)
+	twoFactorService := service.NewTwoFactorService(userRepository, recoveryCodeRepository, auditService, db.Cache(), encryptionKey(env), stepUpThresholds(env))
+	pinService := service.NewPinService(userRepository, twoFactorService, auditService, db.Cache())
+	idempotencyService := service.NewIdempotencyService(idempotencyKeyRepository)
+
+	rateProvider, err := service.NewFileRateProvider(env.FX_RATES_FILE)
+	if err != nil {
+		log.Printf("FX rates could not be loaded, conversions are disabled: %v", err)
+		rateProvider = service.NewStaticRateProvider(nil)
+	}
+
+	fxSpread, err := decimal.NewFromString(env.FX_SPREAD)
+	if err != nil {
+		fxSpread = decimal.Zero
+	}
+
+	fxService, err := service.NewFxService(rateProvider, db.Cache(), fxSpread)
+	if err != nil {
+		log.Fatalf("Invalid FX_SPREAD: %v", err)
+	}
 
 	// Handlers
-	walletHandler := handler.NewWalletHandler(walletService)
+	walletHandler := handler.NewWalletHandler(walletService, fxService, feeService, twoFactorService, pinService)
 
-	// middlewares
-	authMiddleware := middleware.Protected()
+	// middlewares, the whole group is authenticated and signed, and API keys are only accepted on
+	// the routes that name the scope they need
+	authMiddleware := middleware.Authenticated(db)
+	signedMiddleware := middleware.Signed(middleware.SignatureConfig{Cache: db.Cache()})
+	verifiedMiddleware := middleware.Verified(db)
+	tokenOnlyMiddleware := middleware.RequireScopes()
+	walletReadMiddleware := middleware.RequireScopes(model.APIKeyScopeWalletRead)
+	transferMiddleware := middleware.RequireScopes(model.APIKeyScopeTransfer)
+	idempotencyMiddleware := middleware.Idempotency(idempotencyService)
 
 	// Base routes
-	walletRoute := router.Group("/wallet", authMiddleware)
+	walletRoute := router.Group("/wallet", authMiddleware, signedMiddleware, verifiedMiddleware)
 
 	// Routes
-	walletRoute.Get("/", walletHandler.GetDetails)
-	walletRoute.Post("/fund", walletHandler.Fund)
-	walletRoute.Post("/withdraw", walletHandler.Withdraw)
-	walletRoute.Post("/transfer", walletHandler.Transfer)
+	walletRoute.Get("/", walletReadMiddleware, walletHandler.GetDetails)
+	walletRoute.Post("/accounts", tokenOnlyMiddleware, walletHandler.Open)
+	walletRoute.Post("/fund", tokenOnlyMiddleware, idempotencyMiddleware, walletHandler.Fund)
+	walletRoute.Post("/withdraw", tokenOnlyMiddleware, idempotencyMiddleware, walletHandler.Withdraw)
+	walletRoute.Post("/transfer", transferMiddleware, idempotencyMiddleware, walletHandler.Transfer)
+	walletRoute.Post("/holds", tokenOnlyMiddleware, idempotencyMiddleware, walletHandler.Authorize)
+	walletRoute.Post("/holds/:reference/capture", tokenOnlyMiddleware, idempotencyMiddleware, walletHandler.Capture)
+	walletRoute.Post("/holds/:reference/void", tokenOnlyMiddleware, walletHandler.Void)
+	walletRoute.Post("/fees/quote", tokenOnlyMiddleware, walletHandler.QuoteFee)
+	walletRoute.Post("/pin", tokenOnlyMiddleware, walletHandler.SetPin)
+	walletRoute.Put("/pin", tokenOnlyMiddleware, walletHandler.ChangePin)
+	walletRoute.Post("/pin/reset", tokenOnlyMiddleware, walletHandler.ResetPin)
+	walletRoute.Post("/convert/quote", tokenOnlyMiddleware, walletHandler.Quote)
+	walletRoute.Post("/convert", tokenOnlyMiddleware, idempotencyMiddleware, walletHandler.Convert)
+
+	InitializeScheduleRouter(walletRoute, db, env)
 }
@@ -0,0 +1,42 @@
+package router
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/horlakz/wallet-sync.api/handler"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/middleware"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	"github.com/horlakz/wallet-sync.api/service"
+)
+
+func InitializeWebhookRouter(router fiber.Router, db database.DatabaseInterface, env config.Env) {
+	// Repositories
+	webhookRepository := core_repository.NewWebhookRepository(db)
+	auditLogRepository := core_repository.NewAuditLogRepository(db)
+
+	// Services
+	auditService := service.NewAuditService(auditLogRepository)
+	webhookService := service.NewWebhookService(webhookRepository, auditService)
+
+	// Handlers
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+
+	// middlewares, endpoints are managed with a login session only
+	authMiddleware := middleware.Protected(db)
+	verifiedMiddleware := middleware.Verified(db)
+
+	// Base routes
+	webhookRoute := router.Group("/webhooks", authMiddleware, verifiedMiddleware)
+
+	// Routes
+	webhookRoute.Get("/", webhookHandler.List)
+	webhookRoute.Post("/", webhookHandler.Create)
+	webhookRoute.Get("/:id", webhookHandler.Get)
+	webhookRoute.Put("/:id", webhookHandler.Update)
+	webhookRoute.Delete("/:id", webhookHandler.Delete)
+	webhookRoute.Get("/:id/deliveries", webhookHandler.Deliveries)
+	webhookRoute.Get("/:id/deliveries/:deliveryId", webhookHandler.Delivery)
+	webhookRoute.Post("/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+var (
+	ErrInvalidRole   = errors.New("unknown role")
+	ErrOwnRoleChange = errors.New("admins cannot change their own role")
+)
+
+type AdminServiceInterface interface {
+	ListUsers(role string, pageable core_repository.Pageable) ([]dto.UserDto, core_repository.Pagination, error)
+	GetUser(userID uuid.UUID) (dto.UserDto, error)
+	SetRole(actorID uuid.UUID, userID uuid.UUID, role string, client dto.ClientInfo) error
+	ListReconciliationLogs(accountID *uuid.UUID, pageable core_repository.Pageable) ([]model.ReconciliationLog, core_repository.Pagination, error)
+}
+
+type adminService struct {
+	userRepo              user_repository.UserRepository
+	reconciliationLogRepo core_repository.ReconciliationLogRepository
+	auditService          AuditServiceInterface
+}
+
+func NewAdminService(
+	userRepo user_repository.UserRepository,
+	reconciliationLogRepo core_repository.ReconciliationLogRepository,
+	auditService AuditServiceInterface,
+) AdminServiceInterface {
+	return &adminService{
+		userRepo:              userRepo,
+		reconciliationLogRepo: reconciliationLogRepo,
+		auditService:          auditService,
+	}
+}
+
+func (s *adminService) ListUsers(role string, pageable core_repository.Pageable) ([]dto.UserDto, core_repository.Pagination, error) {
+	users, pagination, err := s.userRepo.FindAll(role, pageable)
+	if err != nil {
+		return nil, core_repository.Pagination{}, err
+	}
+
+	userDtos := make([]dto.UserDto, 0, len(users))
+	for i := range users {
+		userDtos = append(userDtos, toUserDto(&users[i]))
+	}
+
+	return userDtos, pagination, nil
+}
+
+func (s *adminService) GetUser(userID uuid.UUID) (dto.UserDto, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.UserDto{}, err
+	}
+
+	return toUserDto(user), nil
+}
+
+// SetRole changes a user's role. The new permissions reach the user's tokens on their next refresh.
+func (s *adminService) SetRole(actorID uuid.UUID, userID uuid.UUID, role string, client dto.ClientInfo) error {
+	if !model.ValidRole(role) {
+		return ErrInvalidRole
+	}
+
+	if actorID == userID {
+		return ErrOwnRoleChange
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateRole(user.ID, role); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&user.ID, model.AuditActionRoleChanged, client, map[string]interface{}{
+		"actor_id": actorID,
+		"from":     user.Role,
+		"to":       role,
+	})
+	return nil
+}
+
+func (s *adminService) ListReconciliationLogs(accountID *uuid.UUID, pageable core_repository.Pageable) ([]model.ReconciliationLog, core_repository.Pagination, error) {
+	return s.reconciliationLogRepo.ListReconciliationLogs(accountID, pageable)
+}
+
+func toUserDto(user *model.User) dto.UserDto {
+	return dto.UserDto{
+		ID:               user.ID,
+		Name:             user.Name,
+		Email:            user.Email,
+		Role:             user.Role,
+		KycLevel:         user.KycLevel,
+		VerifiedAt:       user.VerifiedAt,
+		TwoFactorEnabled: user.TwoFactorEnabled(),
+		CreatedAt:        user.CreatedAt,
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// An API key reads wsk_<prefix>_<secret>. The prefix finds the key, the whole key is compared
+// against the stored hash.
+const (
+	apiKeyMarker      = "wsk_"
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+	maxActiveAPIKeys  = 10
+	apiKeyTouchEvery  = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid or revoked API key")
+	ErrAPIKeyIPNotAllowed = errors.New("the API key may not be used from this address")
+	ErrAPIKeyRevoked      = errors.New("API key is already revoked")
+	ErrTooManyAPIKeys     = errors.New("too many active API keys, revoke one first")
+)
+
+// APIKeyInput describes a new API key. An empty AllowedIPs allows every address, and with
+// RequireSignature every request made with the key must be signed.
+type APIKeyInput struct {
+	Name             string
+	Scopes           []string
+	AllowedIPs       []string
+	RequireSignature bool
+}
+
+type APIKeyServiceInterface interface {
+	CreateAPIKey(userID uuid.UUID, input APIKeyInput, client dto.ClientInfo) (dto.APIKeyCreatedDto, error)
+	GetAPIKeys(userID uuid.UUID) ([]dto.APIKeyDto, error)
+	RevokeAPIKey(userID uuid.UUID, id uuid.UUID, client dto.ClientInfo) error
+	Authenticate(key string, ip string) (*model.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo    user_repository.APIKeyRepository
+	auditService  AuditServiceInterface
+	encryptionKey []byte
+}
+
+// NewAPIKeyService stores signing secrets encrypted with encryptionKey, an AES-256 key.
+func NewAPIKeyService(apiKeyRepo user_repository.APIKeyRepository, auditService AuditServiceInterface, encryptionKey []byte) APIKeyServiceInterface {
+	return &apiKeyService{apiKeyRepo: apiKeyRepo, auditService: auditService, encryptionKey: encryptionKey}
+}
+
+// CreateAPIKey stores a new key and returns it with its signing secret. The key itself is not
+// kept, and neither can be shown again.
+func (s *apiKeyService) CreateAPIKey(userID uuid.UUID, input APIKeyInput, client dto.ClientInfo) (dto.APIKeyCreatedDto, error) {
+	active, err := s.apiKeyRepo.CountActiveAPIKeys(userID)
+	if err != nil {
+		return dto.APIKeyCreatedDto{}, err
+	}
+	if active >= maxActiveAPIKeys {
+		return dto.APIKeyCreatedDto{}, ErrTooManyAPIKeys
+	}
+
+	prefix, err := randomHex(apiKeyPrefixBytes)
+	if err != nil {
+		return dto.APIKeyCreatedDto{}, err
+	}
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return dto.APIKeyCreatedDto{}, err
+	}
+
+	signingSecret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return dto.APIKeyCreatedDto{}, err
+	}
+
+	prefix = apiKeyMarker + prefix
+	rawKey := prefix + "_" + secret
+
+	sealedSecret, err := helper.Seal(s.encryptionKey, signingSecret, prefix)
+	if err != nil {
+		return dto.APIKeyCreatedDto{}, err
+	}
+
+	key := &model.APIKey{
+		UserID:           userID,
+		Name:             input.Name,
+		Prefix:           prefix,
+		KeyHash:          hashAPIKey(rawKey),
+		Scopes:           input.Scopes,
+		AllowedIPs:       input.AllowedIPs,
+		SigningSecret:    sealedSecret,
+		RequireSignature: input.RequireSignature,
+	}
+
+	if err := s.apiKeyRepo.CreateAPIKey(key); err != nil {
+		return dto.APIKeyCreatedDto{}, err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionAPIKeyCreated, client, map[string]interface{}{
+		"api_key_id": key.ID,
+		"prefix":     key.Prefix,
+		"scopes":     input.Scopes,
+	})
+
+	return dto.APIKeyCreatedDto{APIKeyDto: toAPIKeyDto(key), Key: rawKey, SigningSecret: signingSecret}, nil
+}
+
+func (s *apiKeyService) GetAPIKeys(userID uuid.UUID) ([]dto.APIKeyDto, error) {
+	keys, err := s.apiKeyRepo.GetAPIKeysByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.APIKeyDto, 0, len(keys))
+	for i := range keys {
+		result = append(result, toAPIKeyDto(&keys[i]))
+	}
+
+	return result, nil
+}
+
+func (s *apiKeyService) RevokeAPIKey(userID uuid.UUID, id uuid.UUID, client dto.ClientInfo) error {
+	key, err := s.apiKeyRepo.GetAPIKeyByID(userID, id)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.apiKeyRepo.RevokeAPIKey(key, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyRevoked
+	}
+
+	s.auditService.Record(&userID, model.AuditActionAPIKeyRevoked, client, map[string]interface{}{
+		"api_key_id": key.ID,
+		"prefix":     key.Prefix,
+	})
+
+	return nil
+}
+
+// Authenticate returns the unrevoked key matching key, if it may be used from ip, with its signing
+// secret decrypted. The last used time is written at most once every apiKeyTouchEvery.
+func (s *apiKeyService) Authenticate(key string, ip string) (*model.APIKey, error) {
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyRepo.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(apiKey.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if !apiKey.AllowsIP(ip) {
+		return nil, ErrAPIKeyIPNotAllowed
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchEvery {
+		if err := s.apiKeyRepo.TouchAPIKey(apiKey.ID, now); err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = &now
+	}
+
+	apiKey.SigningSecret, err = s.signingSecret(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+// signingSecret decrypts the key's signing secret. A secret stored in the clear by an earlier
+// version is encrypted in place.
+func (s *apiKeyService) signingSecret(apiKey *model.APIKey) (string, error) {
+	if apiKey.SigningSecret == "" {
+		return "", nil
+	}
+
+	signingSecret, err := helper.Open(s.encryptionKey, apiKey.SigningSecret, apiKey.Prefix)
+	if !errors.Is(err, helper.ErrNotSealed) {
+		return signingSecret, err
+	}
+
+	signingSecret = apiKey.SigningSecret
+	sealed, err := helper.Seal(s.encryptionKey, signingSecret, apiKey.Prefix)
+	if err != nil {
+		return "", err
+	}
+
+	apiKey.SigningSecret = sealed
+	if err := s.apiKeyRepo.UpdateAPIKeySigningSecret(apiKey); err != nil {
+		return "", err
+	}
+
+	return signingSecret, nil
+}
+
+// IsAPIKey reports whether a credential looks like an API key rather than a token.
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyMarker)
+}
+
+func apiKeyPrefix(key string) (string, bool) {
+	if !IsAPIKey(key) {
+		return "", false
+	}
+
+	prefix, secret, found := strings.Cut(strings.TrimPrefix(key, apiKeyMarker), "_")
+	if !found || len(prefix) != apiKeyPrefixBytes*2 || len(secret) != apiKeySecretBytes*2 {
+		return "", false
+	}
+
+	return apiKeyMarker + prefix, true
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+func toAPIKeyDto(key *model.APIKey) dto.APIKeyDto {
+	allowedIPs := []string(key.AllowedIPs)
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+
+	return dto.APIKeyDto{
+		ID:               key.ID.String(),
+		Name:             key.Name,
+		Prefix:           key.Prefix,
+		Scopes:           key.Scopes,
+		AllowedIPs:       allowedIPs,
+		RequireSignature: key.RequireSignature,
+		LastUsedAt:       key.LastUsedAt,
+		RevokedAt:        key.RevokedAt,
+		CreatedAt:        key.CreatedAt,
+	}
+}
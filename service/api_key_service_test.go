@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// fakeAPIKeyRepository keeps the keys in memory, by prefix.
+type fakeAPIKeyRepository struct {
+	user_repository.APIKeyRepository
+
+	keys map[string]*model.APIKey
+}
+
+func (r *fakeAPIKeyRepository) CountActiveAPIKeys(userID uuid.UUID) (int64, error) {
+	return int64(len(r.keys)), nil
+}
+
+func (r *fakeAPIKeyRepository) CreateAPIKey(key *model.APIKey) error {
+	key.ID = uuid.New()
+	stored := *key
+	r.keys[key.Prefix] = &stored
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKeyByPrefix(prefix string) (*model.APIKey, error) {
+	key, ok := r.keys[prefix]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	copied := *key
+	return &copied, nil
+}
+
+func (r *fakeAPIKeyRepository) TouchAPIKey(id uuid.UUID, usedAt time.Time) error {
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) UpdateAPIKeySigningSecret(key *model.APIKey) error {
+	r.keys[key.Prefix].SigningSecret = key.SigningSecret
+	return nil
+}
+
+// fakeAuditService records the actions it is given.
+type fakeAuditService struct {
+	actions  []string
+	metadata []map[string]interface{}
+}
+
+func (s *fakeAuditService) Record(userID *uuid.UUID, action string, client dto.ClientInfo, metadata map[string]interface{}) {
+	s.actions = append(s.actions, action)
+	s.metadata = append(s.metadata, metadata)
+}
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestAPIKeySigningSecretIsSealed(t *testing.T) {
+	repo := &fakeAPIKeyRepository{keys: map[string]*model.APIKey{}}
+	apiKeyService := NewAPIKeyService(repo, &fakeAuditService{}, testEncryptionKey)
+
+	created, err := apiKeyService.CreateAPIKey(uuid.New(), APIKeyInput{Name: "shop", Scopes: []string{model.APIKeyScopeTransfer}}, dto.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := repo.keys[created.Prefix]
+	if stored.SigningSecret == created.SigningSecret || strings.Contains(stored.SigningSecret, created.SigningSecret) {
+		t.Fatalf("signing secret stored as %q", stored.SigningSecret)
+	}
+	if _, err := helper.Open(testEncryptionKey, stored.SigningSecret, created.Prefix); err != nil {
+		t.Fatalf("opening the stored secret: %v", err)
+	}
+
+	apiKey, err := apiKeyService.Authenticate(created.Key, "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiKey.SigningSecret != created.SigningSecret {
+		t.Errorf("authenticated key has signing secret %q, want the one it was created with", apiKey.SigningSecret)
+	}
+}
+
+func TestAPIKeySigningSecretInTheClearIsSealedOnUse(t *testing.T) {
+	repo := &fakeAPIKeyRepository{keys: map[string]*model.APIKey{}}
+	apiKeyService := NewAPIKeyService(repo, &fakeAuditService{}, testEncryptionKey)
+
+	created, err := apiKeyService.CreateAPIKey(uuid.New(), APIKeyInput{Name: "shop", Scopes: []string{model.APIKeyScopeTransfer}}, dto.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as an earlier version stored it
+	repo.keys[created.Prefix].SigningSecret = created.SigningSecret
+
+	apiKey, err := apiKeyService.Authenticate(created.Key, "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiKey.SigningSecret != created.SigningSecret {
+		t.Errorf("authenticated key has signing secret %q, want %q", apiKey.SigningSecret, created.SigningSecret)
+	}
+
+	opened, err := helper.Open(testEncryptionKey, repo.keys[created.Prefix].SigningSecret, created.Prefix)
+	if err != nil || opened != created.SigningSecret {
+		t.Errorf("stored secret after use opens to %q, %v", opened, err)
+	}
+}
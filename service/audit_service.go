@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+type AuditServiceInterface interface {
+	Record(userID *uuid.UUID, action string, client dto.ClientInfo, metadata map[string]interface{})
+}
+
+type auditService struct {
+	auditLogRepo core_repository.AuditLogRepository
+	logger       *config.Logger
+}
+
+func NewAuditService(auditLogRepo core_repository.AuditLogRepository) AuditServiceInterface {
+	return &auditService{auditLogRepo: auditLogRepo, logger: config.NewLogger()}
+}
+
+// Record writes an audit log entry. A failed write is logged rather than returned, so auditing
+// never changes the outcome of the request being audited.
+func (s *auditService) Record(userID *uuid.UUID, action string, client dto.ClientInfo, metadata map[string]interface{}) {
+	entry := &model.AuditLog{
+		UserID:    userID,
+		Action:    action,
+		IPAddress: client.IPAddress,
+		UserAgent: truncate(client.UserAgent, 255),
+	}
+
+	if metadata != nil {
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			s.logger.Log().Errorf("failed to encode audit metadata for %s: %v", action, err)
+		} else {
+			entry.Metadata = encoded
+		}
+	}
+
+	if err := s.auditLogRepo.Create(entry); err != nil {
+		s.logger.Log().Errorf("failed to write audit log %s: %v", action, err)
+	}
+}
@@ -2,56 +2,343 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
 	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/model"
 	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
 )
 
+var (
+	ErrInvalidRefreshToken      = errors.New("invalid refresh token")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrInvalidResetToken        = errors.New("invalid, expired or already used reset token")
+	ErrAlreadyVerified          = errors.New("email is already verified")
+	ErrInvalidMfaToken          = errors.New("invalid or expired two-factor login token")
+	ErrInvalidUnlockToken       = errors.New("invalid or expired unlock token")
+)
+
 type AuthServiceInterface interface {
-	Login(email, password string) (string, error)
+	Login(email, password string, deviceName string, client dto.ClientInfo) (dto.LoginResponseDTO, error)
+	LoginTwoFactor(mfaToken string, code string, client dto.ClientInfo) (dto.LoginResponseDTO, error)
+	UnlockAccount(token string, client dto.ClientInfo) error
 	Register(data dto.RegisterDTO) error
+	Refresh(refreshToken string) (dto.LoginResponseDTO, error)
+	Logout(sessionID string) error
+	VerifyEmail(token string) error
+	ResendVerification(userID uuid.UUID) error
+	ForgotPassword(email string) error
+	ResetPassword(token string, password string) error
+	SetupTwoFactor(userID uuid.UUID) (dto.TwoFactorSetupDto, error)
+	ConfirmTwoFactor(userID uuid.UUID, code string) (dto.RecoveryCodesDto, error)
+	DisableTwoFactor(userID uuid.UUID, code string, client dto.ClientInfo) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string, client dto.ClientInfo) (dto.RecoveryCodesDto, error)
 }
 
 type authService struct {
-	userRepo user_repository.UserRepository
-	encrpyt  helper.HashingInterface
-	jwt      helper.JwtInterface
+	userRepo         user_repository.UserRepository
+	tokenStore       TokenStoreInterface
+	sessionService   SessionServiceInterface
+	twoFactorService TwoFactorServiceInterface
+	auditService     AuditServiceInterface
+	guard            *loginGuard
+	mailer           config.EmailInterface
+	appURL           string
+	encrpyt          helper.HashingInterface
+	jwt              helper.JwtInterface
+	logger           *config.Logger
 }
 
-func NewAuthService(userRepo user_repository.UserRepository) AuthServiceInterface {
+// NewAuthService builds the auth service, appURL is the frontend base URL used in email links.
+func NewAuthService(userRepo user_repository.UserRepository, tokenStore TokenStoreInterface, sessionService SessionServiceInterface, twoFactorService TwoFactorServiceInterface, auditService AuditServiceInterface, cache database.RedisClientInterface, mailer config.EmailInterface, appURL string) AuthServiceInterface {
 	return &authService{
-		userRepo: userRepo,
-		encrpyt:  helper.NewHashing(),
-		jwt:      helper.NewJwt(),
+		userRepo:         userRepo,
+		tokenStore:       tokenStore,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		auditService:     auditService,
+		guard:            newLoginGuard(cache),
+		mailer:           mailer,
+		appURL:           strings.TrimRight(appURL, "/"),
+		encrpyt:          helper.NewHashing(),
+		jwt:              helper.NewJwt(),
+		logger:           config.NewLogger(),
 	}
 }
 
-func (s *authService) Login(email, password string) (string, error) {
+// Login checks the credentials of an email. Failed attempts are counted per email and per IP
+// address and lead to a backoff, a lock or a block, see loginGuard. An unknown email fails the
+// same way as a wrong password. Each login starts a session named after deviceName.
+func (s *authService) Login(email, password string, deviceName string, client dto.ClientInfo) (dto.LoginResponseDTO, error) {
+	if err := s.guard.check(email, client.IPAddress); err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return "", err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return dto.LoginResponseDTO{}, s.loginFailed(email, nil, client)
+		}
+		return dto.LoginResponseDTO{}, err
 	}
 
 	match, err := s.encrpyt.ComparePassword(password, user.Password)
 	if err != nil {
-		return "", err
+		return dto.LoginResponseDTO{}, err
 	}
 
 	if !match {
-		return "", errors.New("invalid credentials")
+		return dto.LoginResponseDTO{}, s.loginFailed(email, user, client)
+	}
+
+	// with two-factor on, the password only earns a short lived token to exchange with a code
+	if user.TwoFactorEnabled() {
+		mfaToken, err := s.jwt.CreateTokenWithClaims(user.ID.String(), "mfa", map[string]interface{}{
+			"device": deviceName,
+		})
+		if err != nil {
+			return dto.LoginResponseDTO{}, err
+		}
+
+		return dto.LoginResponseDTO{MfaRequired: true, MfaToken: mfaToken}, nil
+	}
+
+	if err := s.guard.succeed(user.Email); err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	return s.startSession(user, deviceName, client)
+}
+
+// LoginTwoFactor completes a login that Login answered with an mfa token. A wrong code counts as
+// a failed login of the user's email.
+func (s *authService) LoginTwoFactor(mfaToken string, code string, client dto.ClientInfo) (dto.LoginResponseDTO, error) {
+	claims, err := s.jwt.ExtractClaims(mfaToken, "mfa")
+	if err != nil {
+		return dto.LoginResponseDTO{}, ErrInvalidMfaToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return dto.LoginResponseDTO{}, ErrInvalidMfaToken
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.LoginResponseDTO{}, ErrInvalidMfaToken
+	}
+
+	if err := s.guard.check(user.Email, client.IPAddress); err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	if err := s.twoFactorService.Verify(user, code, client); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if failErr := s.loginFailed(user.Email, user, client); failErr != errInvalidCredentials {
+				return dto.LoginResponseDTO{}, failErr
+			}
+		}
+		return dto.LoginResponseDTO{}, err
+	}
+
+	if err := s.guard.succeed(user.Email); err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	deviceName, _ := claims["device"].(string)
+	return s.startSession(user, deviceName, client)
+}
+
+// loginFailed counts a failed login and records the attempts that look like guessing. It returns
+// the error to answer the attempt with, which is more specific once a lock or block started.
+func (s *authService) loginFailed(email string, user *model.User, client dto.ClientInfo) error {
+	failure, err := s.guard.fail(email, client.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	var userID *uuid.UUID
+	if user != nil {
+		userID = &user.ID
+	}
+
+	if failure.ipBlocked {
+		s.auditService.Record(userID, model.AuditActionLoginIPBlocked, client, map[string]interface{}{
+			"email":    email,
+			"failures": failure.ipFailures,
+		})
+		return &LoginError{Code: CodeIPBlocked, Message: "too many failed logins from this address, try again later", RetryAfter: ipBlockDuration}
+	}
+
+	if failure.locked {
+		s.auditService.Record(userID, model.AuditActionLoginLocked, client, map[string]interface{}{
+			"email":    email,
+			"failures": failure.emailFailures,
+		})
+
+		if user != nil {
+			go func() {
+				if err := s.sendUnlockEmail(user); err != nil {
+					s.logger.Log().Errorf("failed to send unlock email to %s: %v", user.Email, err)
+				}
+			}()
+		}
+
+		return &LoginError{Code: CodeAccountLocked, Message: "the account is temporarily locked, check your email to unlock it", RetryAfter: loginLockDuration}
+	}
+
+	// the first throttled failure is recorded, the ones after it follow from it
+	if failure.emailFailures == loginBackoffAfter {
+		s.auditService.Record(userID, model.AuditActionLoginThrottled, client, map[string]interface{}{
+			"email":    email,
+			"failures": failure.emailFailures,
+		})
+	}
+
+	return errInvalidCredentials
+}
+
+// UnlockAccount lifts a login lock with the token of an unlock email.
+func (s *authService) UnlockAccount(token string, client dto.ClientInfo) error {
+	claims, err := s.jwt.ExtractClaims(token, "unlock_account")
+	if err != nil {
+		return ErrInvalidUnlockToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return ErrInvalidUnlockToken
 	}
 
-	accessToken, err := s.jwt.CreateToken(user.ID.String(), "access")
+	user, err := s.userRepo.FindByID(userID)
 	if err != nil {
-		return "", err
+		return ErrInvalidUnlockToken
+	}
+
+	if email, _ := claims["email"].(string); !strings.EqualFold(email, user.Email) {
+		return ErrInvalidUnlockToken
+	}
+
+	if err := s.guard.unlock(user.Email); err != nil {
+		return err
 	}
 
-	return accessToken, nil
+	s.auditService.Record(&user.ID, model.AuditActionLoginUnlocked, client, nil)
+
+	return nil
+}
+
+// startSession records the session and issues its tokens, the session's ID is their sid claim.
+func (s *authService) startSession(user *model.User, deviceName string, client dto.ClientInfo) (dto.LoginResponseDTO, error) {
+	lifetime := s.jwt.TokenLifetime("refresh")
+
+	session, err := s.sessionService.CreateSession(user.ID, deviceName, client, time.Now().Add(lifetime))
+	if err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	sessionID := session.ID.String()
+	tokens, refreshTokenID, err := s.issueTokens(user, sessionID)
+	if err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	if err := s.tokenStore.StartSession(sessionID, refreshTokenID, lifetime); err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair of the same session.
+func (s *authService) Refresh(refreshToken string) (dto.LoginResponseDTO, error) {
+	claims, err := s.jwt.ExtractClaims(refreshToken, "refresh")
+	if err != nil {
+		return dto.LoginResponseDTO{}, ErrInvalidRefreshToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	sessionID, _ := claims["sid"].(string)
+	refreshTokenID, _ := claims["jti"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil || sessionID == "" || refreshTokenID == "" {
+		return dto.LoginResponseDTO{}, ErrInvalidRefreshToken
+	}
+
+	// the user is read again so that role changes reach the new access token
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.LoginResponseDTO{}, ErrInvalidRefreshToken
+	}
+
+	tokens, nextRefreshTokenID, err := s.issueTokens(user, sessionID)
+	if err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	lifetime := s.jwt.TokenLifetime("refresh")
+	if err := s.tokenStore.RotateRefreshToken(sessionID, refreshTokenID, nextRefreshTokenID, lifetime); err != nil {
+		// the token store already ended a session whose refresh token was reused, the record follows
+		if errors.Is(err, ErrRefreshTokenReused) {
+			if endErr := s.sessionService.EndSession(sessionID); endErr != nil {
+				s.logger.Log().Errorf("failed to record session %s as revoked: %v", sessionID, endErr)
+			}
+		}
+		return dto.LoginResponseDTO{}, err
+	}
+
+	if err := s.sessionService.ExtendSession(sessionID, time.Now().Add(lifetime)); err != nil {
+		return dto.LoginResponseDTO{}, err
+	}
+
+	return tokens, nil
+}
+
+func (s *authService) Logout(sessionID string) error {
+	return s.sessionService.EndSession(sessionID)
+}
+
+// issueTokens signs an access and a refresh token for the session and returns the refresh token ID.
+// The access token carries the user's role and permissions.
+func (s *authService) issueTokens(user *model.User, sessionID string) (dto.LoginResponseDTO, string, error) {
+	userID := user.ID.String()
+	refreshTokenID := uuid.NewString()
+
+	accessToken, err := s.jwt.CreateTokenWithClaims(userID, "access", map[string]interface{}{
+		"sid":         sessionID,
+		"jti":         uuid.NewString(),
+		"role":        user.Role,
+		"permissions": user.Permissions(),
+	})
+	if err != nil {
+		return dto.LoginResponseDTO{}, "", err
+	}
+
+	refreshToken, err := s.jwt.CreateTokenWithClaims(userID, "refresh", map[string]interface{}{
+		"sid": sessionID,
+		"jti": refreshTokenID,
+	})
+	if err != nil {
+		return dto.LoginResponseDTO{}, "", err
+	}
+
+	return dto.LoginResponseDTO{AccessToken: accessToken, RefreshToken: refreshToken}, refreshTokenID, nil
 }
 
 func (s *authService) Register(data dto.RegisterDTO) error {
+	data.Email = model.NormalizeEmail(data.Email)
+
 	hashedPassword, err := s.encrpyt.HashPassword(data.Password)
 	if err != nil {
 		return err
@@ -70,5 +357,209 @@ func (s *authService) Register(data dto.RegisterDTO) error {
 		return err
 	}
 
+	// the account exists even if the email fails, the user can ask for it again
+	go func() {
+		if err := s.sendVerificationEmail(user); err != nil {
+			s.logger.Log().Errorf("failed to send verification email to %s: %v", user.Email, err)
+		}
+	}()
+
 	return nil
 }
+
+// VerifyEmail marks the user of a verification token as verified. The token carries the email it
+// was sent to, so a token issued before an email change does not verify the new address.
+func (s *authService) VerifyEmail(token string) error {
+	claims, err := s.jwt.ExtractClaims(token, "verify_email")
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	if email, _ := claims["email"].(string); !strings.EqualFold(email, user.Email) {
+		return ErrInvalidVerificationToken
+	}
+
+	if user.VerifiedAt != nil {
+		return nil
+	}
+
+	return s.userRepo.MarkVerified(user.ID, time.Now())
+}
+
+func (s *authService) ResendVerification(userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.VerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+
+	return s.sendVerificationEmail(user)
+}
+
+// ForgotPassword emails a single-use reset link. Unknown emails are ignored without an error,
+// so the endpoint cannot be used to find out who has an account.
+func (s *authService) ForgotPassword(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	tokenID := uuid.NewString()
+	lifetime := s.jwt.TokenLifetime("reset_password")
+
+	token, err := s.jwt.CreateTokenWithClaims(user.ID.String(), "reset_password", map[string]interface{}{
+		"jti": tokenID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenStore.StorePasswordResetToken(tokenID, lifetime); err != nil {
+		return err
+	}
+
+	return s.mailer.SendWithTemplate(user.Email, "Reset your password", "templates/reset_password.html", map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      s.appURL + "/reset-password?token=" + url.QueryEscape(token),
+		"ExpiresIn": durationText(lifetime),
+	})
+}
+
+func (s *authService) ResetPassword(token string, password string) error {
+	claims, err := s.jwt.ExtractClaims(token, "reset_password")
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	tokenID, _ := claims["jti"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil || tokenID == "" {
+		return ErrInvalidResetToken
+	}
+
+	unused, err := s.tokenStore.ConsumePasswordResetToken(tokenID)
+	if err != nil {
+		return err
+	}
+	if !unused {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.encrpyt.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		return err
+	}
+
+	// whoever can reset the password is the owner, so a lock from guessing it no longer helps,
+	// and sessions started with the old password are ended
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.unlock(user.Email); err != nil {
+		return err
+	}
+
+	return s.sessionService.RevokeAllSessions(user.ID)
+}
+
+func (s *authService) SetupTwoFactor(userID uuid.UUID) (dto.TwoFactorSetupDto, error) {
+	return s.twoFactorService.Setup(userID)
+}
+
+func (s *authService) ConfirmTwoFactor(userID uuid.UUID, code string) (dto.RecoveryCodesDto, error) {
+	codes, err := s.twoFactorService.Confirm(userID, code)
+	if err != nil {
+		return dto.RecoveryCodesDto{}, err
+	}
+
+	return dto.RecoveryCodesDto{RecoveryCodes: codes}, nil
+}
+
+func (s *authService) DisableTwoFactor(userID uuid.UUID, code string, client dto.ClientInfo) error {
+	return s.twoFactorService.Disable(userID, code, client)
+}
+
+func (s *authService) RegenerateRecoveryCodes(userID uuid.UUID, code string, client dto.ClientInfo) (dto.RecoveryCodesDto, error) {
+	codes, err := s.twoFactorService.RegenerateRecoveryCodes(userID, code, client)
+	if err != nil {
+		return dto.RecoveryCodesDto{}, err
+	}
+
+	return dto.RecoveryCodesDto{RecoveryCodes: codes}, nil
+}
+
+func (s *authService) sendVerificationEmail(user *model.User) error {
+	lifetime := s.jwt.TokenLifetime("verify_email")
+
+	token, err := s.jwt.CreateTokenWithClaims(user.ID.String(), "verify_email", map[string]interface{}{
+		"email": user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.SendWithTemplate(user.Email, "Verify your email", "templates/verify_email.html", map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      s.appURL + "/verify-email?token=" + url.QueryEscape(token),
+		"ExpiresIn": durationText(lifetime),
+	})
+}
+
+func (s *authService) sendUnlockEmail(user *model.User) error {
+	lifetime := s.jwt.TokenLifetime("unlock_account")
+
+	token, err := s.jwt.CreateTokenWithClaims(user.ID.String(), "unlock_account", map[string]interface{}{
+		"email": user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.SendWithTemplate(user.Email, "Your account has been locked", "templates/unlock_account.html", map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      s.appURL + "/unlock-account?token=" + url.QueryEscape(token),
+		"LockedFor": durationText(loginLockDuration),
+		"ExpiresIn": durationText(lifetime),
+	})
+}
+
+// durationText writes a duration in whole hours, or in minutes when it is shorter than an hour.
+func durationText(duration time.Duration) string {
+	if duration < time.Hour {
+		minutes := int(duration.Minutes())
+		if minutes == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+
+	hours := int(duration.Hours())
+	if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// fakeUserRepository keeps users in memory.
+type fakeUserRepository struct {
+	user_repository.UserRepository
+
+	users map[uuid.UUID]*model.User
+}
+
+func (r *fakeUserRepository) Create(user *model.User) error {
+	user.ID = uuid.New()
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeUserRepository) FindByEmail(email string) (*model.User, error) {
+	for _, user := range r.users {
+		if user.Email == model.NormalizeEmail(email) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepository) FindByID(id uuid.UUID) (*model.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepository) MarkVerified(id uuid.UUID, verifiedAt time.Time) error {
+	r.users[id].VerifiedAt = &verifiedAt
+	return nil
+}
+
+func (r *fakeUserRepository) UpdatePassword(id uuid.UUID, password string) error {
+	r.users[id].Password = password
+	return nil
+}
+
+func (r *fakeUserRepository) SetTotpSecret(id uuid.UUID, secret string) error {
+	r.users[id].TotpSecret = secret
+	r.users[id].TotpEnabledAt = nil
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateTotpSecret(id uuid.UUID, secret string) error {
+	r.users[id].TotpSecret = secret
+	return nil
+}
+
+// fakeSessionService records whose sessions were revoked.
+type fakeSessionService struct {
+	SessionServiceInterface
+
+	revoked []uuid.UUID
+}
+
+func (s *fakeSessionService) RevokeAllSessions(userID uuid.UUID) error {
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
+
+// fakeTokenCache keeps the token store's keys in memory, ignoring their TTL.
+type fakeTokenCache struct {
+	database.RedisClientInterface
+
+	values map[string]string
+}
+
+func newFakeTokenCache() *fakeTokenCache {
+	return &fakeTokenCache{values: map[string]string{}}
+}
+
+func (c *fakeTokenCache) SetValue(key string, value interface{}, ttl time.Duration) error {
+	switch v := value.(type) {
+	case string:
+		c.values[key] = v
+	case int:
+		c.values[key] = strconv.Itoa(v)
+	default:
+		return errors.New("unsupported value")
+	}
+	return nil
+}
+
+func (c *fakeTokenCache) GetValue(key string) (string, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return "", database.ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (c *fakeTokenCache) Delete(keys ...string) (int64, error) {
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := c.values[key]; ok {
+			delete(c.values, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+var emailTokenPattern = regexp.MustCompile(`token=([^"]+)"`)
+
+// emailToken returns the token of the link in an email.
+func emailToken(t *testing.T, message config.EmailMessage) string {
+	t.Helper()
+
+	match := emailTokenPattern.FindStringSubmatch(message.Body)
+	if match == nil {
+		t.Fatalf("no link in %q", message.Body)
+	}
+
+	token, err := url.QueryUnescape(html.UnescapeString(match[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// waitForEmail waits until the mailbox holds count emails and returns the last one.
+func waitForEmail(t *testing.T, mailbox *config.MemoryEmail, count int) config.EmailMessage {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if messages := mailbox.Messages(); len(messages) >= count {
+			return messages[count-1]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("email %d was not sent", count)
+	return config.EmailMessage{}
+}
+
+func newTestAuthService(t *testing.T) (AuthServiceInterface, *fakeUserRepository, *fakeSessionService, *config.MemoryEmail) {
+	t.Helper()
+
+	// the email templates are read relative to the repository root
+	t.Chdir("..")
+	t.Setenv("JWT_ACCESS_SECRET", "test-access-secret")
+	t.Setenv("JWT_REFRESH_SECRET", "test-refresh-secret")
+
+	userRepo := &fakeUserRepository{users: map[uuid.UUID]*model.User{}}
+	sessionService := &fakeSessionService{}
+	mailbox := config.NewMemoryEmail()
+
+	authService := NewAuthService(userRepo, NewTokenStore(newFakeTokenCache()), sessionService, nil, nil, newFakeTokenCache(), mailbox, "https://app.example.com/")
+	return authService, userRepo, sessionService, mailbox
+}
+
+func TestVerifyEmail(t *testing.T) {
+	authService, userRepo, _, mailbox := newTestAuthService(t)
+
+	if err := authService.Register(dto.RegisterDTO{Name: "Ada", Email: "Ada@Example.com", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+
+	message := waitForEmail(t, mailbox, 1)
+	if message.To != "ada@example.com" || message.Subject != "Verify your email" {
+		t.Errorf("email = %s %q, want the verification email to ada@example.com", message.To, message.Subject)
+	}
+
+	user, _ := userRepo.FindByEmail("ada@example.com")
+	if user.VerifiedAt != nil {
+		t.Fatal("the user is verified before following the link")
+	}
+
+	if err := authService.VerifyEmail("not-a-token"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail with a bad token = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+
+	token := emailToken(t, message)
+	if err := authService.VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ = userRepo.FindByID(user.ID)
+	if user.VerifiedAt == nil {
+		t.Error("the user was not verified")
+	}
+
+	// a link sent to an address the user has since changed no longer verifies
+	userRepo.users[user.ID].Email = "ada@elsewhere.example"
+	userRepo.users[user.ID].VerifiedAt = nil
+	if err := authService.VerifyEmail(token); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail after an email change = %v, want %v", err, ErrInvalidVerificationToken)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	authService, userRepo, sessionService, mailbox := newTestAuthService(t)
+
+	if err := authService.Register(dto.RegisterDTO{Name: "Ada", Email: "ada@example.com", Password: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	waitForEmail(t, mailbox, 1)
+
+	// unknown emails get no email and no error
+	if err := authService.ForgotPassword("nobody@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := authService.ForgotPassword("ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	message := waitForEmail(t, mailbox, 2)
+	if message.To != "ada@example.com" || message.Subject != "Reset your password" {
+		t.Errorf("email = %s %q, want the reset email to ada@example.com", message.To, message.Subject)
+	}
+	if len(mailbox.Messages()) != 2 {
+		t.Errorf("%d emails sent, want 2", len(mailbox.Messages()))
+	}
+
+	user, _ := userRepo.FindByEmail("ada@example.com")
+	token := emailToken(t, message)
+
+	if err := authService.ResetPassword(token, "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ = userRepo.FindByID(user.ID)
+	if match, _ := helper.NewHashing().ComparePassword("battery staple", user.Password); !match {
+		t.Error("the password was not changed")
+	}
+	if len(sessionService.revoked) != 1 || sessionService.revoked[0] != user.ID {
+		t.Errorf("revoked sessions of %v, want %s", sessionService.revoked, user.ID)
+	}
+
+	// the link works once
+	if err := authService.ResetPassword(token, "another password"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second ResetPassword = %v, want %v", err, ErrInvalidResetToken)
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/internal/constants"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+)
+
+var (
+	ErrInvalidCurrency  = errors.New("currency is not a supported ISO 4217 code")
+	ErrInvalidAmount    = errors.New("amount must be greater than zero")
+	ErrAmountPrecision  = errors.New("amount has more decimal places than the currency allows")
+	ErrCurrencyMismatch = errors.New("source and destination wallets use different currencies")
+	ErrQuoteMismatch    = errors.New("quote does not match the transfer's currencies and amount")
+)
+
+// resolveCurrency normalises a requested currency code, falling back to the default currency.
+func resolveCurrency(code string) (helper.Currency, error) {
+	if strings.TrimSpace(code) == "" {
+		code = constants.DEFAULT_CURRENCY
+	}
+
+	currency, ok := helper.LookupCurrency(code)
+	if !ok {
+		return helper.Currency{}, fmt.Errorf("%w: %q", ErrInvalidCurrency, code)
+	}
+
+	return currency, nil
+}
+
+// checkAmount resolves the currency and rejects amounts that are not positive or
+// that cannot be represented in the currency's minor unit.
+func checkAmount(code string, amount decimal.Decimal) (helper.Currency, error) {
+	currency, err := resolveCurrency(code)
+	if err != nil {
+		return helper.Currency{}, err
+	}
+
+	if !amount.IsPositive() {
+		return helper.Currency{}, ErrInvalidAmount
+	}
+
+	if !amount.Equal(amount.Round(currency.MinorUnits)) {
+		return helper.Currency{}, fmt.Errorf("%w: %s uses %d decimal places", ErrAmountPrecision, currency.Code, currency.MinorUnits)
+	}
+
+	return currency, nil
+}
+
+// minorUnits returns the precision of a stored currency code, defaulting to two places.
+func minorUnits(code string) int32 {
+	if currency, ok := helper.LookupCurrency(code); ok {
+		return currency.MinorUnits
+	}
+	return 2
+}
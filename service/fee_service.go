@@ -0,0 +1,112 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+var ErrInvalidFeeRule = errors.New("invalid fee rule")
+
+type FeeServiceInterface interface {
+	QuoteFee(operation string, currency string, amount decimal.Decimal) (dto.FeeDto, error)
+}
+
+type feeService struct {
+	feeRuleRepo core_repository.FeeRuleRepository
+}
+
+func NewFeeService(feeRuleRepo core_repository.FeeRuleRepository) FeeServiceInterface {
+	return &feeService{
+		feeRuleRepo: feeRuleRepo,
+	}
+}
+
+// QuoteFee returns the fee charged on top of amount for the operation. Operations without an
+// active rule for the currency are free.
+func (s *feeService) QuoteFee(operation string, currency string, amount decimal.Decimal) (dto.FeeDto, error) {
+	walletCurrency, err := checkAmount(currency, amount)
+	if err != nil {
+		return dto.FeeDto{}, err
+	}
+
+	quote := dto.FeeDto{
+		Operation: operation,
+		Currency:  walletCurrency.Code,
+		Amount:    amount,
+		Fee:       decimal.Zero,
+		Total:     amount,
+	}
+
+	rule, err := s.feeRuleRepo.GetActiveFeeRule(operation, walletCurrency.Code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return quote, nil
+		}
+		return dto.FeeDto{}, err
+	}
+
+	fee, err := calculateFee(rule, amount)
+	if err != nil {
+		return dto.FeeDto{}, err
+	}
+
+	quote.Kind = rule.Kind
+	quote.Fee = fee.Round(minorUnits(walletCurrency.Code))
+	quote.Total = amount.Add(quote.Fee)
+
+	return quote, nil
+}
+
+// calculateFee applies the rule to amount and clamps the result to the rule's minimum and maximum.
+func calculateFee(rule *model.FeeRule, amount decimal.Decimal) (decimal.Decimal, error) {
+	var fee decimal.Decimal
+
+	switch rule.Kind {
+	case model.FeeKindFlat:
+		fee = rule.FlatAmount
+	case model.FeeKindPercentage:
+		fee = amount.Mul(rule.Percentage)
+	case model.FeeKindTiered:
+		var tiers []model.FeeTier
+		if err := json.Unmarshal(rule.Tiers, &tiers); err != nil {
+			return decimal.Zero, fmt.Errorf("%w: %v", ErrInvalidFeeRule, err)
+		}
+
+		tier, ok := feeTierFor(tiers, amount)
+		if !ok {
+			return decimal.Zero, fmt.Errorf("%w: no tier covers %s", ErrInvalidFeeRule, amount)
+		}
+
+		fee = tier.FlatAmount.Add(amount.Mul(tier.Percentage))
+	default:
+		return decimal.Zero, fmt.Errorf("%w: unknown kind %q", ErrInvalidFeeRule, rule.Kind)
+	}
+
+	if fee.LessThan(rule.MinFee) {
+		fee = rule.MinFee
+	}
+
+	if rule.MaxFee.IsPositive() && fee.GreaterThan(rule.MaxFee) {
+		fee = rule.MaxFee
+	}
+
+	return fee, nil
+}
+
+// feeTierFor picks the first tier whose bound covers amount, tiers are expected in ascending order.
+func feeTierFor(tiers []model.FeeTier, amount decimal.Decimal) (model.FeeTier, bool) {
+	for _, tier := range tiers {
+		if tier.UpTo.IsZero() || amount.LessThanOrEqual(tier.UpTo) {
+			return tier, true
+		}
+	}
+	return model.FeeTier{}, false
+}
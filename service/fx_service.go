@@ -0,0 +1,164 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+var (
+	// QuoteTTL is how long a quoted rate is locked for the user.
+	QuoteTTL = 30 * time.Second
+
+	ErrQuoteNotFound   = errors.New("quote not found or expired")
+	ErrSameCurrency    = errors.New("source and target currency must differ")
+	ErrQuoteTooSmall   = errors.New("amount is too small to convert")
+	ErrInvalidFxSpread = errors.New("fx spread must be between 0 and 1")
+)
+
+// storedQuote is the quote as locked in Redis, bound to the user it was issued to.
+type storedQuote struct {
+	dto.ConversionQuoteDto
+	UserID uuid.UUID `json:"user_id"`
+}
+
+type FxServiceInterface interface {
+	Quote(userID uuid.UUID, fromCurrency, toCurrency string, amount decimal.Decimal) (dto.ConversionQuoteDto, error)
+}
+
+type fxService struct {
+	rateProvider RateProvider
+	cache        database.RedisClientInterface
+	spread       decimal.Decimal
+}
+
+// NewFxService quotes conversions at the provider's mid rate less spread, a fraction such as 0.01 for 1%.
+func NewFxService(rateProvider RateProvider, cache database.RedisClientInterface, spread decimal.Decimal) (FxServiceInterface, error) {
+	if spread.IsNegative() || spread.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return nil, ErrInvalidFxSpread
+	}
+
+	return &fxService{
+		rateProvider: rateProvider,
+		cache:        cache,
+		spread:       spread,
+	}, nil
+}
+
+// Quote prices converting amount of fromCurrency and locks the rate in Redis for QuoteTTL.
+func (s *fxService) Quote(userID uuid.UUID, fromCurrency, toCurrency string, amount decimal.Decimal) (dto.ConversionQuoteDto, error) {
+	from, err := checkAmount(fromCurrency, amount)
+	if err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	to, err := resolveCurrency(toCurrency)
+	if err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	if from.Code == to.Code {
+		return dto.ConversionQuoteDto{}, ErrSameCurrency
+	}
+
+	midRate, err := s.rateProvider.GetRate(from.Code, to.Code)
+	if err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	rate := midRate.Mul(decimal.NewFromInt(1).Sub(s.spread))
+	targetAmount := amount.Mul(rate).RoundDown(to.MinorUnits)
+	if !targetAmount.IsPositive() {
+		return dto.ConversionQuoteDto{}, ErrQuoteTooSmall
+	}
+
+	quoteID, err := uuid.NewV7()
+	if err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	quote := dto.ConversionQuoteDto{
+		QuoteID:      quoteID.String(),
+		FromCurrency: from.Code,
+		ToCurrency:   to.Code,
+		SourceAmount: amount,
+		TargetAmount: targetAmount,
+		MidRate:      midRate,
+		Rate:         rate,
+		Spread:       s.spread,
+		ExpiresAt:    time.Now().Add(QuoteTTL),
+	}
+
+	encoded, err := json.Marshal(storedQuote{ConversionQuoteDto: quote, UserID: userID})
+	if err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	if err := s.cache.SetValue(fxQuoteKey(quote.QuoteID), encoded, QuoteTTL); err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	return quote, nil
+}
+
+func fxQuoteKey(quoteID string) string {
+	return "fx_quote:" + quoteID
+}
+
+// consumeQuote loads a user's quote and removes it, so that a quote is honoured at most once. A caller
+// whose conversion then fails gives it back with restoreQuote.
+func consumeQuote(cache database.RedisClientInterface, userID uuid.UUID, quoteID string) (dto.ConversionQuoteDto, error) {
+	var quote storedQuote
+
+	encoded, err := cache.GetValue(fxQuoteKey(quoteID))
+	if err != nil {
+		if errors.Is(err, database.ErrCacheMiss) {
+			return dto.ConversionQuoteDto{}, ErrQuoteNotFound
+		}
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	if err := json.Unmarshal([]byte(encoded), &quote); err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	if quote.UserID != userID || quote.ExpiresAt.Before(time.Now()) {
+		return dto.ConversionQuoteDto{}, ErrQuoteNotFound
+	}
+
+	// only the caller that actually deletes the key may use the quote
+	deleted, err := cache.Delete(fxQuoteKey(quoteID))
+	if err != nil {
+		return dto.ConversionQuoteDto{}, err
+	}
+
+	if deleted == 0 {
+		return dto.ConversionQuoteDto{}, ErrQuoteNotFound
+	}
+
+	return quote.ConversionQuoteDto, nil
+}
+
+// restoreQuote puts back a quote taken by consumeQuote when the money movement it priced failed, for
+// what is left of its lifetime, so the user can try again with it. It returns cause; a quote that
+// cannot be restored has the user ask for a new one.
+func restoreQuote(cache database.RedisClientInterface, userID uuid.UUID, quote dto.ConversionQuoteDto, cause error) error {
+	ttl := time.Until(quote.ExpiresAt)
+	if ttl <= 0 {
+		return cause
+	}
+
+	encoded, err := json.Marshal(storedQuote{ConversionQuoteDto: quote, UserID: userID})
+	if err != nil {
+		return cause
+	}
+
+	_ = cache.SetValue(fxQuoteKey(quote.QuoteID), encoded, ttl)
+	return cause
+}
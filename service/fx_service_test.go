@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+// fakeCache keeps values in memory, ignoring their TTL.
+type fakeCache struct {
+	database.RedisClientInterface
+
+	values map[string]string
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{values: map[string]string{}}
+}
+
+func (c *fakeCache) SetValue(key string, value interface{}, ttl time.Duration) error {
+	switch v := value.(type) {
+	case []byte:
+		c.values[key] = string(v)
+	case string:
+		c.values[key] = v
+	default:
+		return errors.New("unsupported value")
+	}
+	return nil
+}
+
+func (c *fakeCache) GetValue(key string) (string, error) {
+	value, ok := c.values[key]
+	if !ok {
+		return "", database.ErrCacheMiss
+	}
+	return value, nil
+}
+
+func (c *fakeCache) Delete(keys ...string) (int64, error) {
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := c.values[key]; ok {
+			delete(c.values, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func newTestFxService(t *testing.T, cache database.RedisClientInterface) FxServiceInterface {
+	t.Helper()
+
+	rates := NewStaticRateProvider(map[string]decimal.Decimal{
+		"usd/ngn": decimal.NewFromInt(1500),
+	})
+
+	fxService, err := NewFxService(rates, cache, decimal.RequireFromString("0.01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fxService
+}
+
+func TestFxQuote(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       string
+		to         string
+		amount     string
+		wantRate   string
+		wantTarget string
+		wantErr    error
+	}{
+		{name: "configured pair", from: "USD", to: "NGN", amount: "10", wantRate: "1485", wantTarget: "14850"},
+		{name: "inverse pair rounds down", from: "NGN", to: "USD", amount: "1000", wantRate: "0.00066000000033", wantTarget: "0.66"},
+		{name: "too small", from: "NGN", to: "USD", amount: "1", wantErr: ErrQuoteTooSmall},
+		{name: "same currency", from: "USD", to: "USD", amount: "10", wantErr: ErrSameCurrency},
+		{name: "no rate", from: "USD", to: "EUR", amount: "10", wantErr: ErrRateUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newFakeCache()
+			quote, err := newTestFxService(t, cache).Quote(uuid.New(), tt.from, tt.to, decimal.RequireFromString(tt.amount))
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Quote = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !quote.Rate.Equal(decimal.RequireFromString(tt.wantRate)) {
+				t.Errorf("rate = %s, want %s", quote.Rate, tt.wantRate)
+			}
+			if !quote.TargetAmount.Equal(decimal.RequireFromString(tt.wantTarget)) {
+				t.Errorf("target amount = %s, want %s", quote.TargetAmount, tt.wantTarget)
+			}
+			if _, ok := cache.values[fxQuoteKey(quote.QuoteID)]; !ok {
+				t.Error("the quote was not locked")
+			}
+		})
+	}
+}
+
+func TestConsumeQuote(t *testing.T) {
+	cache := newFakeCache()
+	userID := uuid.New()
+
+	quote, err := newTestFxService(t, cache).Quote(userID, "USD", "NGN", decimal.NewFromInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another user cannot take the quote, nor use it up
+	if _, err := consumeQuote(cache, uuid.New(), quote.QuoteID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("consume by another user = %v, want %v", err, ErrQuoteNotFound)
+	}
+
+	consumed, err := consumeQuote(cache, userID, quote.QuoteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumed.QuoteID != quote.QuoteID || !consumed.TargetAmount.Equal(quote.TargetAmount) {
+		t.Errorf("consumed = %+v, want %+v", consumed, quote)
+	}
+
+	if _, err := consumeQuote(cache, userID, quote.QuoteID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("second consume = %v, want %v", err, ErrQuoteNotFound)
+	}
+}
+
+func TestRestoreQuote(t *testing.T) {
+	cache := newFakeCache()
+	userID := uuid.New()
+
+	quote, err := newTestFxService(t, cache).Quote(userID, "USD", "NGN", decimal.NewFromInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumed, err := consumeQuote(cache, userID, quote.QuoteID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a conversion that failed gives the quote back with its cause
+	cause := errors.New("transfer failed")
+	if err := restoreQuote(cache, userID, consumed, cause); err != cause {
+		t.Errorf("restoreQuote = %v, want %v", err, cause)
+	}
+
+	again, err := consumeQuote(cache, userID, quote.QuoteID)
+	if err != nil {
+		t.Fatalf("consume after restore: %v", err)
+	}
+	if !again.TargetAmount.Equal(quote.TargetAmount) || !again.Rate.Equal(quote.Rate) {
+		t.Errorf("restored quote = %+v, want %+v", again, quote)
+	}
+
+	// an expired quote stays gone
+	again.ExpiresAt = time.Now().Add(-time.Second)
+	restoreQuote(cache, userID, again, cause)
+	if _, err := consumeQuote(cache, userID, quote.QuoteID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("consume after restoring an expired quote = %v, want %v", err, ErrQuoteNotFound)
+	}
+}
+
+func TestConversionPostings(t *testing.T) {
+	quote, err := newTestFxService(t, newFakeCache()).Quote(uuid.New(), "USD", "NGN", decimal.NewFromInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := func(currency string) *model.Account {
+		return &model.Account{Currency: currency}
+	}
+	from, to := account("USD"), account("NGN")
+	fxFrom, fxTo, fxPnl := account("USD"), account("NGN"), account("NGN")
+
+	postings, fxGain := conversionPostings(quote, from, to, fxFrom, fxTo, fxPnl, "Conversion", "Conversion")
+
+	if err := validatePostings(postings); err != nil {
+		t.Fatalf("postings do not balance: %v", err)
+	}
+
+	// the 1% spread on 15000 NGN at the mid rate
+	if !fxGain.Equal(decimal.NewFromInt(150)) {
+		t.Errorf("fx gain = %s, want 150", fxGain)
+	}
+
+	want := map[*model.Account]decimal.Decimal{
+		from:   decimal.NewFromInt(-10),
+		fxFrom: decimal.NewFromInt(10),
+		fxTo:   decimal.NewFromInt(-15000),
+		to:     decimal.NewFromInt(14850),
+		fxPnl:  decimal.NewFromInt(150),
+	}
+	for _, p := range postings {
+		if !p.signedAmount().Equal(want[p.account]) {
+			t.Errorf("%s posting of %s, want %s", p.account.Currency, p.signedAmount(), want[p.account])
+		}
+	}
+	if len(postings) != len(want) {
+		t.Errorf("%d postings, want %d", len(postings), len(want))
+	}
+}
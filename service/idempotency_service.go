@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+var (
+	// IdempotencyRetention is how long a completed response can be replayed.
+	IdempotencyRetention = 24 * time.Hour
+	// IdempotencyLockTimeout bounds how long an in-flight request holds its key,
+	// so a crashed request does not block retries for the whole retention window.
+	// A retry that runs again still finds any transaction the crashed request posted by its key.
+	IdempotencyLockTimeout = time.Minute
+	// IdempotencyWaitTimeout is how long a duplicate waits for the original to finish.
+	IdempotencyWaitTimeout = 5 * time.Second
+
+	idempotencyPollInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrIdempotencyKeyInUse    = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key has already been used with a different request")
+)
+
+type IdempotencyServiceInterface interface {
+	Begin(userID uuid.UUID, key, requestHash string) (*model.IdempotencyKey, bool, error)
+	Complete(record *model.IdempotencyKey, status int, body []byte, reference string) error
+	Release(record *model.IdempotencyKey) error
+	SweepExpiredKeys() (int64, error)
+}
+
+type idempotencyService struct {
+	idempotencyKeyRepo core_repository.IdempotencyKeyRepository
+}
+
+func NewIdempotencyService(idempotencyKeyRepo core_repository.IdempotencyKeyRepository) IdempotencyServiceInterface {
+	return &idempotencyService{
+		idempotencyKeyRepo: idempotencyKeyRepo,
+	}
+}
+
+// Begin claims the key for the calling request. It returns the stored record and
+// true when a completed response should be replayed, or a fresh processing record
+// and false when the caller owns the key and must run the operation.
+func (s *idempotencyService) Begin(userID uuid.UUID, key, requestHash string) (*model.IdempotencyKey, bool, error) {
+	deadline := time.Now().Add(IdempotencyWaitTimeout)
+
+	for {
+		record := &model.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: requestHash,
+			Status:      model.IdempotencyProcessing,
+			ExpiresAt:   time.Now().Add(IdempotencyLockTimeout),
+		}
+
+		created, err := s.idempotencyKeyRepo.CreateIdempotencyKey(record)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if created {
+			return record, false, nil
+		}
+
+		existing, err := s.idempotencyKeyRepo.GetIdempotencyKey(userID, key)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// released between our insert and read, try to claim it again
+				continue
+			}
+			return nil, false, err
+		}
+
+		if existing.ExpiresAt.Before(time.Now()) {
+			if err := s.idempotencyKeyRepo.DeleteIdempotencyKey(existing); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		if existing.RequestHash != requestHash {
+			return nil, false, ErrIdempotencyKeyMismatch
+		}
+
+		if existing.Status == model.IdempotencyCompleted {
+			return existing, true, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, false, ErrIdempotencyKeyInUse
+		}
+
+		time.Sleep(idempotencyPollInterval)
+	}
+}
+
+// Complete stores the outcome of the request so that retries can replay it.
+func (s *idempotencyService) Complete(record *model.IdempotencyKey, status int, body []byte, reference string) error {
+	record.Status = model.IdempotencyCompleted
+	record.ResponseStatus = status
+	record.ResponseBody = body
+	record.TransactionReference = reference
+	record.ExpiresAt = time.Now().Add(IdempotencyRetention)
+
+	return s.idempotencyKeyRepo.UpdateIdempotencyKey(record)
+}
+
+// Release drops a processing key so the client can retry after a server error.
+func (s *idempotencyService) Release(record *model.IdempotencyKey) error {
+	return s.idempotencyKeyRepo.DeleteIdempotencyKey(record)
+}
+
+func (s *idempotencyService) SweepExpiredKeys() (int64, error) {
+	return s.idempotencyKeyRepo.DeleteExpiredIdempotencyKeys(time.Now())
+}
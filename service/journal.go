@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrUnbalancedJournal   = errors.New("journal postings do not balance")
+)
+
+// posting is one leg of a journal transaction. Accounts must be the locked instances
+// returned by lockAccounts so that several postings to the same account accumulate.
+type posting struct {
+	account     *model.Account
+	entryType   model.TransactionType
+	amount      decimal.Decimal
+	description string
+}
+
+// signedAmount is the effect of the posting on the account balance: credits add, debits subtract.
+func (p posting) signedAmount() decimal.Decimal {
+	if p.entryType == model.Debit {
+		return p.amount.Neg()
+	}
+	return p.amount
+}
+
+// validatePostings checks that a journal has at least two positive postings and
+// that, per currency, its credits and debits sum to zero.
+func validatePostings(postings []posting) error {
+	if len(postings) < 2 {
+		return fmt.Errorf("%w: a journal needs at least two postings", ErrUnbalancedJournal)
+	}
+
+	totals := map[string]decimal.Decimal{}
+	for _, p := range postings {
+		if !p.amount.IsPositive() {
+			return fmt.Errorf("%w: posting amounts must be positive", ErrUnbalancedJournal)
+		}
+		if p.entryType != model.Credit && p.entryType != model.Debit {
+			return fmt.Errorf("%w: unknown entry type %q", ErrUnbalancedJournal, p.entryType)
+		}
+
+		totals[p.account.Currency] = totals[p.account.Currency].Add(p.signedAmount())
+	}
+
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: %s postings are off by %s", ErrUnbalancedJournal, currency, total)
+		}
+	}
+
+	return nil
+}
+
+// idempotentTransaction returns the transaction the user already posted for the Idempotency-Key, or
+// nil when there is none. It is looked up inside the wallet transaction that would post it again, so
+// a retry after a lost response or a crash returns the original instead of moving money twice.
+// A key used for a different operation is a mismatch. An empty key never matches.
+func idempotentTransaction(transactionRepo core_repository.TransactionRepository, userID uuid.UUID, key string, operation string) (*model.Transaction, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	existing, err := transactionRepo.GetTransactionByIdempotencyKey(userID, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.Operation != operation {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+
+	return existing, nil
+}
+
+// optionalKey stores an empty Idempotency-Key as NULL, so transactions without one do not collide.
+func optionalKey(key string) *string {
+	if key == "" {
+		return nil
+	}
+	return &key
+}
+
+// balanceRounding makes postings that were scaled and rounded one by one balance again. The rounding
+// remainder of each currency is moved onto that currency's largest posting, and postings rounded
+// down to zero are dropped.
+func balanceRounding(postings []posting) []posting {
+	totals := map[string]decimal.Decimal{}
+	largest := map[string]int{}
+	for i, p := range postings {
+		currency := p.account.Currency
+		totals[currency] = totals[currency].Add(p.signedAmount())
+
+		if j, ok := largest[currency]; !ok || p.amount.GreaterThan(postings[j].amount) {
+			largest[currency] = i
+		}
+	}
+
+	for currency, total := range totals {
+		if total.IsZero() {
+			continue
+		}
+
+		p := &postings[largest[currency]]
+		if p.entryType == model.Debit {
+			p.amount = p.amount.Add(total)
+		} else {
+			p.amount = p.amount.Sub(total)
+		}
+	}
+
+	balanced := make([]posting, 0, len(postings))
+	for _, p := range postings {
+		if !p.amount.IsZero() {
+			balanced = append(balanced, p)
+		}
+	}
+
+	return balanced
+}
+
+// postJournal records transaction as one journal entry with the given postings and applies
+// each posting to its account balance. It must run inside TxHelper so that an unbalanced
+// journal rolls back together with its transaction record.
+func postJournal(repos walletRepositories, transaction *model.Transaction, postings []posting) error {
+	if err := repos.transactionRepo.CreateTransaction(transaction); err != nil {
+		return err
+	}
+
+	return postEntries(repos, transaction, postings)
+}
+
+// postEntries writes the postings of an already stored transaction, such as a captured hold.
+// Debits need an account that may send money and credits one that may receive it.
+func postEntries(repos walletRepositories, transaction *model.Transaction, postings []posting) error {
+	for _, p := range postings {
+		check := checkCanCredit
+		if p.entryType == model.Debit {
+			check = checkCanDebit
+		}
+
+		if err := check(p.account); err != nil {
+			return err
+		}
+	}
+
+	return applyPostings(repos, transaction, postings)
+}
+
+// applyPostings writes the postings without looking at account states, only for operations that
+// settle frozen accounts such as a closure sweep. A user wallet is never allowed to spend more
+// than its available balance.
+func applyPostings(repos walletRepositories, transaction *model.Transaction, postings []posting) error {
+	if err := validatePostings(postings); err != nil {
+		return err
+	}
+
+	for _, p := range postings {
+		if p.account.AccountType == model.AccountTypeWallet && p.account.AvailableBalance().Add(p.signedAmount()).IsNegative() {
+			return ErrInsufficientBalance
+		}
+
+		if err := repos.accountRepo.UpdateAccountBalance(p.account, p.signedAmount()); err != nil {
+			return err
+		}
+
+		ledgerEntry := &model.LedgerEntry{
+			UserID:        p.account.UserID,
+			AccountID:     p.account.ID,
+			TransactionID: transaction.ID,
+			EntryType:     string(p.entryType),
+			Amount:        p.amount,
+			Description:   p.description,
+		}
+
+		if err := repos.ledgerEntryRepo.CreateLedgerEntry(ledgerEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transactionMetadata decodes the JSON metadata of a transaction, an empty map is returned when none is set.
+func transactionMetadata(transaction *model.Transaction) (map[string]interface{}, error) {
+	metadata := map[string]interface{}{}
+	if len(transaction.Metadata) == 0 {
+		return metadata, nil
+	}
+
+	if err := json.Unmarshal(transaction.Metadata, &metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+func setTransactionMetadata(transaction *model.Transaction, metadata map[string]interface{}) error {
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	transaction.Metadata = datatypes.JSON(encoded)
+	return nil
+}
+
+// metadataDecimal reads a decimal stored as a string in transaction metadata.
+func metadataDecimal(metadata map[string]interface{}, key string) (decimal.Decimal, error) {
+	value, ok := metadata[key].(string)
+	if !ok || value == "" {
+		return decimal.Zero, nil
+	}
+	return decimal.NewFromString(value)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// Machine readable codes returned with a LimitError.
+const (
+	LimitCodePerTransaction = "LIMIT_PER_TRANSACTION_EXCEEDED"
+	LimitCodeDaily          = "LIMIT_DAILY_EXCEEDED"
+	LimitCodeMonthly        = "LIMIT_MONTHLY_EXCEEDED"
+	LimitCodeVelocity       = "LIMIT_VELOCITY_EXCEEDED"
+)
+
+// LimitError is returned when an operation would exceed one of the user's limits.
+type LimitError struct {
+	Code    string
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+type LimitServiceInterface interface {
+	CheckLimits(userID uuid.UUID, operation string, currency string, amount decimal.Decimal) error
+	ListLimitRules() ([]model.LimitRule, error)
+	SaveLimitRule(rule *model.LimitRule) error
+	SetKycLevel(userID uuid.UUID, kycLevel int) error
+}
+
+type limitService struct {
+	limitRuleRepo   core_repository.LimitRuleRepository
+	transactionRepo core_repository.TransactionRepository
+	userRepo        user_repository.UserRepository
+}
+
+func NewLimitService(
+	limitRuleRepo core_repository.LimitRuleRepository,
+	transactionRepo core_repository.TransactionRepository,
+	userRepo user_repository.UserRepository,
+) LimitServiceInterface {
+	return &limitService{
+		limitRuleRepo:   limitRuleRepo,
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+	}
+}
+
+// CheckLimits evaluates the user's rule for the operation against their completed transactions.
+// The wallet service calls it once the wallet is locked, so that concurrent operations of the
+// same user are counted one after the other. Daily and monthly limits follow UTC calendar periods.
+func (s *limitService) CheckLimits(userID uuid.UUID, operation string, currency string, amount decimal.Decimal) error {
+	rule, err := s.limitRule(userID, operation, currency)
+	if err != nil || rule == nil {
+		return err
+	}
+
+	if rule.PerTransaction.IsPositive() && amount.GreaterThan(rule.PerTransaction) {
+		return &LimitError{
+			Code:    LimitCodePerTransaction,
+			Message: fmt.Sprintf("amount exceeds the %s limit of %s %s per transaction", operation, rule.PerTransaction, currency),
+		}
+	}
+
+	now := time.Now().UTC()
+
+	if rule.Daily.IsPositive() {
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if err := s.checkPeriod(userID, operation, currency, amount, startOfDay, rule.Daily, LimitCodeDaily, "daily"); err != nil {
+			return err
+		}
+	}
+
+	if rule.Monthly.IsPositive() {
+		startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if err := s.checkPeriod(userID, operation, currency, amount, startOfMonth, rule.Monthly, LimitCodeMonthly, "monthly"); err != nil {
+			return err
+		}
+	}
+
+	if rule.VelocityCount > 0 && rule.VelocityWindowMinutes > 0 {
+		window := time.Duration(rule.VelocityWindowMinutes) * time.Minute
+
+		_, count, err := s.transactionRepo.GetTransactionUsage(userID, countedOperations(operation), currency, now.Add(-window))
+		if err != nil {
+			return err
+		}
+
+		if count >= int64(rule.VelocityCount) {
+			return &LimitError{
+				Code:    LimitCodeVelocity,
+				Message: fmt.Sprintf("no more than %d %s operations are allowed every %d minutes", rule.VelocityCount, operation, rule.VelocityWindowMinutes),
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *limitService) checkPeriod(userID uuid.UUID, operation string, currency string, amount decimal.Decimal, since time.Time, limit decimal.Decimal, code string, period string) error {
+	used, _, err := s.transactionRepo.GetTransactionUsage(userID, countedOperations(operation), currency, since)
+	if err != nil {
+		return err
+	}
+
+	if used.Add(amount).GreaterThan(limit) {
+		return &LimitError{
+			Code:    code,
+			Message: fmt.Sprintf("amount exceeds the %s %s limit of %s %s, %s %s remaining", period, operation, limit, currency, decimal.Max(limit.Sub(used), decimal.Zero), currency),
+		}
+	}
+
+	return nil
+}
+
+// limitRule returns the user's own rule or the rule of their KYC level, nil when neither exists.
+func (s *limitService) limitRule(userID uuid.UUID, operation string, currency string) (*model.LimitRule, error) {
+	rule, err := s.limitRuleRepo.GetUserLimitRule(userID, operation, currency)
+	if err == nil {
+		return rule, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err = s.limitRuleRepo.GetKycLimitRule(user.KycLevel, operation, currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *limitService) ListLimitRules() ([]model.LimitRule, error) {
+	return s.limitRuleRepo.ListLimitRules()
+}
+
+// SaveLimitRule creates the rule, or replaces the existing rule for the same user or KYC level,
+// operation and currency. The unique index on limit_rules keeps concurrent saves to one rule.
+func (s *limitService) SaveLimitRule(rule *model.LimitRule) error {
+	return s.limitRuleRepo.SaveLimitRule(rule)
+}
+
+func (s *limitService) SetKycLevel(userID uuid.UUID, kycLevel int) error {
+	return s.userRepo.UpdateKycLevel(userID, kycLevel)
+}
+
+// countedOperations lists the operations whose transactions count towards the limits of operation.
+// Holds count as withdrawals, open or captured.
+func countedOperations(operation string) []string {
+	if operation == model.OperationWithdrawal {
+		return []string{model.OperationWithdrawal, model.OperationHold}
+	}
+	return []string{operation}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+// Failed logins are counted per email and per IP address within loginFailureWindow. From
+// loginBackoffAfter failures on an email every further attempt has to wait twice as long as the
+// previous one, at loginLockAfter failures the email is locked for loginLockDuration, and an
+// address with ipBlockAfter failures is blocked for ipBlockDuration.
+const (
+	loginFailureWindow = time.Hour
+	loginBackoffAfter  = 3
+	loginBackoffMax    = 5 * time.Minute
+	loginLockAfter     = 10
+	loginLockDuration  = 30 * time.Minute
+	ipBlockAfter       = 50
+	ipBlockDuration    = time.Hour
+)
+
+// Machine readable codes returned when a login is refused.
+const (
+	CodeInvalidCredentials = "INVALID_CREDENTIALS"
+	CodeLoginThrottled     = "LOGIN_THROTTLED"
+	CodeAccountLocked      = "ACCOUNT_LOCKED"
+	CodeIPBlocked          = "IP_BLOCKED"
+)
+
+// LoginError is returned when a login is refused. RetryAfter is set when trying again later can succeed.
+type LoginError struct {
+	Code       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *LoginError) Error() string {
+	return e.Message
+}
+
+var errInvalidCredentials = &LoginError{Code: CodeInvalidCredentials, Message: "invalid credentials"}
+
+// loginFailure is what a failed attempt led to.
+type loginFailure struct {
+	emailFailures int64
+	ipFailures    int64
+	throttled     bool
+	locked        bool
+	ipBlocked     bool
+}
+
+// loginGuard keeps the failed login counters in Redis.
+type loginGuard struct {
+	cache database.RedisClientInterface
+}
+
+func newLoginGuard(cache database.RedisClientInterface) *loginGuard {
+	return &loginGuard{cache: cache}
+}
+
+// check refuses an attempt from a blocked address, for a locked email or before the email's backoff has passed.
+func (g *loginGuard) check(email string, ip string) error {
+	if wait, err := g.remaining(ipBlockKey(ip)); err != nil || wait > 0 {
+		if err != nil {
+			return err
+		}
+		return &LoginError{Code: CodeIPBlocked, Message: "too many failed logins from this address, try again later", RetryAfter: wait}
+	}
+
+	if wait, err := g.remaining(loginLockKey(email)); err != nil || wait > 0 {
+		if err != nil {
+			return err
+		}
+		return &LoginError{Code: CodeAccountLocked, Message: "the account is temporarily locked, check your email to unlock it", RetryAfter: wait}
+	}
+
+	if wait, err := g.remaining(loginBackoffKey(email)); err != nil || wait > 0 {
+		if err != nil {
+			return err
+		}
+		return &LoginError{
+			Code:       CodeLoginThrottled,
+			Message:    fmt.Sprintf("too many failed logins, try again in %d seconds", int(math.Ceil(wait.Seconds()))),
+			RetryAfter: wait,
+		}
+	}
+
+	return nil
+}
+
+// fail counts a failed attempt and starts the backoff, lock or block it earns.
+func (g *loginGuard) fail(email string, ip string) (loginFailure, error) {
+	var failure loginFailure
+	var err error
+
+	if failure.emailFailures, err = g.cache.Increment(loginFailuresKey(email), loginFailureWindow); err != nil {
+		return failure, err
+	}
+	if failure.ipFailures, err = g.cache.Increment(ipFailuresKey(ip), loginFailureWindow); err != nil {
+		return failure, err
+	}
+
+	switch {
+	case failure.emailFailures >= loginLockAfter:
+		failure.locked = true
+		if err := g.until(loginLockKey(email), loginLockDuration); err != nil {
+			return failure, err
+		}
+		if _, err := g.cache.Delete(loginFailuresKey(email), loginBackoffKey(email)); err != nil {
+			return failure, err
+		}
+	case failure.emailFailures >= loginBackoffAfter:
+		failure.throttled = true
+		if err := g.until(loginBackoffKey(email), backoffDelay(failure.emailFailures)); err != nil {
+			return failure, err
+		}
+	}
+
+	if failure.ipFailures >= ipBlockAfter {
+		failure.ipBlocked = true
+		if err := g.until(ipBlockKey(ip), ipBlockDuration); err != nil {
+			return failure, err
+		}
+		if _, err := g.cache.Delete(ipFailuresKey(ip)); err != nil {
+			return failure, err
+		}
+	}
+
+	return failure, nil
+}
+
+// succeed forgets the email's failures, the address keeps its count.
+func (g *loginGuard) succeed(email string) error {
+	_, err := g.cache.Delete(loginFailuresKey(email), loginBackoffKey(email))
+	return err
+}
+
+// unlock lifts the lock and backoff of an email.
+func (g *loginGuard) unlock(email string) error {
+	_, err := g.cache.Delete(loginFailuresKey(email), loginBackoffKey(email), loginLockKey(email))
+	return err
+}
+
+// until stores when a backoff, lock or block ends, so the remaining time can be reported.
+func (g *loginGuard) until(key string, duration time.Duration) error {
+	return g.cache.SetValue(key, time.Now().Add(duration).UnixMilli(), duration)
+}
+
+func (g *loginGuard) remaining(key string) (time.Duration, error) {
+	value, err := g.cache.GetValue(key)
+	if errors.Is(err, database.ErrCacheMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	endsAt, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+
+	return time.Until(time.UnixMilli(endsAt)), nil
+}
+
+// backoffDelay doubles from one second at the first throttled failure, up to loginBackoffMax.
+func backoffDelay(failures int64) time.Duration {
+	exponent := failures - loginBackoffAfter
+	if exponent > 16 {
+		return loginBackoffMax
+	}
+
+	delay := time.Second << exponent
+	if delay > loginBackoffMax {
+		return loginBackoffMax
+	}
+	return delay
+}
+
+func loginFailuresKey(email string) string {
+	return "login_failures:email:" + strings.ToLower(email)
+}
+
+func loginBackoffKey(email string) string {
+	return "login_backoff:email:" + strings.ToLower(email)
+}
+
+func loginLockKey(email string) string {
+	return "login_locked:email:" + strings.ToLower(email)
+}
+
+func ipFailuresKey(ip string) string {
+	return "login_failures:ip:" + ip
+}
+
+func ipBlockKey(ip string) string {
+	return "login_blocked:ip:" + ip
+}
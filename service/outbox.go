@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+var (
+	// OutboxRetryDelay is the wait after the first failed publish of an event, it doubles with every further failure.
+	OutboxRetryDelay = 5 * time.Second
+	// OutboxMaxRetryDelay caps the wait between two publishes, events are retried until the broker takes them.
+	OutboxMaxRetryDelay = 5 * time.Minute
+	// OutboxRetention is how long published events are kept before they are purged.
+	OutboxRetention = 7 * 24 * time.Hour
+	// OutboxPublishTimeout is how long the broker has to confirm an event.
+	OutboxPublishTimeout = 10 * time.Second
+)
+
+const (
+	outboxBatchSize = 100
+	// outboxClaimDuration is how long an instance owns an event while publishing it.
+	outboxClaimDuration = time.Minute
+)
+
+// OutboxRelayInterface publishes the events wallet operations write to the outbox.
+type OutboxRelayInterface interface {
+	RelayEvents(ctx context.Context) (int, error)
+	PurgeSentEvents() (int64, error)
+}
+
+type outboxRelay struct {
+	outboxRepo core_repository.OutboxRepository
+	events     config.EventInterface
+	exchange   string
+}
+
+func NewOutboxRelay(outboxRepo core_repository.OutboxRepository, events config.EventInterface, exchange string) OutboxRelayInterface {
+	return &outboxRelay{outboxRepo: outboxRepo, events: events, exchange: exchange}
+}
+
+// RelayEvents publishes every due event this instance manages to claim and marks it sent once
+// the broker confirms it. The run stops at the first failure, as the broker is likely down and the
+// remaining events would fail too; the failed event is retried with backoff. An event the broker
+// returns as unroutable is marked OutboxUnroutable instead and the run goes on, as retrying it
+// would only hold up the rest. Delivery is at least once: an event confirmed by the broker but not
+// marked sent is published again.
+func (r *outboxRelay) RelayEvents(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	events, err := r.outboxRepo.GetDueEvents(now, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range events {
+		event := &events[i]
+
+		claimed, err := r.outboxRepo.ClaimEvent(event.ID, now, time.Now().Add(outboxClaimDuration))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		publishErr := r.publish(ctx, event)
+		unroutable := errors.Is(publishErr, config.ErrEventUnroutable)
+
+		event.Attempts++
+		event.ClaimedUntil = nil
+		switch {
+		case publishErr == nil:
+			sentAt := time.Now()
+			event.Status = model.OutboxSent
+			event.SentAt = &sentAt
+			event.LastError = ""
+		case unroutable:
+			event.Status = model.OutboxUnroutable
+			event.LastError = truncate(publishErr.Error(), 255)
+		default:
+			event.NextAttemptAt = time.Now().Add(retryDelay(OutboxRetryDelay, OutboxMaxRetryDelay, event.Attempts))
+			event.LastError = truncate(publishErr.Error(), 255)
+		}
+
+		if err := r.outboxRepo.SaveEventResult(event); err != nil {
+			return sent, err
+		}
+
+		if unroutable {
+			continue
+		}
+		if publishErr != nil {
+			return sent, fmt.Errorf("publishing event %s: %w", event.EventID, publishErr)
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (r *outboxRelay) publish(ctx context.Context, event *model.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, OutboxPublishTimeout)
+	defer cancel()
+
+	return r.events.Publish(ctx, r.exchange, event.RoutingKey, []byte(event.Payload))
+}
+
+// PurgeSentEvents deletes the events published more than OutboxRetention ago.
+func (r *outboxRelay) PurgeSentEvents() (int64, error) {
+	return r.outboxRepo.DeleteSentEvents(time.Now().Add(-OutboxRetention))
+}
+
+// recordEvent writes an event to the outbox in the current schema version of its type. Called
+// with the repository of a running DB transaction, the event is committed or rolled back with
+// the operation it reports.
+func recordEvent(outboxRepo core_repository.OutboxRepository, eventType string, data interface{}) error {
+	version, ok := model.EventVersions[eventType]
+	if !ok {
+		return fmt.Errorf("unknown event type %q", eventType)
+	}
+
+	now := time.Now()
+	eventID := uuid.New()
+
+	payload, err := json.Marshal(dto.EventEnvelope{
+		ID:         eventID.String(),
+		Type:       eventType,
+		Version:    version,
+		OccurredAt: now.UTC(),
+		Data:       data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return outboxRepo.CreateEvent(&model.OutboxEvent{
+		EventID:       eventID,
+		EventType:     eventType,
+		Version:       version,
+		RoutingKey:    fmt.Sprintf("%s.v%d", eventType, version),
+		Payload:       string(payload),
+		Status:        model.OutboxPending,
+		NextAttemptAt: now,
+	})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+// fakeOutboxRepository keeps the outbox in memory.
+type fakeOutboxRepository struct {
+	core_repository.OutboxRepository
+
+	events []model.OutboxEvent
+}
+
+func (r *fakeOutboxRepository) CreateEvent(event *model.OutboxEvent) error {
+	event.ID = uuid.New()
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *fakeOutboxRepository) GetDueEvents(now time.Time, limit int) ([]model.OutboxEvent, error) {
+	var due []model.OutboxEvent
+	for _, event := range r.events {
+		if event.Status == model.OutboxPending && !event.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, event)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeOutboxRepository) ClaimEvent(id uuid.UUID, now time.Time, until time.Time) (bool, error) {
+	event := r.find(id)
+	if event.ClaimedUntil != nil && event.ClaimedUntil.After(now) {
+		return false, nil
+	}
+	event.ClaimedUntil = &until
+	return true, nil
+}
+
+func (r *fakeOutboxRepository) SaveEventResult(event *model.OutboxEvent) error {
+	*r.find(event.ID) = *event
+	return nil
+}
+
+func (r *fakeOutboxRepository) find(id uuid.UUID) *model.OutboxEvent {
+	for i := range r.events {
+		if r.events[i].ID == id {
+			return &r.events[i]
+		}
+	}
+	return nil
+}
+
+// testEventTopology binds a queue to the funded events only.
+func testEventTopology() config.EventTopology {
+	return config.EventTopology{
+		Exchanges: []config.ExchangeConfig{{Name: config.DefaultEventsExchange, Kind: "topic"}},
+		Queues:    []config.QueueConfig{{Name: "funded"}},
+		Bindings:  []config.BindingConfig{{Queue: "funded", Exchange: config.DefaultEventsExchange, Key: "wallet.funded.*"}},
+	}
+}
+
+func TestOutboxRelayPublishesEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := &fakeOutboxRepository{}
+	events := config.NewMemoryEvent(testEventTopology())
+	relay := NewOutboxRelay(repo, events, config.DefaultEventsExchange)
+
+	funded := dto.WalletFundedV1{Reference: "TXN-1", AccountNumber: "0123456789", Amount: decimal.NewFromInt(100), Currency: "NGN"}
+	if err := recordEvent(repo, model.EventWalletFunded, funded); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := events.Consume(ctx, "funded")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := relay.RelayEvents(ctx)
+	if err != nil || sent != 1 {
+		t.Fatalf("RelayEvents = %d, %v, want 1 sent", sent, err)
+	}
+
+	event := repo.events[0]
+	if event.Status != model.OutboxSent || event.SentAt == nil || event.Attempts != 1 || event.ClaimedUntil != nil {
+		t.Errorf("event after relay = %+v", event)
+	}
+
+	select {
+	case msg := <-messages:
+		if err := msg.Ack(); err != nil {
+			t.Fatal(err)
+		}
+		if msg.RoutingKey != "wallet.funded.v1" {
+			t.Errorf("routing key = %s, want wallet.funded.v1", msg.RoutingKey)
+		}
+
+		var envelope struct {
+			dto.EventEnvelope
+			Data dto.WalletFundedV1 `json:"data"`
+		}
+		if err := json.Unmarshal(msg.Body, &envelope); err != nil {
+			t.Fatal(err)
+		}
+		if envelope.ID != event.EventID.String() || envelope.Type != model.EventWalletFunded || envelope.Version != 1 {
+			t.Errorf("envelope = %+v", envelope.EventEnvelope)
+		}
+		if envelope.Data.Reference != funded.Reference || !envelope.Data.Amount.Equal(funded.Amount) {
+			t.Errorf("data = %+v, want %+v", envelope.Data, funded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the event was not delivered")
+	}
+
+	// a sent event is not published again
+	if sent, err := relay.RelayEvents(ctx); err != nil || sent != 0 {
+		t.Errorf("second RelayEvents = %d, %v, want nothing sent", sent, err)
+	}
+}
+
+func TestOutboxRelaySetsAsideUnroutableEvents(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &fakeOutboxRepository{}
+	events := config.NewMemoryEvent(testEventTopology())
+	relay := NewOutboxRelay(repo, events, config.DefaultEventsExchange)
+
+	// no queue is bound for the transfer, the funding behind it must still go out
+	if err := recordEvent(repo, model.EventTransferCompleted, dto.TransferCompletedV1{Reference: "TXN-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordEvent(repo, model.EventWalletFunded, dto.WalletFundedV1{Reference: "TXN-3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := relay.RelayEvents(ctx)
+	if err != nil || sent != 1 {
+		t.Fatalf("RelayEvents = %d, %v, want 1 sent", sent, err)
+	}
+
+	unroutable := repo.events[0]
+	if unroutable.Status != model.OutboxUnroutable || unroutable.Attempts != 1 || unroutable.ClaimedUntil != nil {
+		t.Errorf("unroutable event after relay = %+v", unroutable)
+	}
+	if !strings.Contains(unroutable.LastError, config.ErrEventUnroutable.Error()) {
+		t.Errorf("last error = %q, want it to say %q", unroutable.LastError, config.ErrEventUnroutable)
+	}
+	if repo.events[1].Status != model.OutboxSent || events.Pending("funded") != 1 {
+		t.Errorf("event behind it = %+v, %d queued", repo.events[1], events.Pending("funded"))
+	}
+
+	// it is not retried on its own
+	if sent, err := relay.RelayEvents(ctx); err != nil || sent != 0 {
+		t.Errorf("second RelayEvents = %d, %v, want nothing sent", sent, err)
+	}
+
+	// once a queue is bound and the event is set back to pending it goes through
+	if err := events.DeclareQueue(ctx, config.QueueConfig{Name: "transfers"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := events.BindQueue(ctx, "transfers", config.DefaultEventsExchange, "transfer.#"); err != nil {
+		t.Fatal(err)
+	}
+	repo.events[0].Status = model.OutboxPending
+
+	if sent, err := relay.RelayEvents(ctx); err != nil || sent != 1 {
+		t.Fatalf("after binding = %d, %v, want 1 sent", sent, err)
+	}
+	if repo.events[0].Status != model.OutboxSent || events.Pending("transfers") != 1 {
+		t.Errorf("event after binding = %+v, %d queued", repo.events[0], events.Pending("transfers"))
+	}
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// A PIN is locked for pinLockDuration after pinMaxAttempts wrong entries within pinAttemptWindow.
+// Resetting the PIN is locked the same way after pinMaxAttempts wrong passwords.
+const (
+	pinMaxAttempts   = 5
+	pinAttemptWindow = 15 * time.Minute
+	pinLockDuration  = 30 * time.Minute
+)
+
+// Machine readable codes returned when a PIN does not authorize an operation.
+const (
+	CodePinNotSet      = "PIN_NOT_SET"
+	CodePinInvalid     = "PIN_INVALID"
+	CodePinLocked      = "PIN_LOCKED"
+	CodePinResetLocked = "PIN_RESET_LOCKED"
+)
+
+var (
+	ErrPinAlreadySet   = errors.New("transaction PIN is already set, change it instead")
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+var errPinLocked = &PinError{Code: CodePinLocked, Message: "too many wrong PIN attempts, try again later or reset your PIN"}
+
+var errPinResetLocked = &PinError{Code: CodePinResetLocked, Message: "too many wrong passwords, try resetting your PIN later"}
+
+// PinError is returned when a transaction PIN is missing, wrong or locked.
+type PinError struct {
+	Code    string
+	Message string
+}
+
+func (e *PinError) Error() string {
+	return e.Message
+}
+
+type PinServiceInterface interface {
+	SetPin(userID uuid.UUID, pin string, client dto.ClientInfo) error
+	ChangePin(userID uuid.UUID, currentPin string, newPin string, client dto.ClientInfo) error
+	ResetPin(userID uuid.UUID, password string, code string, newPin string, client dto.ClientInfo) error
+	VerifyPin(userID uuid.UUID, pin string, client dto.ClientInfo) error
+}
+
+type pinService struct {
+	userRepo         user_repository.UserRepository
+	twoFactorService TwoFactorServiceInterface
+	auditService     AuditServiceInterface
+	cache            database.RedisClientInterface
+	hashing          helper.HashingInterface
+}
+
+func NewPinService(
+	userRepo user_repository.UserRepository,
+	twoFactorService TwoFactorServiceInterface,
+	auditService AuditServiceInterface,
+	cache database.RedisClientInterface,
+) PinServiceInterface {
+	return &pinService{
+		userRepo:         userRepo,
+		twoFactorService: twoFactorService,
+		auditService:     auditService,
+		cache:            cache,
+		hashing:          helper.NewHashing(),
+	}
+}
+
+func (s *pinService) SetPin(userID uuid.UUID, pin string, client dto.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.HasPin() {
+		return ErrPinAlreadySet
+	}
+
+	if err := s.savePin(user.ID, pin); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&user.ID, model.AuditActionPinSet, client, nil)
+	return nil
+}
+
+// ChangePin replaces the PIN after checking the current one, a wrong current PIN counts towards the lockout.
+func (s *pinService) ChangePin(userID uuid.UUID, currentPin string, newPin string, client dto.ClientInfo) error {
+	if err := s.VerifyPin(userID, currentPin, client); err != nil {
+		return err
+	}
+
+	if err := s.savePin(userID, newPin); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionPinChanged, client, nil)
+	return nil
+}
+
+// ResetPin sets a new PIN for a user who forgot theirs and lifts any lockout. It needs the login
+// password, and a two-factor code when two-factor authentication is enabled. Wrong passwords are
+// audited and lock the reset after too many of them, so it cannot be used to guess the password.
+func (s *pinService) ResetPin(userID uuid.UUID, password string, code string, newPin string, client dto.ClientInfo) error {
+	locked, err := s.isResetLocked(userID)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return errPinResetLocked
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	match, err := s.hashing.ComparePassword(password, user.Password)
+	if err != nil || !match {
+		return s.recordResetFailure(user.ID, client)
+	}
+
+	if user.TwoFactorEnabled() {
+		if err := s.twoFactorService.Verify(user, code, client); err != nil {
+			return err
+		}
+	}
+
+	if err := s.savePin(user.ID, newPin); err != nil {
+		return err
+	}
+
+	if _, err := s.cache.Delete(pinAttemptsKey(user.ID), pinLockKey(user.ID), pinResetAttemptsKey(user.ID)); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&user.ID, model.AuditActionPinReset, client, nil)
+	return nil
+}
+
+// VerifyPin checks the PIN that authorizes a withdrawal or transfer. Every failure is audited and
+// the PIN is locked after too many consecutive failures.
+func (s *pinService) VerifyPin(userID uuid.UUID, pin string, client dto.ClientInfo) error {
+	locked, err := s.isLocked(userID)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return errPinLocked
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.HasPin() {
+		return &PinError{Code: CodePinNotSet, Message: "set a transaction PIN before moving money"}
+	}
+
+	match, err := s.hashing.ComparePassword(pin, user.PinHash)
+	if err != nil {
+		return err
+	}
+
+	if !match {
+		return s.recordFailure(user.ID, client)
+	}
+
+	if _, err := s.cache.Delete(pinAttemptsKey(user.ID)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *pinService) recordFailure(userID uuid.UUID, client dto.ClientInfo) error {
+	attempts, err := s.cache.Increment(pinAttemptsKey(userID), pinAttemptWindow)
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionPinFailed, client, map[string]interface{}{
+		"attempts": attempts,
+	})
+
+	if attempts < pinMaxAttempts {
+		return &PinError{
+			Code:    CodePinInvalid,
+			Message: fmt.Sprintf("invalid transaction PIN, %d attempts left", pinMaxAttempts-attempts),
+		}
+	}
+
+	if err := s.cache.SetValue(pinLockKey(userID), 1, pinLockDuration); err != nil {
+		return err
+	}
+	if _, err := s.cache.Delete(pinAttemptsKey(userID)); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionPinLocked, client, map[string]interface{}{
+		"locked_until": time.Now().Add(pinLockDuration).UTC().Format(time.RFC3339),
+	})
+
+	return errPinLocked
+}
+
+func (s *pinService) recordResetFailure(userID uuid.UUID, client dto.ClientInfo) error {
+	attempts, err := s.cache.Increment(pinResetAttemptsKey(userID), pinAttemptWindow)
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionPinResetFailed, client, map[string]interface{}{
+		"attempts": attempts,
+	})
+
+	if attempts < pinMaxAttempts {
+		return ErrInvalidPassword
+	}
+
+	if err := s.cache.SetValue(pinResetLockKey(userID), 1, pinLockDuration); err != nil {
+		return err
+	}
+	if _, err := s.cache.Delete(pinResetAttemptsKey(userID)); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionPinResetLocked, client, map[string]interface{}{
+		"locked_until": time.Now().Add(pinLockDuration).UTC().Format(time.RFC3339),
+	})
+
+	return errPinResetLocked
+}
+
+func (s *pinService) isLocked(userID uuid.UUID) (bool, error) {
+	return s.exists(pinLockKey(userID))
+}
+
+func (s *pinService) isResetLocked(userID uuid.UUID) (bool, error) {
+	return s.exists(pinResetLockKey(userID))
+}
+
+func (s *pinService) exists(key string) (bool, error) {
+	_, err := s.cache.GetValue(key)
+	if errors.Is(err, database.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *pinService) savePin(userID uuid.UUID, pin string) error {
+	pinHash, err := s.hashing.HashPassword(pin)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.UpdatePin(userID, pinHash, time.Now())
+}
+
+func pinAttemptsKey(userID uuid.UUID) string {
+	return "pin_attempts:" + userID.String()
+}
+
+func pinLockKey(userID uuid.UUID) string {
+	return "pin_locked:" + userID.String()
+}
+
+func pinResetAttemptsKey(userID uuid.UUID) string {
+	return "pin_reset_attempts:" + userID.String()
+}
+
+func pinResetLockKey(userID uuid.UUID) string {
+	return "pin_reset_locked:" + userID.String()
+}
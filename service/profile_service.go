@@ -0,0 +1,227 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+var (
+	ErrEmailTaken              = errors.New("email is already in use")
+	ErrSameEmail               = errors.New("the new email is the current email")
+	ErrInvalidEmailChangeToken = errors.New("invalid, expired or already used email change token")
+)
+
+type ProfileServiceInterface interface {
+	GetProfile(userID uuid.UUID) (dto.UserDto, error)
+	UpdateProfile(userID uuid.UUID, name string) (dto.UserDto, error)
+	ChangePassword(userID uuid.UUID, currentSessionID string, currentPassword string, newPassword string, client dto.ClientInfo) error
+	RequestEmailChange(userID uuid.UUID, email string, password string, client dto.ClientInfo) error
+	ConfirmEmailChange(token string, client dto.ClientInfo) (dto.UserDto, error)
+}
+
+type profileService struct {
+	userRepo       user_repository.UserRepository
+	sessionService SessionServiceInterface
+	auditService   AuditServiceInterface
+	mailer         config.EmailInterface
+	appURL         string
+	hashing        helper.HashingInterface
+	jwt            helper.JwtInterface
+	logger         *config.Logger
+}
+
+// NewProfileService builds the profile service, appURL is the frontend base URL used in email links.
+func NewProfileService(userRepo user_repository.UserRepository, sessionService SessionServiceInterface, auditService AuditServiceInterface, mailer config.EmailInterface, appURL string) ProfileServiceInterface {
+	return &profileService{
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		auditService:   auditService,
+		mailer:         mailer,
+		appURL:         strings.TrimRight(appURL, "/"),
+		hashing:        helper.NewHashing(),
+		jwt:            helper.NewJwt(),
+		logger:         config.NewLogger(),
+	}
+}
+
+func (s *profileService) GetProfile(userID uuid.UUID) (dto.UserDto, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.UserDto{}, err
+	}
+
+	return toUserDto(user), nil
+}
+
+func (s *profileService) UpdateProfile(userID uuid.UUID, name string) (dto.UserDto, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.UserDto{}, err
+	}
+
+	user.Name = strings.TrimSpace(name)
+
+	if err := s.userRepo.Update(user); err != nil {
+		return dto.UserDto{}, err
+	}
+
+	return toUserDto(user), nil
+}
+
+// ChangePassword replaces the password and ends every session but the current one, so whoever
+// knew the old password is signed out.
+func (s *profileService) ChangePassword(userID uuid.UUID, currentSessionID string, currentPassword string, newPassword string, client dto.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	match, err := s.hashing.ComparePassword(currentPassword, user.Password)
+	if err != nil || !match {
+		return ErrInvalidPassword
+	}
+
+	hashedPassword, err := s.hashing.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&user.ID, model.AuditActionPasswordChanged, client, nil)
+
+	_, err = s.sessionService.RevokeOtherSessions(user.ID, currentSessionID, client)
+	return err
+}
+
+// RequestEmailChange emails a confirmation link to the new address, the email only changes once
+// it is confirmed. The current address is told about the request.
+func (s *profileService) RequestEmailChange(userID uuid.UUID, email string, password string, client dto.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	match, err := s.hashing.ComparePassword(password, user.Password)
+	if err != nil || !match {
+		return ErrInvalidPassword
+	}
+
+	email = model.NormalizeEmail(email)
+	if email == user.Email {
+		return ErrSameEmail
+	}
+
+	if err := s.checkEmailFree(email); err != nil {
+		return err
+	}
+
+	lifetime := s.jwt.TokenLifetime("change_email")
+
+	// the token is bound to the current email, so it stops working once the email changed
+	token, err := s.jwt.CreateTokenWithClaims(user.ID.String(), "change_email", map[string]interface{}{
+		"email":         email,
+		"current_email": user.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.mailer.SendWithTemplate(email, "Confirm your new email", "templates/confirm_email_change.html", map[string]interface{}{
+		"Name":      user.Name,
+		"Link":      s.appURL + "/confirm-email-change?token=" + url.QueryEscape(token),
+		"ExpiresIn": durationText(lifetime),
+	}); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := s.mailer.SendWithTemplate(user.Email, "Your email is being changed", "templates/email_change_requested.html", map[string]interface{}{
+			"Name":     user.Name,
+			"NewEmail": email,
+		}); err != nil {
+			s.logger.Log().Errorf("failed to send email change notice to %s: %v", user.Email, err)
+		}
+	}()
+
+	s.auditService.Record(&user.ID, model.AuditActionEmailChangeRequested, client, map[string]interface{}{
+		"new_email": email,
+	})
+
+	return nil
+}
+
+// ConfirmEmailChange switches the user to the email the token was sent to. Confirming the link
+// proves the new address, so it counts as verified. The link is opened outside any session, so
+// every session is ended and the user signs in again with the new email.
+func (s *profileService) ConfirmEmailChange(token string, client dto.ClientInfo) (dto.UserDto, error) {
+	claims, err := s.jwt.ExtractClaims(token, "change_email")
+	if err != nil {
+		return dto.UserDto{}, ErrInvalidEmailChangeToken
+	}
+
+	subject, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return dto.UserDto{}, ErrInvalidEmailChangeToken
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.UserDto{}, ErrInvalidEmailChangeToken
+	}
+
+	email, _ := claims["email"].(string)
+	currentEmail, _ := claims["current_email"].(string)
+	if email == "" || currentEmail != user.Email {
+		return dto.UserDto{}, ErrInvalidEmailChangeToken
+	}
+
+	if err := s.checkEmailFree(email); err != nil {
+		return dto.UserDto{}, err
+	}
+
+	previousEmail := user.Email
+	verifiedAt := time.Now()
+	user.Email = email
+	user.VerifiedAt = &verifiedAt
+
+	if err := s.userRepo.Update(user); err != nil {
+		return dto.UserDto{}, err
+	}
+
+	s.auditService.Record(&user.ID, model.AuditActionEmailChanged, client, map[string]interface{}{
+		"previous_email": previousEmail,
+		"new_email":      user.Email,
+	})
+
+	if _, err := s.sessionService.RevokeOtherSessions(user.ID, "", client); err != nil {
+		return dto.UserDto{}, err
+	}
+
+	return toUserDto(user), nil
+}
+
+func (s *profileService) checkEmailFree(email string) error {
+	_, err := s.userRepo.FindByEmail(email)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrRateUnavailable = errors.New("no exchange rate available for this currency pair")
+
+// RateProvider returns the mid-market rate to convert one unit of from into to.
+type RateProvider interface {
+	GetRate(from, to string) (decimal.Decimal, error)
+}
+
+type staticRateProvider struct {
+	rates map[string]decimal.Decimal
+}
+
+// NewStaticRateProvider serves fixed rates keyed by "FROM/TO", e.g. "USD/NGN".
+// The inverse pair is derived when only one direction is configured.
+func NewStaticRateProvider(rates map[string]decimal.Decimal) RateProvider {
+	normalised := make(map[string]decimal.Decimal, len(rates))
+	for pair, rate := range rates {
+		normalised[strings.ToUpper(pair)] = rate
+	}
+
+	return &staticRateProvider{rates: normalised}
+}
+
+// NewFileRateProvider loads static rates from a JSON file of the form {"USD/NGN": "1530.25"}.
+func NewFileRateProvider(path string) (RateProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[string]decimal.Decimal
+	if err := json.Unmarshal(content, &rates); err != nil {
+		return nil, fmt.Errorf("invalid rates file %s: %w", path, err)
+	}
+
+	return NewStaticRateProvider(rates), nil
+}
+
+func (p *staticRateProvider) GetRate(from, to string) (decimal.Decimal, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+
+	if from == to {
+		return decimal.NewFromInt(1), nil
+	}
+
+	if rate, ok := p.rates[from+"/"+to]; ok && rate.IsPositive() {
+		return rate, nil
+	}
+
+	if rate, ok := p.rates[to+"/"+from]; ok && rate.IsPositive() {
+		return decimal.NewFromInt(1).DivRound(rate, 12), nil
+	}
+
+	return decimal.Zero, fmt.Errorf("%w: %s/%s", ErrRateUnavailable, from, to)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/horlakz/wallet-sync.api/internal/config"
@@ -8,6 +9,8 @@ import (
 	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
 )
 
+var ErrLedgerImbalance = errors.New("ledger is out of balance")
+
 type ReconciliationService interface {
 	ReconcileTransactions() error
 }
@@ -50,6 +53,18 @@ func (s *reconciliationService) ReconcileTransactions() (err error) {
 		return err
 	}
 
+	// Every journal balances, so all postings together must sum to zero
+	credits, debits, err := s.ledgerEntryRepo.GetLedgerTotals()
+	if err != nil {
+		return err
+	}
+
+	var ledgerErr error
+	if ledgerDiff := credits.Sub(debits); !ledgerDiff.IsZero() {
+		s.logger.Log().Errorf("ledger out of balance: credits %s, debits %s, difference %s", credits, debits, ledgerDiff)
+		ledgerErr = fmt.Errorf("%w: credits exceed debits by %s", ErrLedgerImbalance, ledgerDiff)
+	}
+
 	for _, account := range accounts {
 		func() {
 			defer func() {
@@ -88,5 +103,5 @@ func (s *reconciliationService) ReconcileTransactions() (err error) {
 		}()
 	}
 
-	return nil
+	return ledgerErr
 }
@@ -0,0 +1,481 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+var (
+	// ScheduleClaimDuration is how long an instance owns a due schedule while executing it.
+	// A crashed instance releases its schedules once the claim runs out.
+	ScheduleClaimDuration = 5 * time.Minute
+	// ScheduleRetryDelay is how long a transfer that failed on insufficient funds waits before it is retried.
+	ScheduleRetryDelay = time.Hour
+	// ScheduleMaxFailures is how many consecutive failures pause a schedule.
+	ScheduleMaxFailures = 3
+)
+
+const (
+	dueSchedulesBatchSize = 100
+	scheduleRunsLimit     = 50
+)
+
+var (
+	ErrInvalidSchedule    = errors.New("a schedule is either one-off with run_at, or recurring with cron or interval_minutes")
+	ErrScheduleInPast     = errors.New("run_at must be in the future")
+	ErrScheduleNotActive  = errors.New("schedule is not active")
+	ErrScheduleNotPaused  = errors.New("schedule is not paused")
+	ErrScheduleToSelf     = errors.New("cannot schedule a transfer to your own account")
+	ErrInvalidDestination = errors.New("invalid destination account")
+	ErrScheduleRunning    = errors.New("schedule is running, try again shortly")
+)
+
+// ScheduleInput describes a scheduled transfer. RunAt alone makes a one-off transfer; with
+// CronExpression (standard five fields) or IntervalMinutes the transfer recurs, starting at RunAt if set.
+type ScheduleInput struct {
+	ToAccountNumber string
+	Currency        string
+	Amount          decimal.Decimal
+	Description     string
+	RunAt           *time.Time
+	CronExpression  string
+	IntervalMinutes int
+}
+
+type ScheduleServiceInterface interface {
+	CreateSchedule(userID uuid.UUID, input ScheduleInput) (dto.ScheduledTransferDto, error)
+	GetSchedules(userID uuid.UUID) ([]dto.ScheduledTransferDto, error)
+	GetSchedule(userID uuid.UUID, id uuid.UUID) (dto.ScheduledTransferDto, error)
+	UpdateSchedule(userID uuid.UUID, id uuid.UUID, input ScheduleInput) (dto.ScheduledTransferDto, error)
+	PauseSchedule(userID uuid.UUID, id uuid.UUID) (dto.ScheduledTransferDto, error)
+	ResumeSchedule(userID uuid.UUID, id uuid.UUID) (dto.ScheduledTransferDto, error)
+	DeleteSchedule(userID uuid.UUID, id uuid.UUID) error
+	GetScheduleRuns(userID uuid.UUID, id uuid.UUID) ([]dto.ScheduledTransferRunDto, error)
+	RunDueSchedules() (int, error)
+}
+
+type scheduleService struct {
+	scheduleRepo  core_repository.ScheduledTransferRepository
+	accountRepo   core_repository.AccountRepository
+	walletService WalletServiceInterface
+}
+
+func NewScheduleService(
+	scheduleRepo core_repository.ScheduledTransferRepository,
+	accountRepo core_repository.AccountRepository,
+	walletService WalletServiceInterface,
+) ScheduleServiceInterface {
+	return &scheduleService{
+		scheduleRepo:  scheduleRepo,
+		accountRepo:   accountRepo,
+		walletService: walletService,
+	}
+}
+
+func (s *scheduleService) CreateSchedule(userID uuid.UUID, input ScheduleInput) (dto.ScheduledTransferDto, error) {
+	schedule := &model.ScheduledTransfer{
+		UserID: userID,
+		Status: model.ScheduleActive,
+	}
+
+	if err := s.applyInput(schedule, input); err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	if err := s.scheduleRepo.CreateSchedule(schedule); err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	return toScheduledTransferDto(schedule), nil
+}
+
+func (s *scheduleService) GetSchedules(userID uuid.UUID) ([]dto.ScheduledTransferDto, error) {
+	schedules, err := s.scheduleRepo.GetSchedulesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ScheduledTransferDto, 0, len(schedules))
+	for i := range schedules {
+		result = append(result, toScheduledTransferDto(&schedules[i]))
+	}
+
+	return result, nil
+}
+
+func (s *scheduleService) GetSchedule(userID uuid.UUID, id uuid.UUID) (dto.ScheduledTransferDto, error) {
+	schedule, err := s.scheduleRepo.GetScheduleByID(userID, id)
+	if err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	return toScheduledTransferDto(schedule), nil
+}
+
+// UpdateSchedule replaces the transfer and its timing. A completed one-off schedule becomes active again.
+func (s *scheduleService) UpdateSchedule(userID uuid.UUID, id uuid.UUID, input ScheduleInput) (dto.ScheduledTransferDto, error) {
+	schedule, err := s.scheduleRepo.GetScheduleByID(userID, id)
+	if err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	if err := s.applyInput(schedule, input); err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	if schedule.Status == model.ScheduleCompleted {
+		schedule.Status = model.ScheduleActive
+	}
+
+	if err := s.updateSchedule(schedule); err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	return toScheduledTransferDto(schedule), nil
+}
+
+func (s *scheduleService) PauseSchedule(userID uuid.UUID, id uuid.UUID) (dto.ScheduledTransferDto, error) {
+	schedule, err := s.scheduleRepo.GetScheduleByID(userID, id)
+	if err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	if schedule.Status != model.ScheduleActive {
+		return dto.ScheduledTransferDto{}, ErrScheduleNotActive
+	}
+
+	schedule.Status = model.SchedulePaused
+
+	if err := s.updateSchedule(schedule); err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	return toScheduledTransferDto(schedule), nil
+}
+
+// ResumeSchedule reactivates a paused schedule and clears its failures. Occurrences missed while
+// paused are skipped, a one-off transfer whose time has passed runs straight away.
+func (s *scheduleService) ResumeSchedule(userID uuid.UUID, id uuid.UUID) (dto.ScheduledTransferDto, error) {
+	schedule, err := s.scheduleRepo.GetScheduleByID(userID, id)
+	if err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	if schedule.Status != model.SchedulePaused {
+		return dto.ScheduledTransferDto{}, ErrScheduleNotPaused
+	}
+
+	now := time.Now()
+	if schedule.IsRecurring() && schedule.NextRunAt.Before(now) {
+		schedule.NextRunAt = schedule.Occurrence()
+		schedule.PlannedRunAt = nil
+
+		next, err := nextRun(schedule, now)
+		if err != nil {
+			return dto.ScheduledTransferDto{}, err
+		}
+		schedule.NextRunAt = next
+	}
+
+	schedule.Status = model.ScheduleActive
+	schedule.FailureCount = 0
+	schedule.LastError = ""
+
+	if err := s.updateSchedule(schedule); err != nil {
+		return dto.ScheduledTransferDto{}, err
+	}
+
+	return toScheduledTransferDto(schedule), nil
+}
+
+func (s *scheduleService) DeleteSchedule(userID uuid.UUID, id uuid.UUID) error {
+	schedule, err := s.scheduleRepo.GetScheduleByID(userID, id)
+	if err != nil {
+		return err
+	}
+
+	return s.scheduleRepo.DeleteSchedule(schedule)
+}
+
+func (s *scheduleService) GetScheduleRuns(userID uuid.UUID, id uuid.UUID) ([]dto.ScheduledTransferRunDto, error) {
+	schedule, err := s.scheduleRepo.GetScheduleByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := s.scheduleRepo.GetRunsByScheduleID(schedule.ID, scheduleRunsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ScheduledTransferRunDto, 0, len(runs))
+	for _, run := range runs {
+		result = append(result, dto.ScheduledTransferRunDto{
+			Status:               run.Status,
+			TransactionReference: run.TransactionReference,
+			Error:                run.Error,
+			CreatedAt:            run.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	return result, nil
+}
+
+// RunDueSchedules executes every due schedule this instance manages to claim. Schedules claimed
+// by another instance are skipped, so the cron job can run on every instance at the same time.
+func (s *scheduleService) RunDueSchedules() (int, error) {
+	now := time.Now()
+
+	schedules, err := s.scheduleRepo.GetDueSchedules(now, dueSchedulesBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for i := range schedules {
+		claimed, err := s.scheduleRepo.ClaimSchedule(schedules[i].ID, now, now.Add(ScheduleClaimDuration))
+		if err != nil {
+			return executed, err
+		}
+		if !claimed {
+			continue
+		}
+
+		// the listed copy may predate an edit made before the claim, the claimed row is what is paid
+		schedule, err := s.scheduleRepo.GetScheduleByID(schedules[i].UserID, schedules[i].ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return executed, err
+		}
+		if schedule.Status != model.ScheduleActive {
+			schedule.ClaimedUntil = nil
+			if err := s.scheduleRepo.SaveRunResult(schedule); err != nil {
+				return executed, err
+			}
+			continue
+		}
+
+		if err := s.execute(schedule); err != nil {
+			return executed, err
+		}
+
+		executed++
+	}
+
+	return executed, nil
+}
+
+// execute runs a claimed schedule and records the outcome. Transfers that fail on insufficient funds
+// are retried after ScheduleRetryDelay, other failures move on to the next occurrence. Either way the
+// schedule is paused after ScheduleMaxFailures consecutive failures.
+func (s *scheduleService) execute(schedule *model.ScheduledTransfer) error {
+	now := time.Now()
+	run := &model.ScheduledTransferRun{ScheduleID: schedule.ID}
+
+	// the occurrence key makes a run that repeats after a crash return the transfer it already posted
+	transaction, transferErr := s.walletService.TransferFunds(schedule.UserID, schedule.ToAccountNumber, schedule.Currency, schedule.Amount, "", occurrenceKey(schedule))
+
+	schedule.LastRunAt = &now
+	schedule.ClaimedUntil = nil
+
+	if transferErr == nil {
+		run.Status = model.ScheduleRunSucceeded
+		run.TransactionReference = transaction.Reference
+
+		schedule.FailureCount = 0
+		schedule.LastError = ""
+
+		if err := s.advance(schedule, now); err != nil {
+			return err
+		}
+	} else {
+		run.Status = model.ScheduleRunFailed
+		run.Error = truncate(transferErr.Error(), 255)
+
+		schedule.FailureCount++
+		schedule.LastError = run.Error
+
+		switch {
+		case schedule.FailureCount >= ScheduleMaxFailures:
+			schedule.Status = model.SchedulePaused
+		case errors.Is(transferErr, ErrInsufficientBalance):
+			// the retry pays the same occurrence, and the ones after it keep their planned times
+			occurrence := schedule.Occurrence()
+			schedule.PlannedRunAt = &occurrence
+			schedule.NextRunAt = now.Add(ScheduleRetryDelay)
+		case schedule.IsRecurring():
+			if err := s.advance(schedule, now); err != nil {
+				return err
+			}
+		default:
+			// nothing left to run for a one-off transfer, wait for the user to fix and resume it
+			schedule.Status = model.SchedulePaused
+		}
+	}
+
+	if err := s.scheduleRepo.CreateRun(run); err != nil {
+		return err
+	}
+
+	return s.scheduleRepo.SaveRunResult(schedule)
+}
+
+// updateSchedule stores a change made by the user, refused while a run has claimed the schedule.
+func (s *scheduleService) updateSchedule(schedule *model.ScheduledTransfer) error {
+	updated, err := s.scheduleRepo.UpdateSchedule(schedule, time.Now())
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrScheduleRunning
+	}
+	return nil
+}
+
+// occurrenceKey identifies the occurrence a run pays. It only changes once the schedule moves on,
+// so every attempt at the same occurrence posts at most one transfer.
+func occurrenceKey(schedule *model.ScheduledTransfer) string {
+	return fmt.Sprintf("schedule:%s:%d", schedule.ID, schedule.Occurrence().Unix())
+}
+
+// advance moves the schedule to the occurrence after the one just run, or completes a one-off
+// transfer. The next run is counted from the planned occurrence rather than from a retry, so
+// retries do not shift an interval schedule.
+func (s *scheduleService) advance(schedule *model.ScheduledTransfer, now time.Time) error {
+	if !schedule.IsRecurring() {
+		schedule.Status = model.ScheduleCompleted
+		schedule.PlannedRunAt = nil
+		return nil
+	}
+
+	schedule.NextRunAt = schedule.Occurrence()
+	schedule.PlannedRunAt = nil
+
+	next, err := nextRun(schedule, now)
+	if err != nil {
+		return err
+	}
+
+	schedule.NextRunAt = next
+	return nil
+}
+
+// applyInput validates the input and copies it onto the schedule, computing its first run.
+func (s *scheduleService) applyInput(schedule *model.ScheduledTransfer, input ScheduleInput) error {
+	walletCurrency, err := checkAmount(input.Currency, input.Amount)
+	if err != nil {
+		return err
+	}
+
+	if input.CronExpression != "" && input.IntervalMinutes > 0 {
+		return ErrInvalidSchedule
+	}
+
+	if input.CronExpression == "" && input.IntervalMinutes <= 0 && input.RunAt == nil {
+		return ErrInvalidSchedule
+	}
+
+	now := time.Now()
+	if input.RunAt != nil && !input.RunAt.After(now) {
+		return ErrScheduleInPast
+	}
+
+	toAccount, err := s.accountRepo.GetAccountByNumber(input.ToAccountNumber)
+	if err != nil {
+		return ErrInvalidDestination
+	}
+
+	if toAccount.UserID == nil || toAccount.AccountType != model.AccountTypeWallet {
+		return ErrInvalidDestination
+	}
+
+	if *toAccount.UserID == schedule.UserID {
+		return ErrScheduleToSelf
+	}
+
+	schedule.ToAccountNumber = input.ToAccountNumber
+	schedule.Currency = walletCurrency.Code
+	schedule.Amount = input.Amount
+	schedule.Description = input.Description
+	schedule.CronExpression = input.CronExpression
+	schedule.IntervalMinutes = input.IntervalMinutes
+	schedule.FailureCount = 0
+	schedule.LastError = ""
+	schedule.PlannedRunAt = nil
+
+	switch {
+	case input.RunAt != nil:
+		schedule.NextRunAt = *input.RunAt
+	case schedule.IntervalMinutes > 0:
+		schedule.NextRunAt = now.Add(time.Duration(schedule.IntervalMinutes) * time.Minute)
+	default:
+		next, err := nextRun(schedule, now)
+		if err != nil {
+			return err
+		}
+		schedule.NextRunAt = next
+	}
+
+	return nil
+}
+
+// nextRun returns the first occurrence of a recurring schedule after now. Interval schedules keep
+// their original cadence, skipping the occurrences that were missed.
+func nextRun(schedule *model.ScheduledTransfer, now time.Time) (time.Time, error) {
+	if schedule.CronExpression != "" {
+		spec, err := cron.ParseStandard(schedule.CronExpression)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return spec.Next(now), nil
+	}
+
+	interval := time.Duration(schedule.IntervalMinutes) * time.Minute
+	next := schedule.NextRunAt
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+	return next, nil
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length]
+}
+
+func toScheduledTransferDto(schedule *model.ScheduledTransfer) dto.ScheduledTransferDto {
+	scheduleDto := dto.ScheduledTransferDto{
+		ID:              schedule.ID.String(),
+		ToAccountNumber: schedule.ToAccountNumber,
+		Currency:        schedule.Currency,
+		Amount:          schedule.Amount,
+		Description:     schedule.Description,
+		CronExpression:  schedule.CronExpression,
+		IntervalMinutes: schedule.IntervalMinutes,
+		NextRunAt:       schedule.NextRunAt.Format(time.RFC3339),
+		Status:          schedule.Status,
+		FailureCount:    schedule.FailureCount,
+		LastError:       schedule.LastError,
+		CreatedAt:       schedule.CreatedAt.Format(time.RFC3339),
+	}
+
+	if schedule.LastRunAt != nil {
+		scheduleDto.LastRunAt = schedule.LastRunAt.Format(time.RFC3339)
+	}
+
+	return scheduleDto
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+// fakeScheduleRepository returns listed as the due schedules and keeps the rows themselves in stored.
+type fakeScheduleRepository struct {
+	core_repository.ScheduledTransferRepository
+
+	listed []model.ScheduledTransfer
+	stored map[uuid.UUID]*model.ScheduledTransfer
+	runs   []*model.ScheduledTransferRun
+}
+
+func (r *fakeScheduleRepository) GetDueSchedules(now time.Time, limit int) ([]model.ScheduledTransfer, error) {
+	return r.listed, nil
+}
+
+func (r *fakeScheduleRepository) ClaimSchedule(id uuid.UUID, now time.Time, until time.Time) (bool, error) {
+	return r.stored[id] != nil, nil
+}
+
+func (r *fakeScheduleRepository) GetScheduleByID(userID uuid.UUID, id uuid.UUID) (*model.ScheduledTransfer, error) {
+	schedule, ok := r.stored[id]
+	if !ok || schedule.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *schedule
+	return &copied, nil
+}
+
+func (r *fakeScheduleRepository) SaveRunResult(schedule *model.ScheduledTransfer) error {
+	saved := *schedule
+	r.stored[schedule.ID] = &saved
+	return nil
+}
+
+func (r *fakeScheduleRepository) CreateRun(run *model.ScheduledTransferRun) error {
+	r.runs = append(r.runs, run)
+	return nil
+}
+
+// fakeTransferWallet records the transfers it is asked for and fails them while failWith is set.
+type fakeTransferWallet struct {
+	WalletServiceInterface
+
+	amounts  []decimal.Decimal
+	keys     []string
+	failWith error
+}
+
+func (w *fakeTransferWallet) TransferFunds(fromUserID uuid.UUID, toAccountNumber string, currency string, amount decimal.Decimal, quoteID string, idempotencyKey string) (dto.TransactionDto, error) {
+	w.amounts = append(w.amounts, amount)
+	w.keys = append(w.keys, idempotencyKey)
+	if w.failWith != nil {
+		return dto.TransactionDto{}, w.failWith
+	}
+	return dto.TransactionDto{Reference: "TXN-" + idempotencyKey}, nil
+}
+
+func newTestSchedule(nextRunAt time.Time) model.ScheduledTransfer {
+	schedule := model.ScheduledTransfer{
+		UserID:          uuid.New(),
+		ToAccountNumber: "0123456789",
+		Currency:        "NGN",
+		Amount:          decimal.NewFromInt(100),
+		IntervalMinutes: 24 * 60,
+		NextRunAt:       nextRunAt,
+		Status:          model.ScheduleActive,
+	}
+	schedule.ID = uuid.New()
+	return schedule
+}
+
+func TestRunDueSchedulesPaysClaimedRow(t *testing.T) {
+	listed := newTestSchedule(time.Now().Add(-time.Minute))
+
+	// edited after it was listed but before it was claimed
+	edited := listed
+	edited.Amount = decimal.NewFromInt(250)
+
+	paused := newTestSchedule(time.Now().Add(-time.Minute))
+	pausedRow := paused
+	pausedRow.Status = model.SchedulePaused
+
+	deleted := newTestSchedule(time.Now().Add(-time.Minute))
+
+	repo := &fakeScheduleRepository{
+		listed: []model.ScheduledTransfer{listed, paused, deleted},
+		stored: map[uuid.UUID]*model.ScheduledTransfer{listed.ID: &edited, paused.ID: &pausedRow},
+	}
+	wallet := &fakeTransferWallet{}
+
+	executed, err := NewScheduleService(repo, nil, wallet).RunDueSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if executed != 1 || len(wallet.amounts) != 1 {
+		t.Fatalf("executed %d, transfers %v, want only the active schedule paid", executed, wallet.amounts)
+	}
+	if !wallet.amounts[0].Equal(edited.Amount) {
+		t.Errorf("paid %s, want the edited amount %s", wallet.amounts[0], edited.Amount)
+	}
+	if repo.stored[paused.ID].Status != model.SchedulePaused {
+		t.Errorf("paused schedule status = %s", repo.stored[paused.ID].Status)
+	}
+}
+
+func TestRunDueSchedulesRetryKeepsCadence(t *testing.T) {
+	planned := time.Now().Add(-time.Minute).Truncate(time.Second)
+	schedule := newTestSchedule(planned)
+
+	repo := &fakeScheduleRepository{
+		listed: []model.ScheduledTransfer{schedule},
+		stored: map[uuid.UUID]*model.ScheduledTransfer{schedule.ID: &schedule},
+	}
+	wallet := &fakeTransferWallet{failWith: ErrInsufficientBalance}
+	scheduleService := NewScheduleService(repo, nil, wallet)
+
+	if _, err := scheduleService.RunDueSchedules(); err != nil {
+		t.Fatal(err)
+	}
+
+	retrying := *repo.stored[schedule.ID]
+	if retrying.PlannedRunAt == nil || !retrying.PlannedRunAt.Equal(planned) {
+		t.Fatalf("planned run at = %v, want %s", retrying.PlannedRunAt, planned)
+	}
+	if !retrying.NextRunAt.After(time.Now().Add(ScheduleRetryDelay - time.Minute)) {
+		t.Errorf("next run at %s, want a retry after %s", retrying.NextRunAt, ScheduleRetryDelay)
+	}
+
+	// the retry is due and the wallet can now pay
+	retrying.NextRunAt = time.Now().Add(-time.Second)
+	repo.stored[schedule.ID] = &retrying
+	repo.listed = []model.ScheduledTransfer{retrying}
+	wallet.failWith = nil
+
+	if _, err := scheduleService.RunDueSchedules(); err != nil {
+		t.Fatal(err)
+	}
+
+	// both attempts paid the same occurrence, and the next one is a day after it was planned
+	if len(wallet.keys) != 2 || wallet.keys[0] != wallet.keys[1] {
+		t.Errorf("idempotency keys = %v, want the same key for the retry", wallet.keys)
+	}
+	paid := repo.stored[schedule.ID]
+	if want := planned.Add(24 * time.Hour); !paid.NextRunAt.Equal(want) {
+		t.Errorf("next run at %s, want %s", paid.NextRunAt, want)
+	}
+	if paid.PlannedRunAt != nil || paid.FailureCount != 0 {
+		t.Errorf("schedule after the retry = %+v", paid)
+	}
+}
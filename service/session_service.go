@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// sessionSeenEvery is how often a session's last seen time is written, requests in between are not recorded.
+const sessionSeenEvery = 5 * time.Minute
+
+var ErrCurrentSession = errors.New("use logout to end the current session")
+
+// SessionServiceInterface keeps the session records users see as their devices. Revoking a
+// session also ends it in the token store, so its access tokens stop working at once.
+type SessionServiceInterface interface {
+	CreateSession(userID uuid.UUID, deviceName string, client dto.ClientInfo, expiresAt time.Time) (*model.Session, error)
+	ExtendSession(sessionID string, expiresAt time.Time) error
+	Touch(sessionID string, client dto.ClientInfo)
+	GetSessions(userID uuid.UUID, currentSessionID string) ([]dto.SessionDto, error)
+	RevokeSession(userID uuid.UUID, sessionID uuid.UUID, currentSessionID string, client dto.ClientInfo) error
+	RevokeOtherSessions(userID uuid.UUID, currentSessionID string, client dto.ClientInfo) (int, error)
+	RevokeAllSessions(userID uuid.UUID) error
+	EndSession(sessionID string) error
+}
+
+type sessionService struct {
+	sessionRepo  user_repository.SessionRepository
+	tokenStore   TokenStoreInterface
+	auditService AuditServiceInterface
+	cache        database.RedisClientInterface
+	logger       *config.Logger
+}
+
+func NewSessionService(sessionRepo user_repository.SessionRepository, tokenStore TokenStoreInterface, auditService AuditServiceInterface, cache database.RedisClientInterface) SessionServiceInterface {
+	return &sessionService{
+		sessionRepo:  sessionRepo,
+		tokenStore:   tokenStore,
+		auditService: auditService,
+		cache:        cache,
+		logger:       config.NewLogger(),
+	}
+}
+
+// CreateSession records a login. Without a device name one is made up from the user agent.
+func (s *sessionService) CreateSession(userID uuid.UUID, deviceName string, client dto.ClientInfo, expiresAt time.Time) (*model.Session, error) {
+	deviceName = strings.TrimSpace(deviceName)
+	if deviceName == "" {
+		deviceName = deviceNameFromUserAgent(client.UserAgent)
+	}
+
+	session := &model.Session{
+		UserID:     userID,
+		DeviceName: truncate(deviceName, 100),
+		UserAgent:  truncate(client.UserAgent, 255),
+		IPAddress:  client.IPAddress,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  expiresAt,
+	}
+
+	if err := s.sessionRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// ExtendSession moves the expiry of a session whose refresh token was rotated.
+func (s *sessionService) ExtendSession(sessionID string, expiresAt time.Time) error {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil
+	}
+
+	return s.sessionRepo.ExtendSession(id, time.Now(), expiresAt)
+}
+
+// Touch records that the session was used. Failures are logged, they must not fail the request.
+func (s *sessionService) Touch(sessionID string, client dto.ClientInfo) {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return
+	}
+
+	due, err := s.cache.SetValueNX("session_seen:"+sessionID, 1, sessionSeenEvery)
+	if err != nil {
+		s.logger.Log().Errorf("failed to check when session %s was last seen: %v", sessionID, err)
+		return
+	}
+	if !due {
+		return
+	}
+
+	if err := s.sessionRepo.TouchSession(id, client.IPAddress, time.Now()); err != nil {
+		s.logger.Log().Errorf("failed to record session %s as seen: %v", sessionID, err)
+	}
+}
+
+func (s *sessionService) GetSessions(userID uuid.UUID, currentSessionID string) ([]dto.SessionDto, error) {
+	sessions, err := s.sessionRepo.GetActiveSessions(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.SessionDto, 0, len(sessions))
+	for i := range sessions {
+		result = append(result, toSessionDto(&sessions[i], currentSessionID))
+	}
+
+	return result, nil
+}
+
+func (s *sessionService) RevokeSession(userID uuid.UUID, sessionID uuid.UUID, currentSessionID string, client dto.ClientInfo) error {
+	if sessionID.String() == currentSessionID {
+		return ErrCurrentSession
+	}
+
+	session, err := s.sessionRepo.GetSessionByID(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.revoke([]model.Session{*session}); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionSessionRevoked, client, map[string]interface{}{
+		"session_id":  session.ID,
+		"device_name": session.DeviceName,
+	})
+
+	return nil
+}
+
+// RevokeOtherSessions signs the user out everywhere but the current session and returns how many sessions ended.
+func (s *sessionService) RevokeOtherSessions(userID uuid.UUID, currentSessionID string, client dto.ClientInfo) (int, error) {
+	sessions, err := s.sessionRepo.GetActiveSessions(userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	others := make([]model.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ID.String() != currentSessionID {
+			others = append(others, session)
+		}
+	}
+
+	if err := s.revoke(others); err != nil {
+		return 0, err
+	}
+
+	if len(others) > 0 {
+		s.auditService.Record(&userID, model.AuditActionSessionsRevoked, client, map[string]interface{}{
+			"count": len(others),
+		})
+	}
+
+	return len(others), nil
+}
+
+// RevokeAllSessions signs the user out everywhere, for instance after a password reset.
+func (s *sessionService) RevokeAllSessions(userID uuid.UUID) error {
+	sessions, err := s.sessionRepo.GetActiveSessions(userID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return s.revoke(sessions)
+}
+
+// EndSession ends the caller's own session on logout.
+func (s *sessionService) EndSession(sessionID string) error {
+	if err := s.tokenStore.RevokeSession(sessionID); err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil
+	}
+
+	return s.sessionRepo.RevokeSessions([]uuid.UUID{id}, time.Now())
+}
+
+// revoke ends the sessions in the token store first, so they stop working even if the records cannot be updated.
+func (s *sessionService) revoke(sessions []model.Session) error {
+	ids := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		if err := s.tokenStore.RevokeSession(session.ID.String()); err != nil {
+			return err
+		}
+		ids = append(ids, session.ID)
+	}
+
+	return s.sessionRepo.RevokeSessions(ids, time.Now())
+}
+
+// deviceNameFromUserAgent names the browser and platform of a user agent, such as "Chrome on Windows".
+func deviceNameFromUserAgent(userAgent string) string {
+	browsers := []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp", "Android app"},
+		{"CFNetwork", "iOS app"},
+		{"curl/", "curl"},
+	}
+	platforms := []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+
+	browser := ""
+	for _, candidate := range browsers {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, candidate := range platforms {
+		if strings.Contains(userAgent, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
+
+func toSessionDto(session *model.Session, currentSessionID string) dto.SessionDto {
+	return dto.SessionDto{
+		ID:         session.ID.String(),
+		DeviceName: session.DeviceName,
+		UserAgent:  session.UserAgent,
+		IPAddress:  session.IPAddress,
+		Current:    session.ID.String() == currentSessionID,
+		LastSeenAt: session.LastSeenAt,
+		ExpiresAt:  session.ExpiresAt,
+		CreatedAt:  session.CreatedAt,
+	}
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/internal/config"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+const (
+	// DefaultKeyRotation is how long a signing key signs new tokens before the next one takes over.
+	DefaultKeyRotation = 30 * 24 * time.Hour
+	// signingKeyPublishAhead is how long a new key is published before it signs, so that verifiers
+	// caching the key set have fetched it by the time tokens signed with it arrive.
+	signingKeyPublishAhead = time.Hour
+	// signingKeyReloadEvery is how often the keys are read again, to pick up keys made by other instances.
+	signingKeyReloadEvery = time.Minute
+	// signingKeyMissReload is the least time between reloads caused by tokens with an unknown kid.
+	signingKeyMissReload    = 10 * time.Second
+	signingKeyRotationLock  = "jwt_key_rotation"
+	signingKeyRotationLease = 5 * time.Minute
+)
+
+// SigningKeyConfig configures NewSigningKeyService. Algorithm is HS256, RS256 or EdDSA; with HS256
+// tokens are signed with the two secrets and there are no keys to publish or rotate. The private
+// keys of the other algorithms are stored encrypted with EncryptionKey, an AES-256 key.
+type SigningKeyConfig struct {
+	Algorithm     string
+	Rotation      time.Duration
+	AccessSecret  string
+	RefreshSecret string
+	EncryptionKey []byte
+}
+
+// SigningKeyConfigFromEnv reads JWT_ALGORITHM (HS256 when empty), JWT_KEY_ROTATION_DAYS, the JWT secrets
+// and JWT_KEY_ENCRYPTION_KEY, which RS256 and EdDSA need.
+func SigningKeyConfigFromEnv(env config.Env) (SigningKeyConfig, error) {
+	signingConfig := SigningKeyConfig{
+		Algorithm:     env.JWT_ALGORITHM,
+		Rotation:      DefaultKeyRotation,
+		AccessSecret:  env.JWT_ACCESS_SECRET,
+		RefreshSecret: env.JWT_REFRESH_SECRET,
+	}
+
+	if signingConfig.Algorithm == "" {
+		signingConfig.Algorithm = helper.AlgorithmHS256
+	}
+	if _, err := helper.SigningMethod(signingConfig.Algorithm); err != nil {
+		return SigningKeyConfig{}, err
+	}
+
+	if env.JWT_KEY_ROTATION_DAYS != "" {
+		days, err := strconv.Atoi(env.JWT_KEY_ROTATION_DAYS)
+		if err != nil || days < 1 {
+			return SigningKeyConfig{}, fmt.Errorf("JWT_KEY_ROTATION_DAYS must be a whole number of days, got %q", env.JWT_KEY_ROTATION_DAYS)
+		}
+		signingConfig.Rotation = time.Duration(days) * 24 * time.Hour
+	}
+
+	if signingConfig.Algorithm != helper.AlgorithmHS256 {
+		key, err := helper.ParseEncryptionKey(env.JWT_KEY_ENCRYPTION_KEY)
+		if err != nil {
+			return SigningKeyConfig{}, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY: %w", err)
+		}
+		signingConfig.EncryptionKey = key
+	}
+
+	return signingConfig, nil
+}
+
+// SigningKeyServiceInterface provides the keys tokens are signed and verified with, publishes the
+// public keys and rotates them.
+type SigningKeyServiceInterface interface {
+	helper.KeySet
+	// JWKS returns the JSON Web Key Set of the keys verifiers should accept.
+	JWKS() (map[string]interface{}, error)
+	// RotateKeys makes the next key when the current one is due to be replaced and deletes keys
+	// whose tokens have all expired. It reports whether a key was made.
+	RotateKeys() (bool, error)
+}
+
+type signingKey struct {
+	id          string
+	signer      crypto.Signer
+	activatesAt time.Time
+	// expiresAt is when the last token signed with the key expires, zero while it has no successor
+	expiresAt time.Time
+}
+
+type signingKeyService struct {
+	repo     core_repository.SigningKeyRepository
+	cache    database.RedisClientInterface
+	config   SigningKeyConfig
+	hmacKeys helper.KeySet
+	grace    time.Duration
+	logger   *config.Logger
+
+	mu       sync.RWMutex
+	keys     []signingKey
+	loadedAt time.Time
+}
+
+func NewSigningKeyService(repo core_repository.SigningKeyRepository, cache database.RedisClientInterface, signingConfig SigningKeyConfig) SigningKeyServiceInterface {
+	return &signingKeyService{
+		repo:     repo,
+		cache:    cache,
+		config:   signingConfig,
+		hmacKeys: helper.NewHMACKeySet(signingConfig.AccessSecret, signingConfig.RefreshSecret),
+		grace:    helper.LongestTokenLifetime(),
+		logger:   config.NewLogger(),
+	}
+}
+
+func (s *signingKeyService) symmetric() bool {
+	return s.config.Algorithm == helper.AlgorithmHS256
+}
+
+// SigningKey returns the newest active key, making the first key when there is none yet.
+func (s *signingKeyService) SigningKey(tokenType string) (helper.SigningKey, error) {
+	if s.symmetric() {
+		return s.hmacKeys.SigningKey(tokenType)
+	}
+
+	key, err := s.activeKey()
+	if err != nil {
+		return helper.SigningKey{}, err
+	}
+
+	if key == nil {
+		if _, err := s.RotateKeys(); err != nil {
+			return helper.SigningKey{}, err
+		}
+		if key, err = s.activeKey(); err != nil {
+			return helper.SigningKey{}, err
+		}
+		if key == nil {
+			return helper.SigningKey{}, fmt.Errorf("no %s signing key is active", s.config.Algorithm)
+		}
+	}
+
+	method, err := helper.SigningMethod(s.config.Algorithm)
+	if err != nil {
+		return helper.SigningKey{}, err
+	}
+
+	return helper.SigningKey{ID: key.id, Method: method, Key: key.signer}, nil
+}
+
+// VerificationKey returns the public key of kid if it is still published and alg is the configured algorithm.
+func (s *signingKeyService) VerificationKey(tokenType string, kid string, alg string) (interface{}, error) {
+	if s.symmetric() {
+		return s.hmacKeys.VerificationKey(tokenType, kid, alg)
+	}
+
+	if alg != s.config.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", alg)
+	}
+
+	keys, err := s.loadedKeys(false)
+	if err != nil {
+		return nil, err
+	}
+
+	key := findSigningKey(keys, kid)
+	if key == nil {
+		// the key may have been made by another instance since the last load
+		if keys, err = s.loadedKeys(true); err != nil {
+			return nil, err
+		}
+		key = findSigningKey(keys, kid)
+	}
+
+	if key == nil || (!key.expiresAt.IsZero() && !time.Now().Before(key.expiresAt)) {
+		return nil, helper.ErrUnknownSigningKey
+	}
+
+	return key.signer.Public(), nil
+}
+
+func (s *signingKeyService) JWKS() (map[string]interface{}, error) {
+	jwks := make([]map[string]interface{}, 0)
+
+	if !s.symmetric() {
+		keys, err := s.loadedKeys(false)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		for _, key := range keys {
+			if !key.expiresAt.IsZero() && !now.Before(key.expiresAt) {
+				continue
+			}
+
+			jwk, err := helper.PublicJWK(key.id, s.config.Algorithm, key.signer)
+			if err != nil {
+				return nil, err
+			}
+			jwks = append(jwks, jwk)
+		}
+	}
+
+	return map[string]interface{}{"keys": jwks}, nil
+}
+
+// RotateKeys runs on one instance at a time. The next key is made signingKeyPublishAhead before
+// the current one has signed for the rotation period, and takes over at the end of it.
+func (s *signingKeyService) RotateKeys() (bool, error) {
+	if s.symmetric() {
+		return false, nil
+	}
+
+	locked, err := s.cache.SetValueNX(signingKeyRotationLock, 1, signingKeyRotationLease)
+	if err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer func() {
+		if _, err := s.cache.Delete(signingKeyRotationLock); err != nil {
+			s.logger.Log().Errorf("failed to release the signing key rotation lock: %v", err)
+		}
+	}()
+
+	keys, err := s.readKeys()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	created := false
+
+	switch {
+	case len(keys) == 0:
+		if err := s.createKey(now); err != nil {
+			return false, err
+		}
+		created = true
+	default:
+		latest := keys[len(keys)-1]
+		rotatesAt := latest.activatesAt.Add(s.config.Rotation)
+
+		if !latest.activatesAt.After(now) && !now.Before(rotatesAt.Add(-signingKeyPublishAhead)) {
+			activatesAt := rotatesAt
+			if earliest := now.Add(signingKeyPublishAhead); activatesAt.Before(earliest) {
+				activatesAt = earliest
+			}
+
+			if err := s.createKey(activatesAt); err != nil {
+				return false, err
+			}
+			created = true
+		}
+	}
+
+	expired := make([]uuid.UUID, 0)
+	for _, key := range keys {
+		if !key.expiresAt.IsZero() && !now.Before(key.expiresAt) {
+			expired = append(expired, uuid.MustParse(key.id))
+		}
+	}
+	if err := s.repo.DeleteSigningKeys(expired); err != nil {
+		return created, err
+	}
+
+	if _, err := s.loadedKeys(true); err != nil {
+		return created, err
+	}
+
+	return created, nil
+}
+
+func (s *signingKeyService) createKey(activatesAt time.Time) error {
+	privateKey, err := helper.GenerateSigningKey(s.config.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := helper.Seal(s.config.EncryptionKey, privateKey, s.config.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.CreateSigningKey(&model.SigningKey{
+		Algorithm:   s.config.Algorithm,
+		PrivateKey:  sealed,
+		ActivatesAt: activatesAt,
+	})
+}
+
+// activeKey is the newest key whose activation has passed.
+func (s *signingKeyService) activeKey() (*signingKey, error) {
+	keys, err := s.loadedKeys(false)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].activatesAt.After(now) {
+			return &keys[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// loadedKeys returns the cached keys, reading them again once they are stale. A forced reload
+// is still limited to one every signingKeyMissReload, so unknown kids cannot flood the database.
+func (s *signingKeyService) loadedKeys(force bool) ([]signingKey, error) {
+	s.mu.RLock()
+	keys, age := s.keys, time.Since(s.loadedAt)
+	s.mu.RUnlock()
+
+	if age < signingKeyReloadEvery && (!force || age < signingKeyMissReload) {
+		return keys, nil
+	}
+
+	keys, err := s.readKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return keys, nil
+}
+
+func (s *signingKeyService) readKeys() ([]signingKey, error) {
+	records, err := s.repo.GetSigningKeys(s.config.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]signingKey, 0, len(records))
+	for i := range records {
+		record := &records[i]
+
+		privateKey, err := s.privateKey(record)
+		if err != nil {
+			s.logger.Log().Errorf("skipping unreadable signing key %s: %v", record.ID, err)
+			continue
+		}
+
+		signer, err := helper.ParseSigningKey(record.Algorithm, privateKey)
+		if err != nil {
+			s.logger.Log().Errorf("skipping unreadable signing key %s: %v", record.ID, err)
+			continue
+		}
+
+		keys = append(keys, signingKey{id: record.ID.String(), signer: signer, activatesAt: record.ActivatesAt})
+	}
+
+	// a key's tokens outlive it by the longest token lifetime once its successor has taken over
+	for i := 0; i < len(keys)-1; i++ {
+		keys[i].expiresAt = keys[i+1].activatesAt.Add(s.grace)
+	}
+
+	return keys, nil
+}
+
+// privateKey decrypts the PEM key of a record. A key stored in the clear before keys were encrypted
+// is encrypted in place.
+func (s *signingKeyService) privateKey(record *model.SigningKey) (string, error) {
+	privateKey, err := helper.Open(s.config.EncryptionKey, record.PrivateKey, record.Algorithm)
+	if !errors.Is(err, helper.ErrNotSealed) {
+		return privateKey, err
+	}
+
+	privateKey = record.PrivateKey
+	sealed, err := helper.Seal(s.config.EncryptionKey, privateKey, record.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	record.PrivateKey = sealed
+	if err := s.repo.UpdateSigningKeyPrivateKey(record); err != nil {
+		return "", err
+	}
+
+	return privateKey, nil
+}
+
+func findSigningKey(keys []signingKey, kid string) *signingKey {
+	for i := range keys {
+		if keys[i].id == kid {
+			return &keys[i]
+		}
+	}
+	return nil
+}
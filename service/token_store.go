@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/horlakz/wallet-sync.api/lib/database"
+)
+
+var (
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrRefreshTokenReused = errors.New("refresh token has already been used, the session has been revoked")
+)
+
+// TokenStoreInterface keeps the server side state of login sessions in Redis. A session is one
+// login and the family of refresh tokens rotated from it; only its latest refresh token is valid.
+type TokenStoreInterface interface {
+	StartSession(sessionID string, refreshTokenID string, ttl time.Duration) error
+	RotateRefreshToken(sessionID string, refreshTokenID string, nextRefreshTokenID string, ttl time.Duration) error
+	SessionActive(sessionID string) (bool, error)
+	RevokeSession(sessionID string) error
+	StorePasswordResetToken(tokenID string, ttl time.Duration) error
+	ConsumePasswordResetToken(tokenID string) (bool, error)
+}
+
+type tokenStore struct {
+	cache database.RedisClientInterface
+}
+
+func NewTokenStore(cache database.RedisClientInterface) TokenStoreInterface {
+	return &tokenStore{cache: cache}
+}
+
+// session:<id> holds the ID of the session's current refresh token,
+// refresh:<id> exists for as long as that refresh token can be exchanged.
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
+
+func refreshTokenKey(refreshTokenID string) string {
+	return "refresh:" + refreshTokenID
+}
+
+func passwordResetKey(tokenID string) string {
+	return "password_reset:" + tokenID
+}
+
+func (s *tokenStore) StartSession(sessionID string, refreshTokenID string, ttl time.Duration) error {
+	if err := s.cache.SetValue(refreshTokenKey(refreshTokenID), sessionID, ttl); err != nil {
+		return err
+	}
+
+	return s.cache.SetValue(sessionKey(sessionID), refreshTokenID, ttl)
+}
+
+// RotateRefreshToken consumes the presented refresh token and makes nextRefreshTokenID the current one.
+// A token that was already consumed means it leaked or was replayed, so the whole session is revoked.
+func (s *tokenStore) RotateRefreshToken(sessionID string, refreshTokenID string, nextRefreshTokenID string, ttl time.Duration) error {
+	active, err := s.SessionActive(sessionID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrSessionRevoked
+	}
+
+	// deleting the key is atomic, only one of two concurrent refreshes can consume the token
+	deleted, err := s.cache.Delete(refreshTokenKey(refreshTokenID))
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		if err := s.RevokeSession(sessionID); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+
+	return s.StartSession(sessionID, nextRefreshTokenID, ttl)
+}
+
+func (s *tokenStore) SessionActive(sessionID string) (bool, error) {
+	_, err := s.cache.GetValue(sessionKey(sessionID))
+	if errors.Is(err, database.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevokeSession ends the session, its access tokens are rejected and its refresh token can no longer be used.
+func (s *tokenStore) RevokeSession(sessionID string) error {
+	refreshTokenID, err := s.cache.GetValue(sessionKey(sessionID))
+	if errors.Is(err, database.ErrCacheMiss) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.cache.Delete(sessionKey(sessionID), refreshTokenKey(refreshTokenID))
+	return err
+}
+
+func (s *tokenStore) StorePasswordResetToken(tokenID string, ttl time.Duration) error {
+	return s.cache.SetValue(passwordResetKey(tokenID), 1, ttl)
+}
+
+// ConsumePasswordResetToken reports whether the reset token was still unused, marking it used.
+func (s *tokenStore) ConsumePasswordResetToken(tokenID string) (bool, error) {
+	deleted, err := s.cache.Delete(passwordResetKey(tokenID))
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
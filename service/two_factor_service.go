@@ -0,0 +1,482 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// TwoFactorIssuer is the name authenticator apps show next to the account.
+var TwoFactorIssuer = "WalletSync"
+
+const recoveryCodeCount = 10
+
+// Two-factor verification is locked for twoFactorLockDuration after twoFactorMaxAttempts wrong codes
+// within twoFactorAttemptWindow, so a six digit code cannot be guessed through the routes that ask for one.
+const (
+	twoFactorMaxAttempts   = 5
+	twoFactorAttemptWindow = 15 * time.Minute
+	twoFactorLockDuration  = 30 * time.Minute
+)
+
+// Machine readable codes returned when a high-value operation needs a second factor.
+const (
+	CodeStepUpRequired    = "STEP_UP_REQUIRED"
+	CodeTwoFactorRequired = "TWO_FACTOR_REQUIRED"
+	CodeTwoFactorLocked   = "TWO_FACTOR_LOCKED"
+)
+
+var (
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked         = errors.New("too many wrong two-factor codes, try again later")
+)
+
+// StepUpError is returned when an operation above the step-up threshold is attempted without a valid second factor.
+type StepUpError struct {
+	Code    string
+	Message string
+}
+
+func (e *StepUpError) Error() string {
+	return e.Message
+}
+
+type TwoFactorServiceInterface interface {
+	Setup(userID uuid.UUID) (dto.TwoFactorSetupDto, error)
+	Confirm(userID uuid.UUID, code string) ([]string, error)
+	Disable(userID uuid.UUID, code string, client dto.ClientInfo) error
+	RegenerateRecoveryCodes(userID uuid.UUID, code string, client dto.ClientInfo) ([]string, error)
+	Verify(user *model.User, code string, client dto.ClientInfo) error
+	VerifyStepUp(userID uuid.UUID, currency string, amount decimal.Decimal, code string, client dto.ClientInfo) error
+}
+
+// StepUpThresholds are the amounts above which withdrawals and transfers need a second factor, by
+// currency. When any threshold is set, a currency without one needs a second factor for every amount.
+type StepUpThresholds map[string]decimal.Decimal
+
+// ParseStepUpThresholds reads a list such as "NGN=100000,USD=100". A bare amount is the threshold of
+// the default currency, an empty value turns step-up verification off.
+func ParseStepUpThresholds(value string) (StepUpThresholds, error) {
+	thresholds := StepUpThresholds{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		code, amount, found := strings.Cut(entry, "=")
+		if !found {
+			code, amount = "", entry
+		}
+
+		currency, err := resolveCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+
+		threshold, err := decimal.NewFromString(strings.TrimSpace(amount))
+		if err != nil || threshold.IsNegative() {
+			return nil, fmt.Errorf("invalid step-up threshold %q", entry)
+		}
+
+		thresholds[currency.Code] = threshold
+	}
+
+	return thresholds, nil
+}
+
+// exceeded reports whether amount of currency needs a second factor, and the threshold it is above.
+func (t StepUpThresholds) exceeded(currency string, amount decimal.Decimal) (decimal.Decimal, bool) {
+	if len(t) == 0 {
+		return decimal.Zero, false
+	}
+
+	threshold := t[currency]
+	return threshold, amount.GreaterThan(threshold)
+}
+
+type twoFactorService struct {
+	userRepo         user_repository.UserRepository
+	recoveryCodeRepo user_repository.RecoveryCodeRepository
+	auditService     AuditServiceInterface
+	cache            database.RedisClientInterface
+	hashing          helper.HashingInterface
+	encryptionKey    []byte
+	stepUpThresholds StepUpThresholds
+}
+
+// NewTwoFactorService builds the two-factor service. TOTP secrets are stored encrypted with
+// encryptionKey, an AES-256 key. Withdrawals and transfers above their currency's stepUpThresholds
+// need a second factor, no thresholds turn step-up verification off.
+func NewTwoFactorService(
+	userRepo user_repository.UserRepository,
+	recoveryCodeRepo user_repository.RecoveryCodeRepository,
+	auditService AuditServiceInterface,
+	cache database.RedisClientInterface,
+	encryptionKey []byte,
+	stepUpThresholds StepUpThresholds,
+) TwoFactorServiceInterface {
+	return &twoFactorService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		auditService:     auditService,
+		cache:            cache,
+		hashing:          helper.NewHashing(),
+		encryptionKey:    encryptionKey,
+		stepUpThresholds: stepUpThresholds,
+	}
+}
+
+// Setup generates a new secret for the user. It replaces any pending secret and only takes effect
+// once Confirm is called with a code from the authenticator.
+func (s *twoFactorService) Setup(userID uuid.UUID) (dto.TwoFactorSetupDto, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return dto.TwoFactorSetupDto{}, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return dto.TwoFactorSetupDto{}, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil {
+		return dto.TwoFactorSetupDto{}, err
+	}
+
+	sealed, err := helper.Seal(s.encryptionKey, secret, user.ID.String())
+	if err != nil {
+		return dto.TwoFactorSetupDto{}, err
+	}
+
+	if err := s.userRepo.SetTotpSecret(user.ID, sealed); err != nil {
+		return dto.TwoFactorSetupDto{}, err
+	}
+
+	return dto.TwoFactorSetupDto{
+		Secret:     secret,
+		OtpauthURI: helper.TOTPURI(TwoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm enables two-factor authentication with the first valid code and returns the recovery codes.
+// They are only ever shown here, the database keeps their hashes.
+func (s *twoFactorService) Confirm(userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	if user.TotpSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.replaceRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.EnableTotp(user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *twoFactorService) Disable(userID uuid.UUID, code string, client dto.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Verify(user, code, client); err != nil {
+		return err
+	}
+
+	if err := s.recoveryCodeRepo.DeleteRecoveryCodes(user.ID); err != nil {
+		return err
+	}
+
+	return s.userRepo.DisableTotp(user.ID)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uuid.UUID, code string, client dto.ClientInfo) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Verify(user, code, client); err != nil {
+		return nil, err
+	}
+
+	return s.replaceRecoveryCodes(user.ID)
+}
+
+// Verify accepts either a current TOTP code or one of the user's unused recovery codes. Every wrong
+// code is audited, and verification is locked after too many of them.
+func (s *twoFactorService) Verify(user *model.User, code string, client dto.ClientInfo) error {
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	locked, err := s.isLocked(user.ID)
+	if err != nil {
+		return err
+	}
+	if locked {
+		return ErrTwoFactorLocked
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == helper.TOTPDigits {
+		err = s.verifyTOTP(user, code)
+	} else {
+		err = s.useRecoveryCode(user, code)
+	}
+
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		return s.recordFailure(user.ID, client)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.cache.Delete(twoFactorAttemptsKey(user.ID))
+	return err
+}
+
+func (s *twoFactorService) recordFailure(userID uuid.UUID, client dto.ClientInfo) error {
+	attempts, err := s.cache.Increment(twoFactorAttemptsKey(userID), twoFactorAttemptWindow)
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionTwoFactorFailed, client, map[string]interface{}{
+		"attempts": attempts,
+	})
+
+	if attempts < twoFactorMaxAttempts {
+		return ErrInvalidTwoFactorCode
+	}
+
+	if err := s.cache.SetValue(twoFactorLockKey(userID), 1, twoFactorLockDuration); err != nil {
+		return err
+	}
+	if _, err := s.cache.Delete(twoFactorAttemptsKey(userID)); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionTwoFactorLocked, client, map[string]interface{}{
+		"locked_until": time.Now().Add(twoFactorLockDuration).UTC().Format(time.RFC3339),
+	})
+
+	return ErrTwoFactorLocked
+}
+
+func (s *twoFactorService) isLocked(userID uuid.UUID) (bool, error) {
+	_, err := s.cache.GetValue(twoFactorLockKey(userID))
+	if errors.Is(err, database.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VerifyStepUp checks the second factor for a withdrawal or transfer of amount in currency. Amounts up
+// to the currency's threshold pass without one; above it the user must have two-factor authentication enabled.
+func (s *twoFactorService) VerifyStepUp(userID uuid.UUID, currency string, amount decimal.Decimal, code string, client dto.ClientInfo) error {
+	walletCurrency, err := resolveCurrency(currency)
+	if err != nil {
+		return err
+	}
+
+	threshold, exceeded := s.stepUpThresholds.exceeded(walletCurrency.Code, amount)
+	if !exceeded {
+		return nil
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled() {
+		return &StepUpError{
+			Code:    CodeTwoFactorRequired,
+			Message: fmt.Sprintf("enable two-factor authentication to move more than %s %s", threshold, walletCurrency.Code),
+		}
+	}
+
+	if strings.TrimSpace(code) == "" {
+		return &StepUpError{
+			Code:    CodeStepUpRequired,
+			Message: fmt.Sprintf("a two-factor code is required to move more than %s %s", threshold, walletCurrency.Code),
+		}
+	}
+
+	if err := s.Verify(user, code, client); err != nil {
+		if errors.Is(err, ErrTwoFactorLocked) {
+			return &StepUpError{Code: CodeTwoFactorLocked, Message: err.Error()}
+		}
+		return &StepUpError{Code: CodeStepUpRequired, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// verifyTOTP checks the code and refuses it if it was already used, so a code seen by someone
+// else cannot be replayed while it is still valid.
+func (s *twoFactorService) verifyTOTP(user *model.User, code string) error {
+	secret, err := s.totpSecret(user)
+	if err != nil {
+		return err
+	}
+
+	counter, ok := helper.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	key := fmt.Sprintf("totp_used:%s:%d", user.ID, counter)
+	stored, err := s.cache.SetValueNX(key, 1, time.Duration(2*helper.TOTPSkew+1)*helper.TOTPPeriod)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// totpSecret decrypts the user's TOTP secret. A secret stored in the clear by an earlier version is
+// encrypted in place.
+func (s *twoFactorService) totpSecret(user *model.User) (string, error) {
+	if user.TotpSecret == "" {
+		return "", ErrTwoFactorNotSetUp
+	}
+
+	secret, err := helper.Open(s.encryptionKey, user.TotpSecret, user.ID.String())
+	if !errors.Is(err, helper.ErrNotSealed) {
+		return secret, err
+	}
+
+	secret = user.TotpSecret
+	sealed, err := helper.Seal(s.encryptionKey, secret, user.ID.String())
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.userRepo.UpdateTotpSecret(user.ID, sealed); err != nil {
+		return "", err
+	}
+	user.TotpSecret = sealed
+
+	return secret, nil
+}
+
+func (s *twoFactorService) useRecoveryCode(user *model.User, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrInvalidTwoFactorCode
+	}
+
+	codes, err := s.recoveryCodeRepo.GetUnusedRecoveryCodes(user.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range codes {
+		match, err := s.hashing.ComparePassword(normalized, codes[i].CodeHash)
+		if err != nil || !match {
+			continue
+		}
+
+		used, err := s.recoveryCodeRepo.MarkRecoveryCodeUsed(&codes[i], time.Now())
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+func (s *twoFactorService) replaceRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]model.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		hash, err := s.hashing.HashPassword(normalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+		records = append(records, model.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceRecoveryCodes(userID, records); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code such as "k3jd9-x7pq2".
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 7)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))[:10]
+	return encoded[:5] + "-" + encoded[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+func twoFactorAttemptsKey(userID uuid.UUID) string {
+	return "two_factor_attempts:" + userID.String()
+}
+
+func twoFactorLockKey(userID uuid.UUID) string {
+	return "two_factor_locked:" + userID.String()
+}
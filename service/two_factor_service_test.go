@@ -0,0 +1,89 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+// fakeTwoFactorCache adds the used code markers to the token cache.
+type fakeTwoFactorCache struct {
+	*fakeTokenCache
+}
+
+func (c fakeTwoFactorCache) SetValueNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	if _, ok := c.values[key]; ok {
+		return false, nil
+	}
+	return true, c.SetValue(key, value, ttl)
+}
+
+func newTestTwoFactorService(userRepo *fakeUserRepository) TwoFactorServiceInterface {
+	return NewTwoFactorService(userRepo, nil, &fakeAuditService{}, fakeTwoFactorCache{newFakeTokenCache()}, testEncryptionKey, nil)
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code, err := helper.TOTPCode(secret, helper.TOTPCounter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestTwoFactorSecretIsSealed(t *testing.T) {
+	user := &model.User{Email: "ada@example.com"}
+	user.ID = uuid.New()
+	userRepo := &fakeUserRepository{users: map[uuid.UUID]*model.User{user.ID: user}}
+	twoFactorService := newTestTwoFactorService(userRepo)
+
+	setup, err := twoFactorService.Setup(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.TotpSecret == setup.Secret {
+		t.Fatal("the TOTP secret is stored in the clear")
+	}
+	if opened, err := helper.Open(testEncryptionKey, user.TotpSecret, user.ID.String()); err != nil || opened != setup.Secret {
+		t.Fatalf("stored secret opens to %q, %v", opened, err)
+	}
+
+	enabledAt := time.Now()
+	user.TotpEnabledAt = &enabledAt
+
+	if err := twoFactorService.Verify(user, currentTOTPCode(t, setup.Secret), dto.ClientInfo{}); err != nil {
+		t.Errorf("Verify with a code of the sealed secret: %v", err)
+	}
+}
+
+func TestTwoFactorSecretInTheClearIsSealedOnUse(t *testing.T) {
+	secret, err := helper.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as an earlier version stored it
+	enabledAt := time.Now()
+	user := &model.User{Email: "ada@example.com", TotpSecret: secret, TotpEnabledAt: &enabledAt}
+	user.ID = uuid.New()
+	userRepo := &fakeUserRepository{users: map[uuid.UUID]*model.User{user.ID: user}}
+
+	stored := *user
+	if err := newTestTwoFactorService(userRepo).Verify(&stored, currentTOTPCode(t, secret), dto.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if opened, err := helper.Open(testEncryptionKey, user.TotpSecret, user.ID.String()); err != nil || opened != secret {
+		t.Errorf("stored secret after use opens to %q, %v", opened, err)
+	}
+	if user.TotpEnabledAt == nil {
+		t.Error("sealing the secret turned two-factor authentication off")
+	}
+}
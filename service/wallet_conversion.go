@@ -0,0 +1,150 @@
+package service
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+// ConvertFunds executes a quote from FxServiceInterface.Quote, moving money between two of the
+// user's own wallets. Each currency balances on its own through the fx position accounts, and the
+// difference between the mid-market and the quoted amount is booked to the fx_pnl account.
+func (s *walletService) ConvertFunds(userID uuid.UUID, quoteID string) (dto.TransactionDto, error) {
+	var transaction model.Transaction
+
+	quote, err := consumeQuote(s.db.Cache(), userID, quoteID)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	fromWallet, err := s.accountRepo.GetWalletAccountByUserID(userID, quote.FromCurrency)
+	if err != nil {
+		return dto.TransactionDto{}, restoreQuote(s.db.Cache(), userID, quote, err)
+	}
+
+	toWallet, err := s.accountRepo.GetWalletAccountByUserID(userID, quote.ToCurrency)
+	if err != nil {
+		return dto.TransactionDto{}, restoreQuote(s.db.Cache(), userID, quote, err)
+	}
+
+	fxFrom, fxTo, fxPnl, err := s.fxAccounts(quote)
+	if err != nil {
+		return dto.TransactionDto{}, restoreQuote(s.db.Cache(), userID, quote, err)
+	}
+
+	description := "Conversion " + quote.FromCurrency + " to " + quote.ToCurrency
+
+	err = s.TxHelper(func(repos walletRepositories) error {
+
+		locked, err := lockAccounts(repos.accountRepo, fromWallet.ID, toWallet.ID, fxFrom.ID, fxTo.ID, fxPnl.ID)
+		if err != nil {
+			return err
+		}
+
+		postings, fxGain := conversionPostings(quote, locked[fromWallet.ID], locked[toWallet.ID], locked[fxFrom.ID], locked[fxTo.ID], locked[fxPnl.ID], description, description)
+
+		transaction = model.Transaction{
+			UserID:      userID,
+			Type:        model.Debit,
+			Status:      model.TransactionCompleted,
+			Operation:   model.OperationConversion,
+			Amount:      quote.SourceAmount,
+			Currency:    quote.FromCurrency,
+			Description: description,
+		}
+
+		if err := setConversionMetadata(&transaction, quote, fxGain); err != nil {
+			return err
+		}
+
+		if err := postJournal(repos, &transaction, postings); err != nil {
+			return err
+		}
+
+		return recordEvent(repos.outboxRepo, model.EventFundsConverted, dto.FundsConvertedV1{
+			Reference:         transaction.Reference,
+			UserID:            userID.String(),
+			QuoteID:           quote.QuoteID,
+			FromAccountNumber: fromWallet.Number,
+			ToAccountNumber:   toWallet.Number,
+			FromCurrency:      quote.FromCurrency,
+			ToCurrency:        quote.ToCurrency,
+			SourceAmount:      quote.SourceAmount,
+			TargetAmount:      quote.TargetAmount,
+			Rate:              quote.Rate,
+		})
+	})
+	if err != nil {
+		return dto.TransactionDto{}, restoreQuote(s.db.Cache(), userID, quote, err)
+	}
+
+	return toTransactionDto(transaction), nil
+}
+
+// conversionPostings moves quote.SourceAmount out of from and quote.TargetAmount into to. Each currency
+// balances on its own through the fx position accounts, and the difference between the mid-market
+// value and the quoted amount is booked to fxPnl and returned as the fx gain.
+func conversionPostings(quote dto.ConversionQuoteDto, from, to, fxFrom, fxTo, fxPnl *model.Account, fromDescription, toDescription string) ([]posting, decimal.Decimal) {
+	// value of the source amount at the mid rate, the spread is what the receiver does not get
+	midAmount := quote.SourceAmount.Mul(quote.MidRate).Round(minorUnits(quote.ToCurrency))
+	fxGain := midAmount.Sub(quote.TargetAmount)
+
+	postings := []posting{
+		{account: from, entryType: model.Debit, amount: quote.SourceAmount, description: fromDescription},
+		{account: fxFrom, entryType: model.Credit, amount: quote.SourceAmount, description: fromDescription},
+		{account: fxTo, entryType: model.Debit, amount: midAmount, description: toDescription},
+		{account: to, entryType: model.Credit, amount: quote.TargetAmount, description: toDescription},
+	}
+
+	switch {
+	case fxGain.IsPositive():
+		postings = append(postings, posting{account: fxPnl, entryType: model.Credit, amount: fxGain, description: "FX gain: " + toDescription})
+	case fxGain.IsNegative():
+		postings = append(postings, posting{account: fxPnl, entryType: model.Debit, amount: fxGain.Neg(), description: "FX loss: " + toDescription})
+	}
+
+	return postings, fxGain
+}
+
+// setConversionMetadata keeps the executed quote on the transaction next to any metadata already set.
+func setConversionMetadata(transaction *model.Transaction, quote dto.ConversionQuoteDto, fxGain decimal.Decimal) error {
+	metadata, err := transactionMetadata(transaction)
+	if err != nil {
+		return err
+	}
+
+	metadata["quote_id"] = quote.QuoteID
+	metadata["from_currency"] = quote.FromCurrency
+	metadata["to_currency"] = quote.ToCurrency
+	metadata["source_amount"] = quote.SourceAmount.String()
+	metadata["target_amount"] = quote.TargetAmount.String()
+	metadata["mid_rate"] = quote.MidRate.String()
+	metadata["rate"] = quote.Rate.String()
+	metadata["spread"] = quote.Spread.String()
+	metadata["fx_gain"] = fxGain.String()
+
+	return setTransactionMetadata(transaction, metadata)
+}
+
+// fxAccounts returns the fx position accounts of both currencies of a quote and the fx_pnl account
+// of the target currency.
+func (s *walletService) fxAccounts(quote dto.ConversionQuoteDto) (*model.Account, *model.Account, *model.Account, error) {
+	fxFrom, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeFx, quote.FromCurrency)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	fxTo, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeFx, quote.ToCurrency)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	fxPnl, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeFxPnl, quote.ToCurrency)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return fxFrom, fxTo, fxPnl, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+// quoteFee prices the operation and, when a fee is due, resolves the fee account it is paid into.
+// The fee account is nil for free operations.
+func (s *walletService) quoteFee(operation string, currency string, amount decimal.Decimal) (dto.FeeDto, *model.Account, error) {
+	fee, err := s.feeService.QuoteFee(operation, currency, amount)
+	if err != nil {
+		return dto.FeeDto{}, nil, err
+	}
+
+	if !fee.Fee.IsPositive() {
+		return fee, nil, nil
+	}
+
+	feeAccount, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeFee, currency)
+	if err != nil {
+		return dto.FeeDto{}, nil, err
+	}
+
+	return fee, feeAccount, nil
+}
+
+// feeAccountIDs adds the fee account, if any, to the accounts a journal has to lock.
+func feeAccountIDs(feeAccount *model.Account, accountIDs ...uuid.UUID) []uuid.UUID {
+	if feeAccount == nil {
+		return accountIDs
+	}
+	return append(accountIDs, feeAccount.ID)
+}
+
+// feePostings moves the fee from the payer to the fee account as its own pair of ledger entries.
+func feePostings(locked map[uuid.UUID]*model.Account, payer *model.Account, feeAccount *model.Account, fee dto.FeeDto, description string) []posting {
+	if feeAccount == nil {
+		return nil
+	}
+
+	return []posting{
+		{account: payer, entryType: model.Debit, amount: fee.Fee, description: "Fee: " + description},
+		{account: locked[feeAccount.ID], entryType: model.Credit, amount: fee.Fee, description: "Fee: " + description},
+	}
+}
+
+// setFeeMetadata records the fee breakdown on the transaction, free operations are left untouched.
+func setFeeMetadata(transaction *model.Transaction, fee dto.FeeDto) error {
+	if !fee.Fee.IsPositive() {
+		return nil
+	}
+
+	metadata, err := transactionMetadata(transaction)
+	if err != nil {
+		return err
+	}
+
+	metadata["fee"] = fee
+	return setTransactionMetadata(transaction, metadata)
+}
+
+// transactionFee reads the fee breakdown stored by setFeeMetadata, nil when no fee was charged.
+func transactionFee(transaction *model.Transaction) *dto.FeeDto {
+	if len(transaction.Metadata) == 0 {
+		return nil
+	}
+
+	var metadata struct {
+		Fee *dto.FeeDto `json:"fee"`
+	}
+	if err := json.Unmarshal(transaction.Metadata, &metadata); err != nil {
+		return nil
+	}
+
+	return metadata.Fee
+}
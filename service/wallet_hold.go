@@ -0,0 +1,333 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+var (
+	// HoldDuration is how long an authorization reserves funds before it expires.
+	HoldDuration = 7 * 24 * time.Hour
+
+	// expiredHoldsBatchSize caps how many holds a single ExpireHolds run releases.
+	expiredHoldsBatchSize = 100
+)
+
+var (
+	ErrHoldNotActive      = errors.New("hold is no longer active")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds the held amount")
+)
+
+// AuthorizeHold reserves amount on the user's wallet. The ledger balance is untouched
+// until the hold is captured, only the available balance goes down.
+func (s *walletService) AuthorizeHold(userID uuid.UUID, currency string, amount decimal.Decimal, description string, idempotencyKey string) (dto.TransactionDto, error) {
+	var transaction model.Transaction
+
+	walletCurrency, err := checkAmount(currency, amount)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	wallet, err := s.accountRepo.GetWalletAccountByUserID(userID, walletCurrency.Code)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	if description == "" {
+		description = "Wallet authorization"
+	}
+
+	err = s.TxHelper(func(repos walletRepositories) error {
+
+		existing, err := idempotentTransaction(repos.transactionRepo, userID, idempotencyKey, model.OperationHold)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			transaction = *existing
+			return nil
+		}
+
+		locked, err := lockAccounts(repos.accountRepo, wallet.ID)
+		if err != nil {
+			return err
+		}
+		account := locked[wallet.ID]
+
+		if err := checkCanDebit(account); err != nil {
+			return err
+		}
+
+		if account.AvailableBalance().LessThan(amount) {
+			return ErrInsufficientBalance
+		}
+
+		// a hold is a withdrawal that has not settled yet, so it is limited like one
+		if err := s.limitService.CheckLimits(userID, model.OperationWithdrawal, account.Currency, amount); err != nil {
+			return err
+		}
+
+		if err := repos.accountRepo.UpdateAccountHeldBalance(account, amount); err != nil {
+			return err
+		}
+
+		transaction = model.Transaction{
+			UserID:         userID,
+			Type:           model.Debit,
+			Status:         model.TransactionPending,
+			Operation:      model.OperationHold,
+			Amount:         amount,
+			Currency:       account.Currency,
+			Description:    description,
+			IdempotencyKey: optionalKey(idempotencyKey),
+		}
+
+		if err := repos.transactionRepo.CreateTransaction(&transaction); err != nil {
+			return err
+		}
+
+		hold := &model.Hold{
+			UserID:         userID,
+			AccountID:      account.ID,
+			TransactionID:  transaction.ID,
+			Amount:         amount,
+			CapturedAmount: decimal.Zero,
+			Status:         model.HoldActive,
+			ExpiresAt:      time.Now().Add(HoldDuration),
+		}
+
+		if err := repos.holdRepo.CreateHold(hold); err != nil {
+			return err
+		}
+
+		return recordEvent(repos.outboxRepo, model.EventHoldAuthorized, holdEvent(&transaction, hold, account, nil))
+	})
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(transaction), nil
+}
+
+// CaptureHold settles an active hold for amount, or the full held amount when amount is zero.
+// Whatever is not captured is released back to the available balance.
+func (s *walletService) CaptureHold(userID uuid.UUID, reference string, amount decimal.Decimal) (dto.TransactionDto, error) {
+	var transaction *model.Transaction
+
+	pending, err := s.transactionRepo.GetTransactionByReference(reference)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	if pending.UserID != userID {
+		return dto.TransactionDto{}, gorm.ErrRecordNotFound
+	}
+
+	reserve, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeReserve, pending.Currency)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	err = s.TxHelper(func(repos walletRepositories) error {
+
+		transaction, err = repos.transactionRepo.GetTransactionByReferenceForUpdate(reference)
+		if err != nil {
+			return err
+		}
+
+		hold, err := activeHold(repos, transaction)
+		if err != nil {
+			return err
+		}
+
+		if hold.ExpiresAt.Before(time.Now()) {
+			return ErrHoldNotActive
+		}
+
+		captureAmount := amount
+		if captureAmount.IsZero() {
+			captureAmount = hold.Amount
+		}
+
+		if captureAmount.GreaterThan(hold.Amount) {
+			return ErrCaptureExceedsHold
+		}
+
+		if !captureAmount.Equal(captureAmount.Round(minorUnits(transaction.Currency))) {
+			return ErrAmountPrecision
+		}
+
+		locked, err := lockAccounts(repos.accountRepo, hold.AccountID, reserve.ID)
+		if err != nil {
+			return err
+		}
+
+		// Release the full hold first so the capture is checked against the freed funds
+		if err := releaseHold(repos, hold, locked[hold.AccountID], model.HoldCaptured); err != nil {
+			return err
+		}
+
+		hold.CapturedAmount = captureAmount
+		if err := repos.holdRepo.UpdateHold(hold); err != nil {
+			return err
+		}
+
+		transaction.Amount = captureAmount
+		transaction.Status = model.TransactionCompleted
+		if err := repos.transactionRepo.UpdateTransaction(transaction); err != nil {
+			return err
+		}
+
+		err = postEntries(repos, transaction, []posting{
+			{account: locked[hold.AccountID], entryType: model.Debit, amount: captureAmount, description: transaction.Description},
+			{account: locked[reserve.ID], entryType: model.Credit, amount: captureAmount, description: transaction.Description},
+		})
+		if err != nil {
+			return err
+		}
+
+		return recordEvent(repos.outboxRepo, model.EventHoldCaptured, holdEvent(transaction, hold, locked[hold.AccountID], &captureAmount))
+	})
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(*transaction), nil
+}
+
+// VoidHold releases an active hold without moving any money.
+func (s *walletService) VoidHold(userID uuid.UUID, reference string) (dto.TransactionDto, error) {
+	var transaction *model.Transaction
+
+	err := s.TxHelper(func(repos walletRepositories) error {
+		var err error
+
+		transaction, err = repos.transactionRepo.GetTransactionByReferenceForUpdate(reference)
+		if err != nil {
+			return err
+		}
+
+		if transaction.UserID != userID {
+			return gorm.ErrRecordNotFound
+		}
+
+		return voidHold(repos, transaction, model.HoldVoided)
+	})
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(*transaction), nil
+}
+
+// ExpireHolds releases holds that were neither captured nor voided before they expired.
+// Each hold is expired in its own transaction so one failure does not block the rest.
+func (s *walletService) ExpireHolds() (int, error) {
+	holds, err := s.holdRepo.GetExpiredHolds(time.Now(), expiredHoldsBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, hold := range holds {
+		err := s.TxHelper(func(repos walletRepositories) error {
+			transaction, err := repos.transactionRepo.GetTransactionByIDForUpdate(hold.TransactionID)
+			if err != nil {
+				return err
+			}
+
+			return voidHold(repos, transaction, model.HoldExpired)
+		})
+
+		if errors.Is(err, ErrHoldNotActive) {
+			// captured or voided since we listed it
+			continue
+		}
+
+		if err != nil {
+			return expired, err
+		}
+
+		expired++
+	}
+
+	return expired, nil
+}
+
+// activeHold locks the hold of a pending transaction and checks that it can still be settled.
+func activeHold(repos walletRepositories, transaction *model.Transaction) (*model.Hold, error) {
+	hold, err := repos.holdRepo.GetHoldByTransactionIDForUpdate(transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hold.Status != model.HoldActive || transaction.Status != model.TransactionPending {
+		return nil, ErrHoldNotActive
+	}
+
+	return hold, nil
+}
+
+// voidHold releases the hold of transaction with the given final status and fails the transaction.
+func voidHold(repos walletRepositories, transaction *model.Transaction, status string) error {
+	hold, err := activeHold(repos, transaction)
+	if err != nil {
+		return err
+	}
+
+	// Voiding by the user is allowed until the expiry job picks the hold up
+	if status == model.HoldExpired && hold.ExpiresAt.After(time.Now()) {
+		return ErrHoldNotActive
+	}
+
+	locked, err := lockAccounts(repos.accountRepo, hold.AccountID)
+	if err != nil {
+		return err
+	}
+
+	if err := releaseHold(repos, hold, locked[hold.AccountID], status); err != nil {
+		return err
+	}
+
+	transaction.Status = model.TransactionFailed
+	if err := repos.transactionRepo.UpdateTransaction(transaction); err != nil {
+		return err
+	}
+
+	eventType := model.EventHoldVoided
+	if status == model.HoldExpired {
+		eventType = model.EventHoldExpired
+	}
+
+	return recordEvent(repos.outboxRepo, eventType, holdEvent(transaction, hold, locked[hold.AccountID], nil))
+}
+
+// releaseHold gives the held amount back to the available balance and closes the hold.
+func releaseHold(repos walletRepositories, hold *model.Hold, account *model.Account, status string) error {
+	if err := repos.accountRepo.UpdateAccountHeldBalance(account, hold.Amount.Neg()); err != nil {
+		return err
+	}
+
+	hold.Status = status
+	return repos.holdRepo.UpdateHold(hold)
+}
+
+// holdEvent is the data of a hold event, capturedAmount is only given for a capture.
+func holdEvent(transaction *model.Transaction, hold *model.Hold, account *model.Account, capturedAmount *decimal.Decimal) dto.HoldV1 {
+	return dto.HoldV1{
+		Reference:      transaction.Reference,
+		UserID:         hold.UserID.String(),
+		AccountNumber:  account.Number,
+		Amount:         hold.Amount,
+		CapturedAmount: capturedAmount,
+		Currency:       account.Currency,
+		ExpiresAt:      hold.ExpiresAt,
+	}
+}
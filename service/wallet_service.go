@@ -1,10 +1,15 @@
 package service
 
 import (
+	"bytes"
 	"errors"
+	"slices"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
 	"github.com/horlakz/wallet-sync.api/lib/database"
 	"github.com/horlakz/wallet-sync.api/model"
 	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
@@ -13,255 +18,767 @@ import (
 )
 
 type WalletServiceInterface interface {
-	FundWallet(userID uuid.UUID, amount decimal.Decimal) error
-	WithdrawFromWallet(userID uuid.UUID, amount decimal.Decimal) error
-	GetWalletDetails(userID uuid.UUID) (dto.WalletDetailsDto, error)
-	TransferFunds(fromUserID uuid.UUID, toAccountNumber string, amount decimal.Decimal) error
+	FundWallet(userID uuid.UUID, currency string, amount decimal.Decimal, idempotencyKey string) (dto.TransactionDto, error)
+	WithdrawFromWallet(userID uuid.UUID, currency string, amount decimal.Decimal, idempotencyKey string) (dto.TransactionDto, error)
+	GetWalletDetails(userID uuid.UUID) ([]dto.WalletDetailsDto, error)
+	OpenWallet(userID uuid.UUID, currency string) (dto.WalletDetailsDto, error)
+	TransferFunds(fromUserID uuid.UUID, toAccountNumber string, currency string, amount decimal.Decimal, quoteID string, idempotencyKey string) (dto.TransactionDto, error)
+	ReverseTransaction(actorID uuid.UUID, reference string, reason string, amount decimal.Decimal, client dto.ClientInfo) (dto.TransactionDto, error)
+	AuthorizeHold(userID uuid.UUID, currency string, amount decimal.Decimal, description string, idempotencyKey string) (dto.TransactionDto, error)
+	CaptureHold(userID uuid.UUID, reference string, amount decimal.Decimal) (dto.TransactionDto, error)
+	VoidHold(userID uuid.UUID, reference string) (dto.TransactionDto, error)
+	ExpireHolds() (int, error)
+	ConvertFunds(userID uuid.UUID, quoteID string) (dto.TransactionDto, error)
+	ChangeAccountStatus(actorID uuid.UUID, accountID uuid.UUID, status string, reason string) (dto.WalletDetailsDto, error)
+	CloseAccount(actorID uuid.UUID, accountID uuid.UUID, reason string, sweepToAccountNumber string) (dto.AccountClosureDto, error)
+	GetAccountStatusHistory(accountID uuid.UUID) ([]model.AccountStatusChange, error)
 }
 
+var (
+	ErrWalletExists             = errors.New("a wallet in this currency already exists")
+	ErrTransactionNotReversible = errors.New("only completed transactions can be reversed")
+	ErrTransactionFullyReversed = errors.New("transaction has already been fully reversed")
+	ErrReversalExceedsOriginal  = errors.New("reversal amount exceeds the amount left to reverse")
+)
+
+// maxTxAttempts is how many times a wallet transaction is attempted before a concurrency error is returned.
+const maxTxAttempts = 3
+
 type walletService struct {
 	accountRepo     core_repository.AccountRepository
 	transactionRepo core_repository.TransactionRepository
 	ledgerEntryRepo core_repository.LedgerEntryRepository
+	holdRepo        core_repository.HoldRepository
+	webhookRepo     core_repository.WebhookRepository
+	outboxRepo      core_repository.OutboxRepository
+	feeService      FeeServiceInterface
+	limitService    LimitServiceInterface
+	auditService    AuditServiceInterface
 	db              database.DatabaseInterface
 }
 
+// walletRepositories are the repositories TxHelper binds to the running DB transaction.
+type walletRepositories struct {
+	accountRepo     core_repository.AccountRepository
+	transactionRepo core_repository.TransactionRepository
+	ledgerEntryRepo core_repository.LedgerEntryRepository
+	holdRepo        core_repository.HoldRepository
+	webhookRepo     core_repository.WebhookRepository
+	outboxRepo      core_repository.OutboxRepository
+}
+
 func NewWalletService(
 	accountRepo core_repository.AccountRepository,
 	transactionRepo core_repository.TransactionRepository,
 	ledgerEntryRepo core_repository.LedgerEntryRepository,
+	holdRepo core_repository.HoldRepository,
+	webhookRepo core_repository.WebhookRepository,
+	outboxRepo core_repository.OutboxRepository,
+	feeService FeeServiceInterface,
+	limitService LimitServiceInterface,
+	auditService AuditServiceInterface,
 	db database.DatabaseInterface,
 ) WalletServiceInterface {
 	return &walletService{
 		accountRepo:     accountRepo,
 		transactionRepo: transactionRepo,
 		ledgerEntryRepo: ledgerEntryRepo,
+		holdRepo:        holdRepo,
+		webhookRepo:     webhookRepo,
+		outboxRepo:      outboxRepo,
+		feeService:      feeService,
+		limitService:    limitService,
+		auditService:    auditService,
 		db:              db,
 	}
 }
 
-func (s *walletService) FundWallet(userID uuid.UUID, amount decimal.Decimal) error { // use a transaction and rollback if any step fails
-	return s.TxHelper(func(
-		accountRepo core_repository.AccountRepository,
-		transactionRepo core_repository.TransactionRepository,
-		ledgerEntryRepo core_repository.LedgerEntryRepository,
-	) error {
+func (s *walletService) FundWallet(userID uuid.UUID, currency string, amount decimal.Decimal, idempotencyKey string) (dto.TransactionDto, error) { // use a transaction and rollback if any step fails
+	var transaction model.Transaction
+
+	walletCurrency, err := checkAmount(currency, amount)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	wallet, err := s.accountRepo.GetWalletAccountByUserID(userID, walletCurrency.Code)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	// Funding moves money in from the reserve account
+	reserve, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeReserve, wallet.Currency)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	err = s.TxHelper(func(repos walletRepositories) error {
+
+		existing, err := idempotentTransaction(repos.transactionRepo, userID, idempotencyKey, model.OperationFunding)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			transaction = *existing
+			return nil
+		}
 
-		account, err := accountRepo.GetAccountByUserID(userID)
+		locked, err := lockAccounts(repos.accountRepo, wallet.ID, reserve.ID)
 		if err != nil {
 			return err
 		}
 
-		// Update account balance
-		if err := accountRepo.UpdateAccountBalance(userID, amount); err != nil {
+		if err := s.limitService.CheckLimits(userID, model.OperationFunding, wallet.Currency, amount); err != nil {
 			return err
 		}
 
 		// Create a transaction record
-		transaction := model.Transaction{
-			UserID:      *account.UserID,
-			Type:        model.Credit,
-			Status:      model.TransactionCompleted,
-			Amount:      amount,
-			Currency:    "NGN",
-			Description: "Wallet funding",
+		transaction = model.Transaction{
+			UserID:         userID,
+			Type:           model.Credit,
+			Status:         model.TransactionCompleted,
+			Operation:      model.OperationFunding,
+			Amount:         amount,
+			Currency:       wallet.Currency,
+			Description:    "Wallet funding",
+			IdempotencyKey: optionalKey(idempotencyKey),
 		}
 
-		if err := transactionRepo.CreateTransaction(&transaction); err != nil {
+		err = postJournal(repos, &transaction, []posting{
+			{account: locked[reserve.ID], entryType: model.Debit, amount: amount, description: "Wallet funding"},
+			{account: locked[wallet.ID], entryType: model.Credit, amount: amount, description: "Wallet funding"},
+		})
+		if err != nil {
 			return err
 		}
 
-		// Create a ledger entry
-		ledgerEntry := &model.LedgerEntry{
-			UserID:        userID,
-			AccountID:     account.ID,
-			TransactionID: transaction.ID,
-			EntryType:     "credit",
+		err = recordEvent(repos.outboxRepo, model.EventWalletFunded, dto.WalletFundedV1{
+			Reference:     transaction.Reference,
+			UserID:        userID.String(),
+			AccountNumber: wallet.Number,
 			Amount:        amount,
-			Description:   "Wallet funding",
-		}
-
-		if err := ledgerEntryRepo.CreateLedgerEntry(ledgerEntry); err != nil {
+			Currency:      wallet.Currency,
+		})
+		if err != nil {
 			return err
 		}
 
-		return nil
+		return enqueueWebhook(repos.webhookRepo, userID, model.WebhookEventWalletFunded, dto.WalletEventDataDto{
+			Transaction:   toTransactionDto(transaction),
+			AccountNumber: wallet.Number,
+		})
 	})
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(transaction), nil
 }
 
-func (s *walletService) WithdrawFromWallet(userID uuid.UUID, amount decimal.Decimal) error {
-	return s.TxHelper(func(
-		accountRepo core_repository.AccountRepository,
-		transactionRepo core_repository.TransactionRepository,
-		ledgerEntryRepo core_repository.LedgerEntryRepository,
-	) error {
+func (s *walletService) WithdrawFromWallet(userID uuid.UUID, currency string, amount decimal.Decimal, idempotencyKey string) (dto.TransactionDto, error) {
+	var transaction model.Transaction
+
+	walletCurrency, err := checkAmount(currency, amount)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	wallet, err := s.accountRepo.GetWalletAccountByUserID(userID, walletCurrency.Code)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	// Withdrawals pay out through the reserve account
+	reserve, err := s.accountRepo.GetOrCreateSystemAccount(model.AccountTypeReserve, wallet.Currency)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	fee, feeAccount, err := s.quoteFee(model.OperationWithdrawal, wallet.Currency, amount)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
 
-		account, err := accountRepo.GetAccountByUserID(userID)
+	err = s.TxHelper(func(repos walletRepositories) error {
+
+		existing, err := idempotentTransaction(repos.transactionRepo, userID, idempotencyKey, model.OperationWithdrawal)
 		if err != nil {
 			return err
 		}
+		if existing != nil {
+			transaction = *existing
+			return nil
+		}
 
-		// Check if the account has sufficient balance
-		if account.Balance.LessThan(amount) {
-			return errors.New("insufficient balance")
+		// Lock the wallet so concurrent withdrawals see each other's debits
+		locked, err := lockAccounts(repos.accountRepo, feeAccountIDs(feeAccount, wallet.ID, reserve.ID)...)
+		if err != nil {
+			return err
 		}
 
-		// Update account balance
-		if err := accountRepo.UpdateAccountBalance(userID, amount.Neg()); err != nil {
+		if err := s.limitService.CheckLimits(userID, model.OperationWithdrawal, wallet.Currency, amount); err != nil {
 			return err
 		}
 
 		// Create a transaction record
-		transaction := &model.Transaction{
-			UserID:      *account.UserID,
-			Type:        model.Debit,
-			Status:      model.TransactionCompleted,
-			Amount:      amount,
-			Currency:    "NGN",
-			Description: "Wallet withdrawal",
+		transaction = model.Transaction{
+			UserID:         userID,
+			Type:           model.Debit,
+			Status:         model.TransactionCompleted,
+			Operation:      model.OperationWithdrawal,
+			Amount:         amount,
+			Currency:       wallet.Currency,
+			Description:    "Wallet withdrawal",
+			IdempotencyKey: optionalKey(idempotencyKey),
 		}
 
-		if err := transactionRepo.CreateTransaction(transaction); err != nil {
+		if err := setFeeMetadata(&transaction, fee); err != nil {
 			return err
 		}
 
-		// Create a ledger entry
-		ledgerEntry := &model.LedgerEntry{
-			UserID:        userID,
-			AccountID:     account.ID,
-			TransactionID: transaction.ID,
-			EntryType:     "debit",
-			Amount:        amount,
-			Description:   "Wallet withdrawal",
+		postings := []posting{
+			{account: locked[wallet.ID], entryType: model.Debit, amount: amount, description: "Wallet withdrawal"},
+			{account: locked[reserve.ID], entryType: model.Credit, amount: amount, description: "Wallet withdrawal"},
 		}
 
-		if err := ledgerEntryRepo.CreateLedgerEntry(ledgerEntry); err != nil {
+		if err := postJournal(repos, &transaction, append(postings, feePostings(locked, locked[wallet.ID], feeAccount, fee, "Wallet withdrawal")...)); err != nil {
+			return err
+		}
+
+		err = recordEvent(repos.outboxRepo, model.EventWalletWithdrawn, dto.WalletWithdrawnV1{
+			Reference:     transaction.Reference,
+			UserID:        userID.String(),
+			AccountNumber: wallet.Number,
+			Amount:        amount,
+			Fee:           fee.Fee,
+			Currency:      wallet.Currency,
+		})
+		if err != nil {
 			return err
 		}
 
-		return nil
+		return enqueueWebhook(repos.webhookRepo, userID, model.WebhookEventWalletWithdrawn, dto.WalletEventDataDto{
+			Transaction:   toTransactionDto(transaction),
+			AccountNumber: wallet.Number,
+		})
 	})
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(transaction), nil
+}
+
+func (s *walletService) GetWalletDetails(userID uuid.UUID) ([]dto.WalletDetailsDto, error) {
+	accounts, err := s.accountRepo.GetWalletAccountsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
 
+	wallets := make([]dto.WalletDetailsDto, 0, len(accounts))
+	for _, account := range accounts {
+		wallets = append(wallets, toWalletDetailsDto(account))
+	}
+
+	return wallets, nil
 }
 
-func (s *walletService) GetWalletDetails(userID uuid.UUID) (dto.WalletDetailsDto, error) {
-	account, err := s.accountRepo.GetWalletAccountByUserID(userID)
+// OpenWallet creates an additional wallet for the user in the given currency.
+func (s *walletService) OpenWallet(userID uuid.UUID, currency string) (dto.WalletDetailsDto, error) {
+	walletCurrency, err := resolveCurrency(currency)
 	if err != nil {
 		return dto.WalletDetailsDto{}, err
 	}
 
-	return dto.WalletDetailsDto{
-		Balance:       account.Balance,
-		AccountNumber: account.Number,
-	}, nil
+	if existing, _ := s.accountRepo.GetWalletAccountByUserID(userID, walletCurrency.Code); existing != nil {
+		return dto.WalletDetailsDto{}, ErrWalletExists
+	}
+
+	account := &model.Account{
+		UserID:      &userID,
+		AccountType: model.AccountTypeWallet,
+		Currency:    walletCurrency.Code,
+		Balance:     decimal.Zero,
+		HeldBalance: decimal.Zero,
+		Number:      helper.GenerateAccountNumber(),
+		Status:      model.AccountStatusActive,
+	}
+
+	if err := s.accountRepo.CreateAccount(account); err != nil {
+		return dto.WalletDetailsDto{}, err
+	}
+
+	return toWalletDetailsDto(account), nil
 }
 
-func (s *walletService) TransferFunds(fromUserID uuid.UUID, toAccountNumber string, amount decimal.Decimal) error {
+// TransferFunds moves amount from the sender's wallet in currency to another user's wallet. A wallet
+// in another currency is paid through quoteID, a quote from FxServiceInterface.Quote for exactly
+// amount into the recipient's currency. The quote is used up only if the transfer goes through.
+func (s *walletService) TransferFunds(fromUserID uuid.UUID, toAccountNumber string, currency string, amount decimal.Decimal, quoteID string, idempotencyKey string) (dto.TransactionDto, error) {
+	var transaction model.Transaction
+
+	walletCurrency, err := checkAmount(currency, amount)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	fee, feeAccount, err := s.quoteFee(model.OperationTransfer, walletCurrency.Code, amount)
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
 
-	return s.TxHelper(func(
-		accountRepo core_repository.AccountRepository,
-		transactionRepo core_repository.TransactionRepository,
-		ledgerEntryRepo core_repository.LedgerEntryRepository,
-	) error {
+	var quote *dto.ConversionQuoteDto
+	var fxFrom, fxTo, fxPnl *model.Account
+	if quoteID != "" {
+		// a retry must find the transfer before it tries the quote the transfer consumed
+		existing, err := idempotentTransaction(s.transactionRepo, fromUserID, idempotencyKey, model.OperationTransfer)
+		if err != nil {
+			return dto.TransactionDto{}, err
+		}
+		if existing != nil {
+			return toTransactionDto(*existing), nil
+		}
 
-		fromAccount, err := accountRepo.GetWalletAccountByUserID(fromUserID)
+		consumed, err := consumeQuote(s.db.Cache(), fromUserID, quoteID)
+		if err != nil {
+			return dto.TransactionDto{}, err
+		}
+		if consumed.FromCurrency != walletCurrency.Code || !consumed.SourceAmount.Equal(amount) {
+			return dto.TransactionDto{}, restoreQuote(s.db.Cache(), fromUserID, consumed, ErrQuoteMismatch)
+		}
+		quote = &consumed
+
+		fxFrom, fxTo, fxPnl, err = s.fxAccounts(consumed)
+		if err != nil {
+			return dto.TransactionDto{}, restoreQuote(s.db.Cache(), fromUserID, consumed, err)
+		}
+	}
+
+	err = s.TxHelper(func(repos walletRepositories) error {
+
+		existing, err := idempotentTransaction(repos.transactionRepo, fromUserID, idempotencyKey, model.OperationTransfer)
 		if err != nil {
 			return err
 		}
+		if existing != nil {
+			transaction = *existing
+			return nil
+		}
 
-		toAccount, err := accountRepo.GetAccountByNumber(toAccountNumber)
+		fromAccount, err := repos.accountRepo.GetWalletAccountByUserID(fromUserID, walletCurrency.Code)
 		if err != nil {
 			return err
 		}
 
+		toAccount, err := repos.accountRepo.GetAccountByNumber(toAccountNumber)
+		if err != nil {
+			return err
+		}
+
+		// only user wallets can receive transfers
+		if toAccount.UserID == nil || toAccount.AccountType != model.AccountTypeWallet {
+			return errors.New("invalid destination account")
+		}
+
 		// check if wallet belongs to self
 		if *toAccount.UserID == fromUserID {
 			return errors.New("cannot transfer to your own account")
 		}
 
-		// Check if the from account has sufficient balance
-		if fromAccount.Balance.LessThan(amount) {
-			return errors.New("insufficient balance")
+		// cross-currency transfers need a quote into the recipient's currency
+		if quote == nil && toAccount.Currency != fromAccount.Currency {
+			return ErrCurrencyMismatch
+		}
+		if quote != nil && quote.ToCurrency != toAccount.Currency {
+			return ErrQuoteMismatch
+		}
+
+		accountIDs := []uuid.UUID{fromAccount.ID, toAccount.ID}
+		if quote != nil {
+			accountIDs = append(accountIDs, fxFrom.ID, fxTo.ID, fxPnl.ID)
 		}
 
-		// Update the balances of both accounts
-		if err := accountRepo.UpdateAccountBalance(*fromAccount.UserID, amount.Neg()); err != nil {
+		// Lock both accounts in a deterministic order to avoid deadlocks with a reverse transfer
+		locked, err := lockAccounts(repos.accountRepo, feeAccountIDs(feeAccount, accountIDs...)...)
+		if err != nil {
 			return err
 		}
-		if err := accountRepo.UpdateAccountBalance(*toAccount.UserID, amount); err != nil {
+		fromAccount, toAccount = locked[fromAccount.ID], locked[toAccount.ID]
+
+		if err := s.limitService.CheckLimits(fromUserID, model.OperationTransfer, fromAccount.Currency, amount); err != nil {
 			return err
 		}
 
-		// Create a transaction record for the sender
-		senderTransaction := &model.Transaction{
-			UserID:      *fromAccount.UserID,
-			Type:        model.Debit,
-			Status:      model.TransactionCompleted,
-			Amount:      amount,
-			Currency:    "NGN",
-			Description: "Transfer to " + toAccount.Number,
+		// One journal transaction for both sides of the transfer
+		transaction = model.Transaction{
+			UserID:         fromUserID,
+			Type:           model.Debit,
+			Status:         model.TransactionCompleted,
+			Operation:      model.OperationTransfer,
+			Amount:         amount,
+			Currency:       fromAccount.Currency,
+			Description:    "Transfer to " + toAccount.Number,
+			IdempotencyKey: optionalKey(idempotencyKey),
 		}
 
-		if err := transactionRepo.CreateTransaction(senderTransaction); err != nil {
+		if err := setFeeMetadata(&transaction, fee); err != nil {
 			return err
 		}
 
-		// Create a ledger entry for the sender
-		senderLedgerEntry := &model.LedgerEntry{
-			UserID:        *fromAccount.UserID,
-			AccountID:     fromAccount.ID,
-			TransactionID: senderTransaction.ID,
-			EntryType:     "debit",
-			Amount:        amount,
-			Description:   "Transfer to " + toAccount.Number,
+		postings := []posting{
+			{account: fromAccount, entryType: model.Debit, amount: amount, description: "Transfer to " + toAccount.Number},
+			{account: toAccount, entryType: model.Credit, amount: amount, description: "Transfer from " + fromAccount.Number},
+		}
+		receivedAmount, receivedCurrency := amount, fromAccount.Currency
+
+		if quote != nil {
+			var fxGain decimal.Decimal
+			postings, fxGain = conversionPostings(*quote, fromAccount, toAccount, locked[fxFrom.ID], locked[fxTo.ID], locked[fxPnl.ID],
+				"Transfer to "+toAccount.Number, "Transfer from "+fromAccount.Number)
+			receivedAmount, receivedCurrency = quote.TargetAmount, quote.ToCurrency
+
+			if err := setConversionMetadata(&transaction, *quote, fxGain); err != nil {
+				return err
+			}
 		}
 
-		if err := ledgerEntryRepo.CreateLedgerEntry(senderLedgerEntry); err != nil {
+		if err := postJournal(repos, &transaction, append(postings, feePostings(locked, fromAccount, feeAccount, fee, "Transfer to "+toAccount.Number)...)); err != nil {
 			return err
 		}
 
-		// Create a transaction record for the receiver
-		receiverTransaction := &model.Transaction{
-			UserID:      *toAccount.UserID,
-			Type:        model.Credit,
+		err = recordEvent(repos.outboxRepo, model.EventTransferCompleted, dto.TransferCompletedV1{
+			Reference:         transaction.Reference,
+			FromUserID:        fromUserID.String(),
+			FromAccountNumber: fromAccount.Number,
+			ToUserID:          toAccount.UserID.String(),
+			ToAccountNumber:   toAccount.Number,
+			Amount:            amount,
+			Fee:               fee.Fee,
+			Currency:          fromAccount.Currency,
+			ReceivedAmount:    receivedAmount,
+			ReceivedCurrency:  receivedCurrency,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = enqueueWebhook(repos.webhookRepo, fromUserID, model.WebhookEventTransferSent, dto.WalletEventDataDto{
+			Transaction:               toTransactionDto(transaction),
+			AccountNumber:             fromAccount.Number,
+			CounterpartyAccountNumber: toAccount.Number,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the recipient sees a credit of what they received, without the sender's fee
+		received := toTransactionDto(transaction)
+		received.Type = string(model.Credit)
+		received.Amount = receivedAmount
+		received.Currency = receivedCurrency
+		received.Description = "Transfer from " + fromAccount.Number
+		received.Fee = nil
+
+		return enqueueWebhook(repos.webhookRepo, *toAccount.UserID, model.WebhookEventTransferReceived, dto.WalletEventDataDto{
+			Transaction:               received,
+			AccountNumber:             toAccount.Number,
+			CounterpartyAccountNumber: fromAccount.Number,
+		})
+	})
+	if err != nil {
+		// a quote is only used up by a transfer that went through
+		if quote != nil {
+			err = restoreQuote(s.db.Cache(), fromUserID, *quote, err)
+		}
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(transaction), nil
+}
+
+// ReverseTransaction posts compensating entries for a completed transaction. A zero amount reverses
+// whatever is left of the original; a smaller amount is a partial refund and reverses every posting
+// of the original proportionally. The running total is kept in the original's metadata so that a
+// transaction can never be reversed for more than its amount. The staff member who reversed it,
+// actorID, is kept with the reason in the reversal's metadata and in the audit log.
+func (s *walletService) ReverseTransaction(actorID uuid.UUID, reference string, reason string, amount decimal.Decimal, client dto.ClientInfo) (dto.TransactionDto, error) {
+	var reversal model.Transaction
+
+	err := s.TxHelper(func(repos walletRepositories) error {
+
+		// Lock the original so concurrent refunds are applied one after the other
+		original, err := repos.transactionRepo.GetTransactionByReferenceForUpdate(reference)
+		if err != nil {
+			return err
+		}
+
+		if original.Status != model.TransactionCompleted || original.Operation == model.OperationReversal {
+			return ErrTransactionNotReversible
+		}
+
+		metadata, err := transactionMetadata(original)
+		if err != nil {
+			return err
+		}
+
+		reversedAmount, err := metadataDecimal(metadata, "reversed_amount")
+		if err != nil {
+			return err
+		}
+
+		remaining := original.Amount.Sub(reversedAmount)
+		if !remaining.IsPositive() {
+			return ErrTransactionFullyReversed
+		}
+
+		if amount.IsZero() {
+			amount = remaining
+		}
+
+		if amount.IsNegative() || amount.GreaterThan(remaining) {
+			return ErrReversalExceedsOriginal
+		}
+
+		if !amount.Equal(amount.Round(minorUnits(original.Currency))) {
+			return ErrAmountPrecision
+		}
+
+		entries, err := repos.ledgerEntryRepo.GetLedgerEntriesByTransactionID(original.ID)
+		if err != nil {
+			return err
+		}
+
+		accountIDs := make([]uuid.UUID, 0, len(entries))
+		for _, entry := range entries {
+			accountIDs = append(accountIDs, entry.AccountID)
+		}
+
+		locked, err := lockAccounts(repos.accountRepo, accountIDs...)
+		if err != nil {
+			return err
+		}
+
+		// Swap the side of every posting, scaled down for partial refunds
+		ratio := amount.Div(original.Amount)
+		postings := make([]posting, 0, len(entries))
+		for _, entry := range entries {
+			entryType := model.Credit
+			if entry.EntryType == string(model.Credit) {
+				entryType = model.Debit
+			}
+
+			account := locked[entry.AccountID]
+
+			// each leg is rounded in its own account's currency, a conversion has legs in two
+			entryAmount := entry.Amount
+			if !amount.Equal(original.Amount) {
+				entryAmount = entry.Amount.Mul(ratio).Round(minorUnits(account.Currency))
+			}
+
+			postings = append(postings, posting{
+				account:     account,
+				entryType:   entryType,
+				amount:      entryAmount,
+				description: "Reversal: " + entry.Description,
+			})
+		}
+
+		postings = balanceRounding(postings)
+
+		reversalType := model.Credit
+		if original.Type == model.Credit {
+			reversalType = model.Debit
+		}
+
+		reversal = model.Transaction{
+			UserID:      original.UserID,
+			Type:        reversalType,
 			Status:      model.TransactionCompleted,
+			Operation:   model.OperationReversal,
 			Amount:      amount,
-			Currency:    "NGN",
-			Description: "Transfer from " + fromAccount.Number,
+			Currency:    original.Currency,
+			Description: "Reversal of " + original.Reference,
 		}
 
-		if err := transactionRepo.CreateTransaction(receiverTransaction); err != nil {
+		if err := setTransactionMetadata(&reversal, map[string]interface{}{
+			"reversal_of": original.Reference,
+			"reason":      reason,
+			"actor_id":    actorID,
+		}); err != nil {
 			return err
 		}
 
-		// Create a ledger entry for the receiver
-		receiverLedgerEntry := &model.LedgerEntry{
-			UserID:        *toAccount.UserID,
-			AccountID:     toAccount.ID,
-			TransactionID: receiverTransaction.ID,
-			EntryType:     "credit",
-			Amount:        amount,
-			Description:   "Transfer from " + fromAccount.Number,
+		if err := postJournal(repos, &reversal, postings); err != nil {
+			return err
+		}
+
+		// Link the reversal back to the original
+		reversals, _ := metadata["reversals"].([]interface{})
+		metadata["reversals"] = append(reversals, reversal.Reference)
+		metadata["reversed_amount"] = reversedAmount.Add(amount).String()
+
+		if err := setTransactionMetadata(original, metadata); err != nil {
+			return err
 		}
 
-		if err := ledgerEntryRepo.CreateLedgerEntry(receiverLedgerEntry); err != nil {
+		if err := repos.transactionRepo.UpdateTransaction(original); err != nil {
+			return err
+		}
+
+		err = recordEvent(repos.outboxRepo, model.EventTransactionReversed, dto.TransactionReversedV1{
+			Reference:         reversal.Reference,
+			OriginalReference: original.Reference,
+			UserID:            original.UserID.String(),
+			Amount:            amount,
+			RemainingAmount:   remaining.Sub(amount),
+			Currency:          original.Currency,
+			Reason:            reason,
+		})
+		if err != nil {
 			return err
 		}
 
-		return nil
+		return enqueueReversalWebhooks(repos, &reversal, original.Reference, reason, postings)
 	})
+	if err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	s.auditService.Record(&reversal.UserID, model.AuditActionTransactionReversed, client, map[string]interface{}{
+		"actor_id":    actorID,
+		"reference":   reversal.Reference,
+		"reversal_of": reference,
+		"amount":      reversal.Amount.String(),
+		"reason":      reason,
+	})
+
+	return toTransactionDto(reversal), nil
+}
+
+// enqueueReversalWebhooks tells the owner of every wallet a reversal moved money in or out of,
+// with the net amount the wallet gained or lost.
+func enqueueReversalWebhooks(repos walletRepositories, reversal *model.Transaction, originalReference string, reason string, postings []posting) error {
+	wallets := make([]*model.Account, 0, len(postings))
+	net := make(map[uuid.UUID]decimal.Decimal)
+
+	for _, p := range postings {
+		if p.account.UserID == nil || p.account.AccountType != model.AccountTypeWallet {
+			continue
+		}
+		if _, seen := net[p.account.ID]; !seen {
+			wallets = append(wallets, p.account)
+		}
+		net[p.account.ID] = net[p.account.ID].Add(p.signedAmount())
+	}
+
+	for _, wallet := range wallets {
+		transaction := toTransactionDto(*reversal)
+		transaction.Type = string(model.Credit)
+		transaction.Amount = net[wallet.ID]
+		if transaction.Amount.IsNegative() {
+			transaction.Type = string(model.Debit)
+			transaction.Amount = transaction.Amount.Neg()
+		}
+
+		err := enqueueWebhook(repos.webhookRepo, *wallet.UserID, model.WebhookEventTransactionReversed, dto.WalletEventDataDto{
+			Transaction:   transaction,
+			AccountNumber: wallet.Number,
+			ReversalOf:    originalReference,
+			Reason:        reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // TxHelper wraps a function in a DB transaction and injects repository instances with the transaction context.
-func (s *walletService) TxHelper(fn func(
-	accountRepo core_repository.AccountRepository,
-	transactionRepo core_repository.TransactionRepository,
-	ledgerEntryRepo core_repository.LedgerEntryRepository,
-) error) error {
-	return s.db.Connection().Transaction(func(tx *gorm.DB) error {
-		accountRepoTx := s.accountRepo.WithTx(tx)
-		transactionRepoTx := s.transactionRepo.WithTx(tx)
-		ledgerEntryRepoTx := s.ledgerEntryRepo.WithTx(tx)
-		return fn(accountRepoTx, transactionRepoTx, ledgerEntryRepoTx)
+// The whole transaction is retried when it loses a race on an account (stale version, deadlock or lock timeout),
+// so fn must not have side effects outside the transaction.
+func (s *walletService) TxHelper(fn func(repos walletRepositories) error) error {
+	var err error
+
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = s.db.Connection().Transaction(func(tx *gorm.DB) error {
+			return fn(walletRepositories{
+				accountRepo:     s.accountRepo.WithTx(tx),
+				transactionRepo: s.transactionRepo.WithTx(tx),
+				ledgerEntryRepo: s.ledgerEntryRepo.WithTx(tx),
+				holdRepo:        s.holdRepo.WithTx(tx),
+				webhookRepo:     s.webhookRepo.WithTx(tx),
+				outboxRepo:      s.outboxRepo.WithTx(tx),
+			})
+		})
+
+		if !isRetryableTxError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// lockAccounts reads the given accounts with SELECT ... FOR UPDATE ordered by ID,
+// so that two transactions touching the same accounts always lock them in the same order.
+func lockAccounts(accountRepo core_repository.AccountRepository, accountIDs ...uuid.UUID) (map[uuid.UUID]*model.Account, error) {
+	ids := slices.Clone(accountIDs)
+	slices.SortFunc(ids, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
 	})
+	ids = slices.Compact(ids)
+
+	accounts := make(map[uuid.UUID]*model.Account, len(ids))
+	for _, id := range ids {
+		account, err := accountRepo.GetAccountByIDForUpdate(id)
+		if err != nil {
+			return nil, err
+		}
+		accounts[id] = account
+	}
+
+	return accounts, nil
+}
+
+func isRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, core_repository.ErrStaleAccount) {
+		return true
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// 1213: deadlock found, 1205: lock wait timeout exceeded
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	return false
+}
+
+func toWalletDetailsDto(account *model.Account) dto.WalletDetailsDto {
+	return dto.WalletDetailsDto{
+		Currency:         account.Currency,
+		LedgerBalance:    account.Balance,
+		AvailableBalance: account.AvailableBalance(),
+		AccountNumber:    account.Number,
+		Status:           account.Status,
+	}
+}
+
+func toTransactionDto(transaction model.Transaction) dto.TransactionDto {
+	return dto.TransactionDto{
+		Reference:   transaction.Reference,
+		Type:        string(transaction.Type),
+		Status:      transaction.Status,
+		Amount:      transaction.Amount,
+		Currency:    transaction.Currency,
+		Description: transaction.Description,
+		Fee:         transactionFee(&transaction),
+		CreatedAt:   transaction.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   transaction.UpdatedAt.Format(time.RFC3339),
+	}
 }
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/constants"
+	"github.com/horlakz/wallet-sync.api/lib/database"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+	user_repository "github.com/horlakz/wallet-sync.api/repository/user"
+)
+
+// testDatabase connects to the MySQL database in TEST_DATABASE_DSN and applies the migrations.
+// Tests that need row locks skip when it is not set.
+func testDatabase(t *testing.T) database.DatabaseInterface {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	conn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+
+	db := database.Wrap(conn)
+	database.MigrationDir = "../migrations"
+	database.Migrate(db)
+
+	return db
+}
+
+// cachedDatabase is a test database with its cache kept in memory.
+type cachedDatabase struct {
+	database.DatabaseInterface
+
+	cache database.RedisClientInterface
+}
+
+func (d cachedDatabase) Cache() database.RedisClientInterface {
+	return d.cache
+}
+
+func testUser(t *testing.T, db database.DatabaseInterface) (*model.User, *model.Account) {
+	t.Helper()
+
+	user := &model.User{Name: "Test User", Email: uuid.NewString() + "@example.com", Password: "unused"}
+	if err := db.Connection().Create(user).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	wallet, err := core_repository.NewAccountRepository(db).GetWalletAccountByUserID(user.ID, constants.DEFAULT_CURRENCY)
+	if err != nil {
+		t.Fatalf("reading wallet: %v", err)
+	}
+
+	return user, wallet
+}
+
+func newTestWalletService(db database.DatabaseInterface) WalletServiceInterface {
+	transactionRepo := core_repository.NewTransactionRepository(db)
+
+	return NewWalletService(
+		core_repository.NewAccountRepository(db),
+		transactionRepo,
+		core_repository.NewLedgerEntryRepository(db),
+		core_repository.NewHoldRepository(db),
+		core_repository.NewWebhookRepository(db),
+		core_repository.NewOutboxRepository(db),
+		NewFeeService(core_repository.NewFeeRuleRepository(db)),
+		NewLimitService(core_repository.NewLimitRuleRepository(db), transactionRepo, user_repository.NewUserRepository(db)),
+		NewAuditService(core_repository.NewAuditLogRepository(db)),
+		db,
+	)
+}
+
+// debited is what a withdrawal or transfer took from the wallet, the amount and any fee.
+func debited(transaction dto.TransactionDto) decimal.Decimal {
+	if transaction.Fee != nil {
+		return transaction.Fee.Total
+	}
+	return transaction.Amount
+}
+
+func TestWalletConcurrentOperations(t *testing.T) {
+	db := testDatabase(t)
+	walletService := newTestWalletService(db)
+	accountRepo := core_repository.NewAccountRepository(db)
+
+	sender, wallet := testUser(t, db)
+	_, recipientWallet := testUser(t, db)
+
+	opening := decimal.NewFromInt(500)
+	if _, err := walletService.FundWallet(sender.ID, wallet.Currency, opening, ""); err != nil {
+		t.Fatalf("opening deposit: %v", err)
+	}
+
+	const rounds = 20
+	var (
+		mu          sync.Mutex
+		expected    = opening
+		transferred = decimal.Zero
+		wg          sync.WaitGroup
+	)
+
+	// record applies the outcome of one operation. A race may fail it with insufficient balance, or
+	// with a deadlock or stale balance that outlasted maxTxAttempts; either way nothing was posted.
+	record := func(name string, change decimal.Decimal, err error) {
+		if errors.Is(err, ErrInsufficientBalance) || isRetryableTxError(err) {
+			return
+		}
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		mu.Lock()
+		expected = expected.Add(change)
+		mu.Unlock()
+	}
+
+	// watch the balance while the operations run, it must never be seen below zero
+	var negative atomic.Bool
+	done := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			account, err := accountRepo.GetAccountByID(wallet.ID)
+			if err == nil && account.Balance.IsNegative() {
+				negative.Store(true)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for i := 0; i < rounds; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+			amount := decimal.NewFromInt(10)
+			_, err := walletService.FundWallet(sender.ID, wallet.Currency, amount, "")
+			record("fund", amount, err)
+		}()
+
+		go func() {
+			defer wg.Done()
+			transaction, err := walletService.WithdrawFromWallet(sender.ID, wallet.Currency, decimal.NewFromInt(30), "")
+			record("withdraw", debited(transaction).Neg(), err)
+		}()
+
+		go func(i int) {
+			defer wg.Done()
+			transaction, err := walletService.TransferFunds(sender.ID, recipientWallet.Number, wallet.Currency, decimal.NewFromInt(20), "", fmt.Sprintf("transfer-%d", i))
+			record("transfer", debited(transaction).Neg(), err)
+			if err == nil {
+				mu.Lock()
+				transferred = transferred.Add(transaction.Amount)
+				mu.Unlock()
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(done)
+	<-watched
+
+	if negative.Load() {
+		t.Error("the wallet balance went negative")
+	}
+
+	account, err := accountRepo.GetAccountByID(wallet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.Balance.Equal(expected) {
+		t.Errorf("sender balance = %s, want %s", account.Balance, expected)
+	}
+
+	received, err := accountRepo.GetAccountByID(recipientWallet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.Balance.Equal(transferred) {
+		t.Errorf("recipient balance = %s, want %s", received.Balance, transferred)
+	}
+
+	// the stored balance must agree with the journal
+	ledgerEntryRepo := core_repository.NewLedgerEntryRepository(db)
+	credits, err := ledgerEntryRepo.GetTotalCreditsByAccountID(wallet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debits, err := ledgerEntryRepo.GetTotalDebitsByAccountID(wallet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !credits.Sub(debits).Equal(account.Balance) {
+		t.Errorf("journal balance = %s, stored balance = %s", credits.Sub(debits), account.Balance)
+	}
+}
+
+func TestTransferKeepsQuoteWhenItFails(t *testing.T) {
+	cache := newFakeCache()
+	db := cachedDatabase{DatabaseInterface: testDatabase(t), cache: cache}
+	walletService := newTestWalletService(db)
+
+	sender, wallet := testUser(t, db)
+	recipient, _ := testUser(t, db)
+	recipientWallet, err := walletService.OpenWallet(recipient.ID, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amount := decimal.NewFromInt(15000)
+	quote, err := newTestFxService(t, cache).Quote(sender.ID, wallet.Currency, "USD", amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the empty wallet cannot pay, and the quote is left for the next try
+	_, err = walletService.TransferFunds(sender.ID, recipientWallet.AccountNumber, wallet.Currency, amount, quote.QuoteID, "")
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("transfer from an empty wallet = %v, want %v", err, ErrInsufficientBalance)
+	}
+
+	if _, err := walletService.FundWallet(sender.ID, wallet.Currency, amount, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := walletService.TransferFunds(sender.ID, recipientWallet.AccountNumber, wallet.Currency, amount, quote.QuoteID, ""); err != nil {
+		t.Fatalf("transfer with the same quote: %v", err)
+	}
+
+	received, err := core_repository.NewAccountRepository(db).GetWalletAccountByUserID(recipient.ID, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.Balance.Equal(quote.TargetAmount) {
+		t.Errorf("recipient balance = %s, want %s", received.Balance, quote.TargetAmount)
+	}
+
+	// the transfer that went through used the quote up
+	if _, err := consumeQuote(cache, sender.ID, quote.QuoteID); !errors.Is(err, ErrQuoteNotFound) {
+		t.Errorf("consume after the transfer = %v, want %v", err, ErrQuoteNotFound)
+	}
+}
+
+func TestHoldIdempotencyKeyIsNotAWithdrawal(t *testing.T) {
+	db := testDatabase(t)
+	walletService := newTestWalletService(db)
+
+	user, wallet := testUser(t, db)
+	if _, err := walletService.FundWallet(user.ID, wallet.Currency, decimal.NewFromInt(500), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	key := uuid.NewString()
+	if _, err := walletService.WithdrawFromWallet(user.ID, wallet.Currency, decimal.NewFromInt(50), key); err != nil {
+		t.Fatal(err)
+	}
+
+	// the withdrawal's key does not replay as a hold
+	if _, err := walletService.AuthorizeHold(user.ID, wallet.Currency, decimal.NewFromInt(50), "", key); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+		t.Errorf("hold with a withdrawal's key = %v, want %v", err, ErrIdempotencyKeyMismatch)
+	}
+
+	holdKey := uuid.NewString()
+	hold, err := walletService.AuthorizeHold(user.ID, wallet.Currency, decimal.NewFromInt(50), "", holdKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := walletService.AuthorizeHold(user.ID, wallet.Currency, decimal.NewFromInt(50), "", holdKey)
+	if err != nil || replayed.Reference != hold.Reference {
+		t.Errorf("replayed hold = %s, %v, want %s", replayed.Reference, err, hold.Reference)
+	}
+}
+
+func TestReverseTransactionRecordsActor(t *testing.T) {
+	db := testDatabase(t)
+	walletService := newTestWalletService(db)
+
+	user, wallet := testUser(t, db)
+	funding, err := walletService.FundWallet(user.ID, wallet.Currency, decimal.NewFromInt(100), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actorID := uuid.New()
+	reversal, err := walletService.ReverseTransaction(actorID, funding.Reference, "duplicate deposit", decimal.Zero, dto.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := core_repository.NewTransactionRepository(db).GetTransactionByReference(reversal.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := transactionMetadata(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata["actor_id"] != actorID.String() || metadata["reason"] != "duplicate deposit" {
+		t.Errorf("reversal metadata = %v", metadata)
+	}
+
+	var audit model.AuditLog
+	err = db.Connection().
+		Where("user_id = ? AND action = ?", user.ID, model.AuditActionTransactionReversed).
+		First(&audit).Error
+	if err != nil {
+		t.Fatalf("reading the audit log: %v", err)
+	}
+	for _, want := range []string{actorID.String(), reversal.Reference, funding.Reference, "duplicate deposit"} {
+		if !strings.Contains(string(audit.Metadata), want) {
+			t.Errorf("audit metadata %s does not mention %s", audit.Metadata, want)
+		}
+	}
+}
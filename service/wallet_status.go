@@ -0,0 +1,251 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/model"
+)
+
+// Machine readable codes returned when an account's state does not allow an operation.
+const (
+	CodeAccountFrozen = "ACCOUNT_FROZEN"
+	CodeAccountClosed = "ACCOUNT_CLOSED"
+)
+
+var (
+	ErrNotWalletAccount     = errors.New("only wallet accounts have a lifecycle")
+	ErrInvalidStatusChange  = errors.New("the account cannot move to this status")
+	ErrAccountHasHolds      = errors.New("release or capture the account's holds before closing it")
+	ErrSweepAccountRequired = errors.New("the account has a balance, a sweep account is required to close it")
+	ErrInvalidSweepAccount  = errors.New("the sweep account must be another open wallet in the same currency")
+	ErrStatusReasonRequired = errors.New("a reason is required to change an account's status")
+)
+
+// AccountStateError is returned when an account's state does not allow money to move in or out.
+type AccountStateError struct {
+	Code    string
+	Message string
+}
+
+func (e *AccountStateError) Error() string {
+	return e.Message
+}
+
+// checkCanDebit refuses to take money from an account that is frozen or closed.
+func checkCanDebit(account *model.Account) error {
+	if account.CanDebit() {
+		return nil
+	}
+	return accountStateError(account)
+}
+
+// checkCanCredit refuses to pay money into an account that is frozen for credits or closed.
+func checkCanCredit(account *model.Account) error {
+	if account.CanCredit() {
+		return nil
+	}
+	return accountStateError(account)
+}
+
+func accountStateError(account *model.Account) error {
+	if account.Status == model.AccountStatusClosed {
+		return &AccountStateError{Code: CodeAccountClosed, Message: fmt.Sprintf("account %s is closed", account.Number)}
+	}
+	return &AccountStateError{Code: CodeAccountFrozen, Message: fmt.Sprintf("account %s is frozen", account.Number)}
+}
+
+// ChangeAccountStatus freezes or unfreezes a wallet and records the transition with its reason.
+func (s *walletService) ChangeAccountStatus(actorID uuid.UUID, accountID uuid.UUID, status string, reason string) (dto.WalletDetailsDto, error) {
+	var updated *model.Account
+
+	if reason == "" {
+		return dto.WalletDetailsDto{}, ErrStatusReasonRequired
+	}
+
+	err := s.TxHelper(func(repos walletRepositories) error {
+		locked, err := lockAccounts(repos.accountRepo, accountID)
+		if err != nil {
+			return err
+		}
+		account := locked[accountID]
+
+		if account.AccountType != model.AccountTypeWallet {
+			return ErrNotWalletAccount
+		}
+
+		if !account.CanTransitionTo(status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidStatusChange, account.Status, status)
+		}
+
+		if err := recordStatusChange(repos, account, actorID, status, reason, ""); err != nil {
+			return err
+		}
+
+		updated = account
+		return nil
+	})
+	if err != nil {
+		return dto.WalletDetailsDto{}, err
+	}
+
+	return toWalletDetailsDto(updated), nil
+}
+
+// CloseAccount closes a wallet for good. A wallet with a balance is only closed when
+// sweepToAccountNumber names another open wallet in the same currency, which receives the balance.
+func (s *walletService) CloseAccount(actorID uuid.UUID, accountID uuid.UUID, reason string, sweepToAccountNumber string) (dto.AccountClosureDto, error) {
+	var closure dto.AccountClosureDto
+
+	if reason == "" {
+		return dto.AccountClosureDto{}, ErrStatusReasonRequired
+	}
+
+	var sweepTo *model.Account
+	if sweepToAccountNumber != "" {
+		account, err := s.accountRepo.GetAccountByNumber(sweepToAccountNumber)
+		if err != nil {
+			return dto.AccountClosureDto{}, ErrInvalidSweepAccount
+		}
+		sweepTo = account
+	}
+
+	err := s.TxHelper(func(repos walletRepositories) error {
+		accountIDs := []uuid.UUID{accountID}
+		if sweepTo != nil {
+			accountIDs = append(accountIDs, sweepTo.ID)
+		}
+
+		locked, err := lockAccounts(repos.accountRepo, accountIDs...)
+		if err != nil {
+			return err
+		}
+		account := locked[accountID]
+
+		if account.AccountType != model.AccountTypeWallet {
+			return ErrNotWalletAccount
+		}
+
+		if account.Status == model.AccountStatusClosed {
+			return fmt.Errorf("%w: the account is already closed", ErrInvalidStatusChange)
+		}
+
+		if account.HeldBalance.IsPositive() {
+			return ErrAccountHasHolds
+		}
+
+		closure.Sweep = nil
+		sweepReference := ""
+		if account.Balance.IsPositive() {
+			if sweepTo == nil {
+				return ErrSweepAccountRequired
+			}
+
+			target := locked[sweepTo.ID]
+			if target.ID == account.ID || target.AccountType != model.AccountTypeWallet || target.Currency != account.Currency {
+				return ErrInvalidSweepAccount
+			}
+			if err := checkCanCredit(target); err != nil {
+				return err
+			}
+
+			sweep, err := sweepBalance(repos, account, target, reason)
+			if err != nil {
+				return err
+			}
+			closure.Sweep = &sweep
+			sweepReference = sweep.Reference
+		}
+
+		if err := recordStatusChange(repos, account, actorID, model.AccountStatusClosed, reason, sweepReference); err != nil {
+			return err
+		}
+
+		closure.Account = toWalletDetailsDto(account)
+		return nil
+	})
+	if err != nil {
+		return dto.AccountClosureDto{}, err
+	}
+
+	return closure, nil
+}
+
+func (s *walletService) GetAccountStatusHistory(accountID uuid.UUID) ([]model.AccountStatusChange, error) {
+	if _, err := s.accountRepo.GetAccountByID(accountID); err != nil {
+		return nil, err
+	}
+
+	return s.accountRepo.GetStatusChanges(accountID)
+}
+
+// sweepBalance moves the whole balance of a closing account to target. It is posted directly,
+// as the closing account may already be frozen.
+func sweepBalance(repos walletRepositories, account *model.Account, target *model.Account, reason string) (dto.TransactionDto, error) {
+	transaction := model.Transaction{
+		UserID:      *account.UserID,
+		Type:        model.Debit,
+		Status:      model.TransactionCompleted,
+		Operation:   model.OperationClosure,
+		Amount:      account.Balance,
+		Currency:    account.Currency,
+		Description: truncate("Account closure: "+reason, 255),
+	}
+
+	if err := setTransactionMetadata(&transaction, map[string]interface{}{
+		"closed_account_number": account.Number,
+		"sweep_account_number":  target.Number,
+	}); err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	if err := repos.transactionRepo.CreateTransaction(&transaction); err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	postings := []posting{
+		{account: account, entryType: model.Debit, amount: account.Balance, description: "Balance swept on account closure"},
+		{account: target, entryType: model.Credit, amount: account.Balance, description: "Balance received from closed account " + account.Number},
+	}
+
+	if err := applyPostings(repos, &transaction, postings); err != nil {
+		return dto.TransactionDto{}, err
+	}
+
+	return toTransactionDto(transaction), nil
+}
+
+// recordStatusChange moves the locked account to status and stores the transition. sweepReference
+// names the transaction that swept the balance of a closing account, if any.
+func recordStatusChange(repos walletRepositories, account *model.Account, actorID uuid.UUID, status string, reason string, sweepReference string) error {
+	if err := repos.accountRepo.UpdateAccountStatus(account.ID, status); err != nil {
+		return err
+	}
+
+	change := &model.AccountStatusChange{
+		AccountID:  account.ID,
+		ActorID:    &actorID,
+		FromStatus: account.Status,
+		ToStatus:   status,
+		Reason:     reason,
+	}
+	if err := repos.accountRepo.CreateStatusChange(change); err != nil {
+		return err
+	}
+
+	account.Status = status
+	account.Version++
+
+	return recordEvent(repos.outboxRepo, model.EventAccountStatusChanged, dto.AccountStatusChangedV1{
+		UserID:         account.UserID.String(),
+		AccountNumber:  account.Number,
+		FromStatus:     change.FromStatus,
+		ToStatus:       status,
+		Reason:         reason,
+		ActorID:        actorID.String(),
+		SweepReference: sweepReference,
+	})
+}
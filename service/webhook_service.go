@@ -0,0 +1,539 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/dto"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+var (
+	// WebhookMaxAttempts is how many times a delivery is attempted before it is dead.
+	WebhookMaxAttempts = 10
+	// WebhookRetryDelay is the wait after the first failed attempt, it doubles with every further failure.
+	WebhookRetryDelay = 30 * time.Second
+	// WebhookMaxRetryDelay caps the wait between two attempts.
+	WebhookMaxRetryDelay = 6 * time.Hour
+	// WebhookTimeout is how long an endpoint has to answer.
+	WebhookTimeout = 10 * time.Second
+)
+
+const (
+	webhookSecretMarker       = "whsec_"
+	webhookSecretBytes        = 24
+	maxWebhookEndpoints       = 10
+	dueWebhooksBatchSize      = 100
+	webhookDeliveriesLimit    = 50
+	webhookResponseBodyLength = 1024
+	// webhookClaimDuration is how long an instance owns a delivery while sending it.
+	webhookClaimDuration = time.Minute
+)
+
+// Headers of a webhook request. The signature is the hex HMAC-SHA256 of "<timestamp>.<body>" keyed
+// by the endpoint's secret, sent as v1=<signature>.
+const (
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrTooManyWebhooks         = errors.New("too many webhook endpoints, delete one first")
+	ErrWebhookDeliveryInFlight = errors.New("the delivery is being sent, try again shortly")
+
+	errWebhookNotHTTPS       = errors.New("webhook URL must use https")
+	errWebhookAddressRefused = errors.New("webhook URL resolves to a non-public address")
+)
+
+// WebhookInput describes a webhook endpoint. Active is left unchanged when nil.
+type WebhookInput struct {
+	URL         string
+	Description string
+	Events      []string
+	Active      *bool
+}
+
+type WebhookServiceInterface interface {
+	CreateEndpoint(userID uuid.UUID, input WebhookInput, client dto.ClientInfo) (dto.WebhookEndpointCreatedDto, error)
+	GetEndpoints(userID uuid.UUID) ([]dto.WebhookEndpointDto, error)
+	GetEndpoint(userID uuid.UUID, id uuid.UUID) (dto.WebhookEndpointDto, error)
+	UpdateEndpoint(userID uuid.UUID, id uuid.UUID, input WebhookInput) (dto.WebhookEndpointDto, error)
+	DeleteEndpoint(userID uuid.UUID, id uuid.UUID, client dto.ClientInfo) error
+	GetDeliveries(userID uuid.UUID, endpointID uuid.UUID, status string) ([]dto.WebhookDeliveryDto, error)
+	GetDelivery(userID uuid.UUID, endpointID uuid.UUID, id uuid.UUID) (dto.WebhookDeliveryDetailDto, error)
+	Redeliver(userID uuid.UUID, endpointID uuid.UUID, id uuid.UUID) (dto.WebhookDeliveryDto, error)
+	DeliverDueWebhooks() (int, error)
+}
+
+type webhookService struct {
+	webhookRepo  core_repository.WebhookRepository
+	auditService AuditServiceInterface
+	client       *http.Client
+}
+
+func NewWebhookService(webhookRepo core_repository.WebhookRepository, auditService AuditServiceInterface) WebhookServiceInterface {
+	return &webhookService{
+		webhookRepo:  webhookRepo,
+		auditService: auditService,
+		client:       newWebhookClient(),
+	}
+}
+
+// newWebhookClient returns the client webhooks are sent with. It only connects to public addresses,
+// checked on the address actually dialed after DNS resolution, so a host name that resolves, or is
+// later re-pointed, to a loopback, private or link-local address is refused as well.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: WebhookTimeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !helper.IsPublicIP(addrPort.Addr()) {
+				return errWebhookAddressRefused
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	return &http.Client{
+		Timeout:   WebhookTimeout,
+		Transport: transport,
+		// a redirect is an answer like any other, following it could send the payload elsewhere
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CreateEndpoint stores a new endpoint and returns it with its signing secret, which cannot be shown again.
+func (s *webhookService) CreateEndpoint(userID uuid.UUID, input WebhookInput, client dto.ClientInfo) (dto.WebhookEndpointCreatedDto, error) {
+	count, err := s.webhookRepo.CountEndpoints(userID)
+	if err != nil {
+		return dto.WebhookEndpointCreatedDto{}, err
+	}
+	if count >= maxWebhookEndpoints {
+		return dto.WebhookEndpointCreatedDto{}, ErrTooManyWebhooks
+	}
+
+	secret, err := randomHex(webhookSecretBytes)
+	if err != nil {
+		return dto.WebhookEndpointCreatedDto{}, err
+	}
+
+	endpoint := &model.WebhookEndpoint{
+		UserID:      userID,
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      input.Events,
+		Secret:      webhookSecretMarker + secret,
+		Active:      input.Active == nil || *input.Active,
+	}
+
+	if err := s.webhookRepo.CreateEndpoint(endpoint); err != nil {
+		return dto.WebhookEndpointCreatedDto{}, err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionWebhookCreated, client, map[string]interface{}{
+		"webhook_id": endpoint.ID,
+		"url":        endpoint.URL,
+		"events":     input.Events,
+	})
+
+	return dto.WebhookEndpointCreatedDto{WebhookEndpointDto: toWebhookEndpointDto(endpoint), Secret: endpoint.Secret}, nil
+}
+
+func (s *webhookService) GetEndpoints(userID uuid.UUID) ([]dto.WebhookEndpointDto, error) {
+	endpoints, err := s.webhookRepo.GetEndpointsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.WebhookEndpointDto, 0, len(endpoints))
+	for i := range endpoints {
+		result = append(result, toWebhookEndpointDto(&endpoints[i]))
+	}
+
+	return result, nil
+}
+
+func (s *webhookService) GetEndpoint(userID uuid.UUID, id uuid.UUID) (dto.WebhookEndpointDto, error) {
+	endpoint, err := s.webhookRepo.GetEndpointByID(userID, id)
+	if err != nil {
+		return dto.WebhookEndpointDto{}, err
+	}
+
+	return toWebhookEndpointDto(endpoint), nil
+}
+
+func (s *webhookService) UpdateEndpoint(userID uuid.UUID, id uuid.UUID, input WebhookInput) (dto.WebhookEndpointDto, error) {
+	endpoint, err := s.webhookRepo.GetEndpointByID(userID, id)
+	if err != nil {
+		return dto.WebhookEndpointDto{}, err
+	}
+
+	endpoint.URL = input.URL
+	endpoint.Description = input.Description
+	endpoint.Events = input.Events
+	if input.Active != nil {
+		endpoint.Active = *input.Active
+	}
+
+	if err := s.webhookRepo.UpdateEndpoint(endpoint); err != nil {
+		return dto.WebhookEndpointDto{}, err
+	}
+
+	return toWebhookEndpointDto(endpoint), nil
+}
+
+func (s *webhookService) DeleteEndpoint(userID uuid.UUID, id uuid.UUID, client dto.ClientInfo) error {
+	endpoint, err := s.webhookRepo.GetEndpointByID(userID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.webhookRepo.DeleteEndpoint(endpoint); err != nil {
+		return err
+	}
+
+	s.auditService.Record(&userID, model.AuditActionWebhookDeleted, client, map[string]interface{}{
+		"webhook_id": endpoint.ID,
+		"url":        endpoint.URL,
+	})
+
+	return nil
+}
+
+func (s *webhookService) GetDeliveries(userID uuid.UUID, endpointID uuid.UUID, status string) ([]dto.WebhookDeliveryDto, error) {
+	endpoint, err := s.webhookRepo.GetEndpointByID(userID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.webhookRepo.GetDeliveriesByEndpointID(endpoint.ID, status, webhookDeliveriesLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.WebhookDeliveryDto, 0, len(deliveries))
+	for i := range deliveries {
+		result = append(result, toWebhookDeliveryDto(&deliveries[i]))
+	}
+
+	return result, nil
+}
+
+func (s *webhookService) GetDelivery(userID uuid.UUID, endpointID uuid.UUID, id uuid.UUID) (dto.WebhookDeliveryDetailDto, error) {
+	endpoint, err := s.webhookRepo.GetEndpointByID(userID, endpointID)
+	if err != nil {
+		return dto.WebhookDeliveryDetailDto{}, err
+	}
+
+	delivery, err := s.webhookRepo.GetDeliveryByID(endpoint.ID, id)
+	if err != nil {
+		return dto.WebhookDeliveryDetailDto{}, err
+	}
+
+	attempts, err := s.webhookRepo.GetAttemptsByDeliveryID(delivery.ID)
+	if err != nil {
+		return dto.WebhookDeliveryDetailDto{}, err
+	}
+
+	detail := dto.WebhookDeliveryDetailDto{
+		WebhookDeliveryDto: toWebhookDeliveryDto(delivery),
+		Payload:            json.RawMessage(delivery.Payload),
+		AttemptLogs:        make([]dto.WebhookAttemptDto, 0, len(attempts)),
+	}
+
+	for _, attempt := range attempts {
+		detail.AttemptLogs = append(detail.AttemptLogs, dto.WebhookAttemptDto{
+			StatusCode:   attempt.StatusCode,
+			ResponseBody: attempt.ResponseBody,
+			Error:        attempt.Error,
+			DurationMs:   attempt.DurationMs,
+			CreatedAt:    attempt.CreatedAt,
+		})
+	}
+
+	return detail, nil
+}
+
+// Redeliver queues a delivery to be sent again right away with a fresh set of attempts, whatever
+// its state. The payload is sent as it was, with the same event ID.
+func (s *webhookService) Redeliver(userID uuid.UUID, endpointID uuid.UUID, id uuid.UUID) (dto.WebhookDeliveryDto, error) {
+	endpoint, err := s.webhookRepo.GetEndpointByID(userID, endpointID)
+	if err != nil {
+		return dto.WebhookDeliveryDto{}, err
+	}
+
+	delivery, err := s.webhookRepo.GetDeliveryByID(endpoint.ID, id)
+	if err != nil {
+		return dto.WebhookDeliveryDto{}, err
+	}
+
+	now := time.Now()
+	if delivery.ClaimedUntil != nil && delivery.ClaimedUntil.After(now) {
+		return dto.WebhookDeliveryDto{}, ErrWebhookDeliveryInFlight
+	}
+
+	delivery.Status = model.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.DeliveredAt = nil
+	delivery.LastError = ""
+	delivery.ClaimedUntil = nil
+
+	if err := s.webhookRepo.SaveDeliveryResult(delivery); err != nil {
+		return dto.WebhookDeliveryDto{}, err
+	}
+
+	return toWebhookDeliveryDto(delivery), nil
+}
+
+// DeliverDueWebhooks sends every due delivery this instance manages to claim. Deliveries claimed
+// by another instance are skipped, so the cron job can run on every instance at the same time.
+func (s *webhookService) DeliverDueWebhooks() (int, error) {
+	now := time.Now()
+
+	deliveries, err := s.webhookRepo.GetDueDeliveries(now, dueWebhooksBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		claimed, err := s.webhookRepo.ClaimDelivery(delivery.ID, now, time.Now().Add(webhookClaimDuration))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.deliver(delivery); err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+// deliver makes one attempt at a claimed delivery and records it. A 2xx answer delivers it, anything
+// else is retried after a doubling delay until WebhookMaxAttempts is reached and the delivery is dead.
+func (s *webhookService) deliver(delivery *model.WebhookDelivery) error {
+	endpoint, err := s.webhookRepo.GetEndpointForDelivery(delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+
+	// deliveries of a deleted or disabled endpoint wait for a manual redelivery
+	if endpoint.DeletedAt.Valid || !endpoint.Active {
+		delivery.Status = model.WebhookDeliveryDead
+		delivery.LastError = "endpoint is disabled"
+		delivery.ClaimedUntil = nil
+		return s.webhookRepo.SaveDeliveryResult(delivery)
+	}
+
+	attempt := s.send(endpoint, delivery)
+	if err := s.webhookRepo.CreateAttempt(attempt); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ClaimedUntil = nil
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = model.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	case delivery.Attempts >= WebhookMaxAttempts:
+		delivery.Status = model.WebhookDeliveryDead
+		delivery.LastError = attempt.Error
+	default:
+		delivery.NextAttemptAt = now.Add(retryDelay(WebhookRetryDelay, WebhookMaxRetryDelay, delivery.Attempts))
+		delivery.LastError = attempt.Error
+	}
+
+	return s.webhookRepo.SaveDeliveryResult(delivery)
+}
+
+// send posts the payload to the endpoint. The returned attempt has an Error unless the endpoint answered 2xx.
+func (s *webhookService) send(endpoint *model.WebhookEndpoint, delivery *model.WebhookDelivery) *model.WebhookAttempt {
+	attempt := &model.WebhookAttempt{DeliveryID: delivery.ID}
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	// endpoints stored before https was required are not sent to
+	if parsed, err := url.Parse(endpoint.URL); err != nil || parsed.Scheme != "https" {
+		attempt.Error = errWebhookNotHTTPS.Error()
+		return attempt
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = truncate(err.Error(), 255)
+		return attempt
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WalletSync-Webhooks/1.0")
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID.String())
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderDelivery, delivery.ID.String())
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, "v1="+SignWebhookPayload(endpoint.Secret, timestamp, body))
+
+	started := time.Now()
+	res, err := s.client.Do(req)
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	if errors.Is(err, errWebhookAddressRefused) {
+		// nothing about a refused target is kept, not even the dial error with its address
+		attempt.Error = errWebhookAddressRefused.Error()
+		return attempt
+	}
+	if err != nil {
+		attempt.Error = truncate(err.Error(), 255)
+		return attempt
+	}
+	defer res.Body.Close()
+
+	attempt.StatusCode = res.StatusCode
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint answered %d", res.StatusCode)
+	}
+
+	// the body of a redirect may come from wherever it points, so it is not shown back to the user
+	if res.StatusCode < 300 || res.StatusCode > 399 {
+		responseBody, _ := io.ReadAll(io.LimitReader(res.Body, webhookResponseBodyLength))
+		attempt.ResponseBody = string(responseBody)
+	}
+
+	return attempt
+}
+
+// SignWebhookPayload returns the signature of a webhook body sent at timestamp, which receivers
+// compute with their endpoint's secret to check a request came from us.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueueWebhook writes a delivery of the event for each of the user's endpoints subscribed to it.
+// Called with the repository of a running DB transaction, the deliveries are committed or rolled
+// back with the money movement the event reports.
+func enqueueWebhook(webhookRepo core_repository.WebhookRepository, userID uuid.UUID, eventType string, data interface{}) error {
+	endpoints, err := webhookRepo.GetSubscribedEndpoints(userID, eventType)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	now := time.Now()
+	event := dto.WebhookEventDto{
+		ID:        uuid.NewString(),
+		Type:      eventType,
+		CreatedAt: now.Format(time.RFC3339),
+		Data:      data,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		err := webhookRepo.CreateDelivery(&model.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       uuid.MustParse(event.ID),
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retryDelay is the wait after the given number of failed attempts, starting at base and
+// doubling with every further failure up to limit.
+func retryDelay(base time.Duration, limit time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+func toWebhookEndpointDto(endpoint *model.WebhookEndpoint) dto.WebhookEndpointDto {
+	events := []string(endpoint.Events)
+	if events == nil {
+		events = []string{}
+	}
+
+	return dto.WebhookEndpointDto{
+		ID:          endpoint.ID.String(),
+		URL:         endpoint.URL,
+		Description: endpoint.Description,
+		Events:      events,
+		Active:      endpoint.Active,
+		CreatedAt:   endpoint.CreatedAt,
+	}
+}
+
+func toWebhookDeliveryDto(delivery *model.WebhookDelivery) dto.WebhookDeliveryDto {
+	deliveryDto := dto.WebhookDeliveryDto{
+		ID:            delivery.ID.String(),
+		EventID:       delivery.EventID.String(),
+		EventType:     delivery.EventType,
+		Status:        delivery.Status,
+		Attempts:      delivery.Attempts,
+		LastAttemptAt: delivery.LastAttemptAt,
+		DeliveredAt:   delivery.DeliveredAt,
+		LastError:     delivery.LastError,
+		CreatedAt:     delivery.CreatedAt,
+	}
+
+	if delivery.Status == model.WebhookDeliveryPending {
+		deliveryDto.NextAttemptAt = &delivery.NextAttemptAt
+	}
+
+	return deliveryDto
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	core_repository "github.com/horlakz/wallet-sync.api/repository/core"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// fakeWebhookRepository keeps one endpoint and one delivery in memory.
+type fakeWebhookRepository struct {
+	core_repository.WebhookRepository
+
+	endpoint *model.WebhookEndpoint
+	delivery *model.WebhookDelivery
+	attempts []*model.WebhookAttempt
+}
+
+func (r *fakeWebhookRepository) GetEndpointByID(userID uuid.UUID, id uuid.UUID) (*model.WebhookEndpoint, error) {
+	return r.endpoint, nil
+}
+
+func (r *fakeWebhookRepository) GetEndpointForDelivery(id uuid.UUID) (*model.WebhookEndpoint, error) {
+	return r.endpoint, nil
+}
+
+func (r *fakeWebhookRepository) GetDeliveryByID(endpointID uuid.UUID, id uuid.UUID) (*model.WebhookDelivery, error) {
+	return r.delivery, nil
+}
+
+func (r *fakeWebhookRepository) CreateAttempt(attempt *model.WebhookAttempt) error {
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+
+func (r *fakeWebhookRepository) SaveDeliveryResult(delivery *model.WebhookDelivery) error {
+	r.delivery = delivery
+	return nil
+}
+
+// newTestWebhookService points a webhook service at server. The server's client trusts its
+// certificate and keeps the redirect policy of the real client, whose dialer refuses loopback.
+func newTestWebhookService(server *httptest.Server) (*webhookService, *fakeWebhookRepository) {
+	repo := &fakeWebhookRepository{
+		endpoint: &model.WebhookEndpoint{URL: server.URL + "/hook", Secret: testWebhookSecret, Active: true},
+		delivery: &model.WebhookDelivery{
+			EventID:   uuid.New(),
+			EventType: model.WebhookEventWalletFunded,
+			Payload:   `{"type":"wallet.funded"}`,
+			Status:    model.WebhookDeliveryPending,
+		},
+	}
+
+	client := server.Client()
+	client.CheckRedirect = newWebhookClient().CheckRedirect
+
+	return &webhookService{webhookRepo: repo, client: client}, repo
+}
+
+func TestWebhookSendSignsPayload(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhookService, repo := newTestWebhookService(server)
+	if err := webhookService.deliver(repo.delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != repo.delivery.Payload {
+		t.Errorf("body = %s, want %s", body, repo.delivery.Payload)
+	}
+	if got := received.Header.Get(WebhookHeaderEventID); got != repo.delivery.EventID.String() {
+		t.Errorf("event ID header = %q, want %q", got, repo.delivery.EventID)
+	}
+	if got := received.Header.Get(WebhookHeaderEvent); got != repo.delivery.EventType {
+		t.Errorf("event header = %q, want %q", got, repo.delivery.EventType)
+	}
+
+	timestamp := received.Header.Get(WebhookHeaderTimestamp)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("timestamp header %q: %v", timestamp, err)
+	}
+
+	// what a receiver computes with its secret
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	want := "v1=" + hex.EncodeToString(mac.Sum(nil))
+	if got := received.Header.Get(WebhookHeaderSignature); got != want {
+		t.Errorf("signature header = %q, want %q", got, want)
+	}
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		attempts     int
+		wantStatus   string
+		wantAttempts int
+		wantRetry    bool
+	}{
+		{name: "2xx delivers", status: http.StatusOK, wantStatus: model.WebhookDeliveryDelivered, wantAttempts: 1},
+		{name: "non-2xx backs off", status: http.StatusInternalServerError, attempts: 2, wantStatus: model.WebhookDeliveryPending, wantAttempts: 3, wantRetry: true},
+		{name: "last attempt is dead", status: http.StatusBadRequest, attempts: WebhookMaxAttempts - 1, wantStatus: model.WebhookDeliveryDead, wantAttempts: WebhookMaxAttempts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			webhookService, repo := newTestWebhookService(server)
+			repo.delivery.Attempts = tt.attempts
+			claimed := time.Now().Add(webhookClaimDuration)
+			repo.delivery.ClaimedUntil = &claimed
+
+			before := time.Now()
+			if err := webhookService.deliver(repo.delivery); err != nil {
+				t.Fatal(err)
+			}
+			after := time.Now()
+
+			delivery := repo.delivery
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", delivery.Status, tt.wantStatus)
+			}
+			if delivery.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", delivery.Attempts, tt.wantAttempts)
+			}
+			if delivery.ClaimedUntil != nil {
+				t.Error("the claim was not released")
+			}
+			if len(repo.attempts) != 1 || repo.attempts[0].StatusCode != tt.status {
+				t.Fatalf("attempts logged = %+v, want one with status %d", repo.attempts, tt.status)
+			}
+
+			if tt.wantStatus == model.WebhookDeliveryDelivered && (delivery.DeliveredAt == nil || delivery.LastError != "") {
+				t.Errorf("delivered at = %v, last error = %q", delivery.DeliveredAt, delivery.LastError)
+			}
+			if tt.wantStatus != model.WebhookDeliveryDelivered && delivery.LastError == "" {
+				t.Error("the failure was not recorded")
+			}
+
+			if tt.wantRetry {
+				delay := retryDelay(WebhookRetryDelay, WebhookMaxRetryDelay, tt.wantAttempts)
+				if delivery.NextAttemptAt.Before(before.Add(delay)) || delivery.NextAttemptAt.After(after.Add(delay)) {
+					t.Errorf("next attempt at %s, want %s after the attempt", delivery.NextAttemptAt, delay)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookRedirectIsNotFollowed(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	webhookService, repo := newTestWebhookService(server)
+	if err := webhookService.deliver(repo.delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	if followed {
+		t.Error("the redirect was followed")
+	}
+
+	attempt := repo.attempts[0]
+	if attempt.StatusCode != http.StatusTemporaryRedirect || attempt.Error == "" {
+		t.Errorf("attempt = %d %q, want a failed %d", attempt.StatusCode, attempt.Error, http.StatusTemporaryRedirect)
+	}
+	if attempt.ResponseBody != "" {
+		t.Errorf("the redirect body was kept: %q", attempt.ResponseBody)
+	}
+	if repo.delivery.Status != model.WebhookDeliveryPending {
+		t.Errorf("status = %s, want %s", repo.delivery.Status, model.WebhookDeliveryPending)
+	}
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	reached := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	webhookService, repo := newTestWebhookService(server)
+	webhookService.client = newWebhookClient()
+
+	attempt := webhookService.send(repo.endpoint, repo.delivery)
+	if reached {
+		t.Error("the loopback server was reached")
+	}
+	if attempt.Error != errWebhookAddressRefused.Error() {
+		t.Errorf("error = %q, want %q", attempt.Error, errWebhookAddressRefused)
+	}
+}
+
+func TestWebhookRedeliverResetsAttempts(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	webhookService, repo := newTestWebhookService(server)
+	lastAttempt := time.Now().Add(-time.Hour)
+	repo.delivery.Status = model.WebhookDeliveryDead
+	repo.delivery.Attempts = WebhookMaxAttempts
+	repo.delivery.LastAttemptAt = &lastAttempt
+	repo.delivery.LastError = "endpoint answered 500"
+
+	redelivered, err := webhookService.Redeliver(uuid.New(), uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if redelivered.Status != model.WebhookDeliveryPending || redelivered.Attempts != 0 || redelivered.LastError != "" {
+		t.Errorf("redelivered = %+v, want a pending delivery with no attempts", redelivered)
+	}
+	if repo.delivery.NextAttemptAt.After(time.Now()) {
+		t.Errorf("next attempt at %s, want now", repo.delivery.NextAttemptAt)
+	}
+
+	// a delivery being sent is not reset under the sender
+	claimed := time.Now().Add(webhookClaimDuration)
+	repo.delivery.ClaimedUntil = &claimed
+	if _, err := webhookService.Redeliver(uuid.New(), uuid.New(), uuid.New()); err != ErrWebhookDeliveryInFlight {
+		t.Errorf("redeliver while claimed = %v, want %v", err, ErrWebhookDeliveryInFlight)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 20, want: 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := retryDelay(30*time.Second, 6*time.Hour, tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package validator
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/horlakz/wallet-sync.api/payload/request"
+)
+
+type AdminValidator struct {
+	Validator[request.TransactionReverseRequest]
+}
+
+func (validator *AdminValidator) ReverseValidate(reverseReq request.TransactionReverseRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&reverseReq,
+		validation.Field(&reverseReq.Reason, validation.Required, validation.Length(3, 255)),
+		validation.Field(&reverseReq.Amount, validation.Min(0.00)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AdminValidator) LimitRuleValidate(limitReq request.LimitRuleRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&limitReq,
+		validation.Field(&limitReq.UserID, is.UUID),
+		validation.Field(&limitReq.KycLevel, validation.Min(0)),
+		validation.Field(&limitReq.Operation, validation.Required, validation.In(model.OperationFunding, model.OperationWithdrawal, model.OperationTransfer)),
+		validation.Field(&limitReq.Currency, validation.Required, isCurrency),
+		validation.Field(&limitReq.PerTransaction, validation.Min(0.00)),
+		validation.Field(&limitReq.Daily, validation.Min(0.00)),
+		validation.Field(&limitReq.Monthly, validation.Min(0.00)),
+		validation.Field(&limitReq.VelocityCount, validation.Min(0)),
+		validation.Field(&limitReq.VelocityWindowMinutes, validation.Min(0)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AdminValidator) KycLevelValidate(kycReq request.KycLevelRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&kycReq,
+		validation.Field(&kycReq.KycLevel, validation.Min(0), validation.Max(3)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AdminValidator) RoleValidate(roleReq request.RoleRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&roleReq,
+		validation.Field(&roleReq.Role, validation.Required, validation.In(model.RoleUser, model.RoleSupport, model.RoleAdmin)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AdminValidator) AccountStatusValidate(statusReq request.AccountStatusRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&statusReq,
+		validation.Field(&statusReq.Status, validation.Required, validation.In(model.AccountStatusActive, model.AccountStatusFrozenDebit, model.AccountStatusFrozenAll)),
+		validation.Field(&statusReq.Reason, validation.Required, validation.Length(3, 255)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AdminValidator) AccountCloseValidate(closeReq request.AccountCloseRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&closeReq,
+		validation.Field(&closeReq.Reason, validation.Required, validation.Length(3, 255)),
+		validation.Field(&closeReq.SweepToAccountNumber, validation.Length(10, 10)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,49 @@
+package validator
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+
+	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/horlakz/wallet-sync.api/payload/request"
+)
+
+var isIPOrCIDR = validation.By(func(value interface{}) error {
+	text, _ := value.(string)
+
+	if strings.Contains(text, "/") {
+		if _, _, err := net.ParseCIDR(text); err == nil {
+			return nil
+		}
+	} else if net.ParseIP(text) != nil {
+		return nil
+	}
+
+	return errors.New("must be an IP address or CIDR range")
+})
+
+type APIKeyValidator struct {
+	Validator[request.APIKeyRequest]
+}
+
+func (validator *APIKeyValidator) APIKeyValidate(apiKeyReq request.APIKeyRequest) (map[string]interface{}, error) {
+	scopes := make([]interface{}, 0, len(model.APIKeyScopes))
+	for _, scope := range model.APIKeyScopes {
+		scopes = append(scopes, scope)
+	}
+
+	err := validation.ValidateStruct(&apiKeyReq,
+		validation.Field(&apiKeyReq.Name, validation.Required, validation.Length(1, 64)),
+		validation.Field(&apiKeyReq.Scopes, validation.Required, validation.Each(validation.In(scopes...))),
+		validation.Field(&apiKeyReq.AllowedIPs, validation.Length(0, 20), validation.Each(isIPOrCIDR)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
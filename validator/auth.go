@@ -15,6 +15,7 @@ func (validator *AuthValidator) LoginValidate(loginReq request.LoginRequest) (ma
 	err := validation.ValidateStruct(&loginReq,
 		validation.Field(&loginReq.Email, validation.Required, validation.Length(3, 32), is.Email),
 		validation.Field(&loginReq.Password, validation.Required, validation.Length(3, 32)),
+		validation.Field(&loginReq.DeviceName, validation.Length(0, 100)),
 	)
 
 	if err != nil {
@@ -37,3 +38,89 @@ func (validator *AuthValidator) RegisterValidate(registerDto request.RegisterReq
 
 	return nil, nil
 }
+
+func (validator *AuthValidator) RefreshValidate(refreshReq request.RefreshRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&refreshReq,
+		validation.Field(&refreshReq.RefreshToken, validation.Required),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AuthValidator) VerifyEmailValidate(verifyReq request.VerifyEmailRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&verifyReq,
+		validation.Field(&verifyReq.Token, validation.Required),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AuthValidator) UnlockAccountValidate(unlockReq request.UnlockAccountRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&unlockReq,
+		validation.Field(&unlockReq.Token, validation.Required),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AuthValidator) ForgotPasswordValidate(forgotReq request.ForgotPasswordRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&forgotReq,
+		validation.Field(&forgotReq.Email, validation.Required, validation.Length(3, 32), is.Email),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AuthValidator) ResetPasswordValidate(resetReq request.ResetPasswordRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&resetReq,
+		validation.Field(&resetReq.Token, validation.Required),
+		validation.Field(&resetReq.Password, validation.Required, validation.Length(3, 32)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AuthValidator) LoginTwoFactorValidate(loginReq request.LoginTwoFactorRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&loginReq,
+		validation.Field(&loginReq.MfaToken, validation.Required),
+		validation.Field(&loginReq.Code, validation.Required, validation.Length(6, 16)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *AuthValidator) TwoFactorCodeValidate(codeReq request.TwoFactorCodeRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&codeReq,
+		validation.Field(&codeReq.Code, validation.Required, validation.Length(6, 16)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,62 @@
+package validator
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+
+	"github.com/horlakz/wallet-sync.api/payload/request"
+)
+
+type ProfileValidator struct {
+	Validator[request.UpdateProfileRequest]
+}
+
+func (validator *ProfileValidator) UpdateProfileValidate(profileReq request.UpdateProfileRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&profileReq,
+		validation.Field(&profileReq.Name, validation.Required, validation.Length(3, 32)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *ProfileValidator) ChangePasswordValidate(passwordReq request.ChangePasswordRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&passwordReq,
+		validation.Field(&passwordReq.CurrentPassword, validation.Required),
+		validation.Field(&passwordReq.NewPassword, validation.Required, validation.Length(3, 32)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *ProfileValidator) ChangeEmailValidate(emailReq request.ChangeEmailRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&emailReq,
+		validation.Field(&emailReq.Email, validation.Required, validation.Length(3, 32), is.Email),
+		validation.Field(&emailReq.Password, validation.Required),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *ProfileValidator) ConfirmEmailChangeValidate(confirmReq request.ConfirmEmailChangeRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&confirmReq,
+		validation.Field(&confirmReq.Token, validation.Required),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
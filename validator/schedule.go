@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"errors"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/robfig/cron/v3"
+
+	"github.com/horlakz/wallet-sync.api/payload/request"
+)
+
+var isRFC3339 = validation.By(func(value interface{}) error {
+	text, _ := value.(string)
+	if text == "" {
+		return nil
+	}
+
+	if _, err := time.Parse(time.RFC3339, text); err != nil {
+		return errors.New("must be an RFC 3339 timestamp")
+	}
+
+	return nil
+})
+
+var isCronExpression = validation.By(func(value interface{}) error {
+	text, _ := value.(string)
+	if text == "" {
+		return nil
+	}
+
+	if _, err := cron.ParseStandard(text); err != nil {
+		return errors.New("must be a five field cron expression")
+	}
+
+	return nil
+})
+
+type ScheduleValidator struct {
+	Validator[request.ScheduledTransferRequest]
+}
+
+func (validator *ScheduleValidator) ScheduleValidate(scheduleReq request.ScheduledTransferRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&scheduleReq,
+		validation.Field(&scheduleReq.ToAccountNumber, validation.Required),
+		validation.Field(&scheduleReq.Amount, validation.Required, validation.Min(1.00)),
+		validation.Field(&scheduleReq.Currency, isCurrency),
+		validation.Field(&scheduleReq.Description, validation.Length(0, 255)),
+		validation.Field(&scheduleReq.RunAt, isRFC3339),
+		validation.Field(&scheduleReq.Cron, isCronExpression),
+		validation.Field(&scheduleReq.IntervalMinutes, validation.Min(0)),
+		validation.Field(&scheduleReq.Pin, validation.Required, isPin),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
@@ -1,17 +1,53 @@
 package validator
 
 import (
+	"errors"
+	"regexp"
+
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
 	"github.com/horlakz/wallet-sync.api/payload/request"
 )
 
+// isCurrency accepts an empty value (the default currency is used) or an ISO 4217 code.
+var isCurrency = validation.By(func(value interface{}) error {
+	code, _ := value.(string)
+	if code == "" {
+		return nil
+	}
+
+	if _, ok := helper.LookupCurrency(code); !ok {
+		return errors.New("must be a valid ISO 4217 currency code")
+	}
+
+	return nil
+})
+
+// isPin accepts a transaction PIN of 4 to 6 digits.
+var isPin = validation.Match(regexp.MustCompile(`^[0-9]{4,6}$`)).Error("must be 4 to 6 digits")
+
 type WalletValidator struct {
 	Validator[request.WalletFundRequest]
 }
 
+func (validator *WalletValidator) OpenValidate(openReq request.WalletOpenRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&openReq,
+		validation.Field(&openReq.Currency, validation.Required, isCurrency),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
 func (validator *WalletValidator) FundValidate(fundReq request.WalletFundRequest) (map[string]interface{}, error) {
 	err := validation.ValidateStruct(&fundReq,
 		validation.Field(&fundReq.Amount, validation.Required, validation.Min(1.00)),
+		validation.Field(&fundReq.Currency, isCurrency),
 	)
 
 	if err != nil {
@@ -24,6 +60,8 @@ func (validator *WalletValidator) FundValidate(fundReq request.WalletFundRequest
 func (validator *WalletValidator) WithdrawValidate(withdrawReq request.WalletWithdrawRequest) (map[string]interface{}, error) {
 	err := validation.ValidateStruct(&withdrawReq,
 		validation.Field(&withdrawReq.Amount, validation.Required, validation.Min(1.00)),
+		validation.Field(&withdrawReq.Currency, isCurrency),
+		validation.Field(&withdrawReq.Pin, validation.Required, isPin),
 	)
 
 	if err != nil {
@@ -37,6 +75,115 @@ func (validator *WalletValidator) TransferValidate(transferReq request.WalletTra
 	err := validation.ValidateStruct(&transferReq,
 		validation.Field(&transferReq.ToAccountNumber, validation.Required, validation.Length(10, 10)),
 		validation.Field(&transferReq.Amount, validation.Required, validation.Min(1.00)),
+		validation.Field(&transferReq.Currency, isCurrency),
+		validation.Field(&transferReq.QuoteID, is.UUID),
+		validation.Field(&transferReq.Pin, validation.Required, isPin),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) HoldValidate(holdReq request.WalletHoldRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&holdReq,
+		validation.Field(&holdReq.Amount, validation.Required, validation.Min(1.00)),
+		validation.Field(&holdReq.Currency, isCurrency),
+		validation.Field(&holdReq.Description, validation.Length(0, 255)),
+		validation.Field(&holdReq.Pin, validation.Required, isPin),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) CaptureValidate(captureReq request.WalletCaptureRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&captureReq,
+		validation.Field(&captureReq.Amount, validation.Min(0.00)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) QuoteValidate(quoteReq request.WalletQuoteRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&quoteReq,
+		validation.Field(&quoteReq.FromCurrency, validation.Required, isCurrency),
+		validation.Field(&quoteReq.ToCurrency, validation.Required, isCurrency),
+		validation.Field(&quoteReq.Amount, validation.Required, validation.Min(1.00)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) ConvertValidate(convertReq request.WalletConvertRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&convertReq,
+		validation.Field(&convertReq.QuoteID, validation.Required, is.UUID),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) FeeQuoteValidate(feeReq request.WalletFeeQuoteRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&feeReq,
+		validation.Field(&feeReq.Operation, validation.Required, validation.In(model.OperationWithdrawal, model.OperationTransfer)),
+		validation.Field(&feeReq.Currency, isCurrency),
+		validation.Field(&feeReq.Amount, validation.Required, validation.Min(1.00)),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) SetPinValidate(pinReq request.WalletSetPinRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&pinReq,
+		validation.Field(&pinReq.Pin, validation.Required, isPin),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) ChangePinValidate(pinReq request.WalletChangePinRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&pinReq,
+		validation.Field(&pinReq.CurrentPin, validation.Required, isPin),
+		validation.Field(&pinReq.NewPin, validation.Required, isPin),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}
+
+func (validator *WalletValidator) ResetPinValidate(pinReq request.WalletResetPinRequest) (map[string]interface{}, error) {
+	err := validation.ValidateStruct(&pinReq,
+		validation.Field(&pinReq.Password, validation.Required),
+		validation.Field(&pinReq.Code, validation.Length(6, 16)),
+		validation.Field(&pinReq.NewPin, validation.Required, isPin),
 	)
 
 	if err != nil {
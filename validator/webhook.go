@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+
+	"github.com/horlakz/wallet-sync.api/internal/helper"
+	"github.com/horlakz/wallet-sync.api/model"
+	"github.com/horlakz/wallet-sync.api/payload/request"
+)
+
+// isPublicHTTPSURL accepts https URLs of public hosts. Host names are checked again against the
+// addresses they resolve to when a webhook is sent.
+var isPublicHTTPSURL = validation.By(func(value interface{}) error {
+	text, _ := value.(string)
+
+	parsed, err := url.Parse(text)
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return errors.New("must be an https URL")
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("must be a public address")
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && !helper.IsPublicIP(addr) {
+		return errors.New("must be a public address")
+	}
+
+	return nil
+})
+
+type WebhookValidator struct {
+	Validator[request.WebhookRequest]
+}
+
+func (validator *WebhookValidator) WebhookValidate(webhookReq request.WebhookRequest) (map[string]interface{}, error) {
+	events := make([]interface{}, 0, len(model.WebhookEvents))
+	for _, event := range model.WebhookEvents {
+		events = append(events, event)
+	}
+
+	err := validation.ValidateStruct(&webhookReq,
+		validation.Field(&webhookReq.URL, validation.Required, validation.Length(1, 2048), is.URL, isPublicHTTPSURL),
+		validation.Field(&webhookReq.Description, validation.Length(0, 255)),
+		validation.Field(&webhookReq.Events, validation.Required, validation.Each(validation.In(events...))),
+	)
+
+	if err != nil {
+		return validator.ValidateErr(err)
+	}
+
+	return nil, nil
+}